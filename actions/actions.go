@@ -0,0 +1,259 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/permissions"
+)
+
+var log = slog.Scope("Actions")
+
+// Errors returned by Trigger
+var (
+	ErrUnknownAction = errors.New("unknown action")
+	ErrPermission    = errors.New("not allowed")
+)
+
+// Caller is who triggered an action
+type Caller struct {
+	User  string
+	Level permissions.Level
+	// Reward is set when the action was redeemed with channel points, which skips the permission
+	Reward bool
+	// Allowed is set when the permission was already checked, like by the chat command rule
+	Allowed bool
+	// Done, if set, is called in background with the result once the action ran
+	Done func(err error)
+}
+
+// Action is a parsed config.ActionConfig
+type Action struct {
+	Name       string
+	Command    string
+	Reward     string
+	Permission permissions.Level
+	Cooldown   time.Duration
+	steps      []step
+}
+
+// Registry has the configured actions and runs them
+type Registry struct {
+	sync.Mutex
+	actions   map[string]*Action
+	limiter   *cooldown.Limiter
+	publisher Publisher
+	running   sync.WaitGroup
+}
+
+// Load parses the configured actions. Invalid actions are skipped and returned as errors
+func Load(cfg config.GeneralConfig) (*Registry, []error) {
+	r := &Registry{
+		actions:   map[string]*Action{},
+		limiter:   cooldown.NewLimiter(),
+		publisher: &mqttPublisher{host: cfg.Host, user: cfg.User, pass: cfg.Pass},
+	}
+
+	var errs []error
+	for i, ac := range withLegacyLight(cfg) {
+		a, err := parseAction(ac, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("action %d (%s): %s", i+1, ac.Name, err))
+			continue
+		}
+		if _, ok := r.actions[a.Name]; ok {
+			errs = append(errs, fmt.Errorf("action %d: duplicated name %q", i+1, a.Name))
+			continue
+		}
+		r.actions[a.Name] = a
+	}
+
+	return r, errs
+}
+
+func parseAction(ac config.ActionConfig, cfg config.GeneralConfig) (*Action, error) {
+	a := &Action{
+		Name:    strings.ToLower(strings.TrimSpace(ac.Name)),
+		Command: strings.ToLower(strings.TrimSpace(ac.Command)),
+		Reward:  ac.Reward,
+	}
+	if a.Name == "" {
+		return nil, fmt.Errorf("action without name")
+	}
+
+	var err error
+	if a.Permission, err = permissions.ParseLevel(ac.Permission); err != nil {
+		return nil, err
+	}
+	if ac.Cooldown != "" {
+		if a.Cooldown, err = time.ParseDuration(ac.Cooldown); err != nil || a.Cooldown < 0 {
+			return nil, fmt.Errorf("invalid cooldown %q", ac.Cooldown)
+		}
+	}
+	if len(ac.Steps) == 0 {
+		return nil, fmt.Errorf("action without steps")
+	}
+	for i, sc := range ac.Steps {
+		s, err := parseStep(sc, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+		a.steps = append(a.steps, s)
+	}
+
+	return a, nil
+}
+
+// withLegacyLight adds the room light button bound to LightRewardTitle, unless an action already uses that reward
+func withLegacyLight(cfg config.GeneralConfig) []config.ActionConfig {
+	list := cfg.Actions
+	if cfg.LightRewardTitle == "" {
+		return list
+	}
+	for _, a := range list {
+		if a.Reward == cfg.LightRewardTitle || strings.EqualFold(a.Name, "light") {
+			return list
+		}
+	}
+
+	return append(list, config.ActionConfig{
+		Name:       "light",
+		Reward:     cfg.LightRewardTitle,
+		Permission: "subscriber",
+		Steps: []config.ActionStep{
+			{Type: stepMQTT, Topic: "ENTRADA/036", Payload: "1"},
+			// Simulate button hit
+			{Type: stepMQTT, Topic: "ENTRADA/036", Payload: "0", Delay: "10ms"},
+		},
+	})
+}
+
+// SetPublisher replaces the MQTT connection of the mqtt steps
+func (r *Registry) SetPublisher(p Publisher) {
+	r.Lock()
+	defer r.Unlock()
+	r.publisher = p
+}
+
+// SetLimiter replaces the cooldown limiter, to share it with other users of the cooldowns
+func (r *Registry) SetLimiter(limiter *cooldown.Limiter) {
+	r.Lock()
+	defer r.Unlock()
+	r.limiter = limiter
+}
+
+// SetNow replaces the time source of the cooldowns
+func (r *Registry) SetNow(now func() time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	r.limiter.SetNow(now)
+}
+
+// Names returns the action names
+func (r *Registry) Names() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	names := make([]string, 0, len(r.actions))
+	for name := range r.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns the action called name
+func (r *Registry) Get(name string) (*Action, bool) {
+	r.Lock()
+	defer r.Unlock()
+	a, ok := r.actions[strings.ToLower(name)]
+	return a, ok
+}
+
+// ForCommand returns the action bound to the chat command, like "!light"
+func (r *Registry) ForCommand(command string) (*Action, bool) {
+	command = strings.ToLower(command)
+
+	r.Lock()
+	defer r.Unlock()
+	for _, a := range r.actions {
+		if a.Command != "" && a.Command == command {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// ForReward returns the action bound to the reward title
+func (r *Registry) ForReward(title string) (*Action, bool) {
+	r.Lock()
+	defer r.Unlock()
+	for _, a := range r.actions {
+		if a.Reward != "" && a.Reward == title {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// Trigger checks the permission and cooldown of the action and runs it in background.
+// A cooling down action returns a *cooldown.Error
+func (r *Registry) Trigger(name string, caller Caller) error {
+	r.Lock()
+	defer r.Unlock()
+
+	a, ok := r.actions[strings.ToLower(name)]
+	if !ok {
+		return ErrUnknownAction
+	}
+
+	if !caller.Reward && !caller.Allowed && caller.Level < a.Permission {
+		return ErrPermission
+	}
+
+	if err := r.limiter.Allow("action\x00"+a.Name, "", cooldown.Rules{Key: cooldown.Limit{Every: a.Cooldown}}); err != nil {
+		return err
+	}
+
+	log.Info("%s triggered action %s", caller.User, a.Name)
+	publisher := r.publisher
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		err := a.run(publisher)
+		if err != nil {
+			log.Error("Error running action %s: %s", a.Name, err)
+		}
+		if caller.Done != nil {
+			caller.Done(err)
+		}
+	}()
+
+	return nil
+}
+
+// Wait blocks until the triggered actions finish
+func (r *Registry) Wait() {
+	r.running.Wait()
+}
+
+func (a *Action) run(publisher Publisher) error {
+	for i, s := range a.steps {
+		if s.delay > 0 {
+			time.Sleep(s.delay)
+		}
+		log.Debug("Action %s step %d: %s", a.Name, i+1, s)
+		if err := s.run(publisher); err != nil {
+			return fmt.Errorf("step %d (%s): %s", i+1, s, err)
+		}
+	}
+	return nil
+}
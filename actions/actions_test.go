@@ -0,0 +1,299 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/permissions"
+)
+
+// fakePublisher records the mqtt steps
+type fakePublisher struct {
+	sync.Mutex
+	published []string
+	err       error
+}
+
+func (p *fakePublisher) Publish(topic string, payload []byte) error {
+	p.Lock()
+	defer p.Unlock()
+	p.published = append(p.published, topic+"="+string(payload))
+	return p.err
+}
+
+func (p *fakePublisher) Published() []string {
+	p.Lock()
+	defer p.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+// testRegistry loads the actions with a fake publisher
+func testRegistry(t *testing.T, cfg config.GeneralConfig) (*Registry, *fakePublisher) {
+	t.Helper()
+	r, errs := Load(cfg)
+	for _, err := range errs {
+		t.Fatalf("Load: %s", err)
+	}
+	p := &fakePublisher{}
+	r.SetPublisher(p)
+	return r, p
+}
+
+// trigger runs the action and waits its result
+func trigger(t *testing.T, r *Registry, name string, caller Caller) error {
+	t.Helper()
+	result := make(chan error, 1)
+	caller.Done = func(err error) {
+		result <- err
+	}
+	if err := r.Trigger(name, caller); err != nil {
+		t.Fatalf("Trigger(%s): %s", name, err)
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting action %s", name)
+	}
+	return nil
+}
+
+func TestParseStep(t *testing.T) {
+	cfg := config.GeneralConfig{HomeAssistantUrl: "http://ha:8123/", HomeAssistantToken: "secret"}
+
+	invalid := []config.ActionStep{
+		{Type: "mqtt"},
+		{Type: "mqtt", Topic: "a", Delay: "soon"},
+		{Type: "mqtt", Topic: "a", Delay: "-1s"},
+		{Type: "http"},
+		{Type: "homeassistant", Service: "light"},
+		{Type: "homeassistant", Service: ".toggle"},
+		{Type: "homeassistant", Service: "light."},
+		{Type: "telnet"},
+	}
+	for _, sc := range invalid {
+		if _, err := parseStep(sc, cfg); err == nil {
+			t.Errorf("parseStep(%+v) did not fail", sc)
+		}
+	}
+	if _, err := parseStep(config.ActionStep{Type: "homeassistant", Service: "light.toggle"}, config.GeneralConfig{}); err == nil {
+		t.Errorf("homeassistant step without HomeAssistantUrl did not fail")
+	}
+
+	s, err := parseStep(config.ActionStep{Type: " MQTT ", Topic: "ENTRADA/036", Payload: "1", Delay: "10ms"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.kind != stepMQTT || s.topic != "ENTRADA/036" || s.payload != "1" || s.delay != 10*time.Millisecond {
+		t.Errorf("mqtt step = %+v", s)
+	}
+
+	s, err = parseStep(config.ActionStep{Type: "http", Url: "http://a/b"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.method != http.MethodPost {
+		t.Errorf("http step method = %s, want the POST default", s.method)
+	}
+
+	s, err = parseStep(config.ActionStep{
+		Type:     "homeassistant",
+		Service:  "light.turn_on",
+		EntityId: "light.office",
+		Data:     map[string]string{"color_name": "red"},
+	}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.method != http.MethodPost || s.url != "http://ha:8123/api/services/light/turn_on" {
+		t.Errorf("homeassistant request = %s %s", s.method, s.url)
+	}
+	if s.headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the token", s.headers["Authorization"])
+	}
+	var data map[string]string
+	if err := json.Unmarshal([]byte(s.body), &data); err != nil {
+		t.Fatalf("homeassistant body %q: %s", s.body, err)
+	}
+	if want := map[string]string{"entity_id": "light.office", "color_name": "red"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("homeassistant data = %v, want %v", data, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	r, errs := Load(config.GeneralConfig{Actions: []config.ActionConfig{
+		{Name: "ok", Steps: []config.ActionStep{{Type: "mqtt", Topic: "a"}}},
+		{Name: "OK", Steps: []config.ActionStep{{Type: "mqtt", Topic: "b"}}},
+		{Steps: []config.ActionStep{{Type: "mqtt", Topic: "a"}}},
+		{Name: "nosteps"},
+		{Name: "perm", Permission: "king", Steps: []config.ActionStep{{Type: "mqtt", Topic: "a"}}},
+		{Name: "cool", Cooldown: "-5s", Steps: []config.ActionStep{{Type: "mqtt", Topic: "a"}}},
+		{Name: "step", Steps: []config.ActionStep{{Type: "mqtt", Topic: "a"}, {Type: "http"}}},
+	}})
+	if len(errs) != 6 {
+		t.Errorf("Load errors = %v, want 6", errs)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"ok"}) {
+		t.Errorf("Names() = %v, want [ok]", names)
+	}
+}
+
+func TestLegacyLight(t *testing.T) {
+	r, p := testRegistry(t, config.GeneralConfig{LightRewardTitle: "Light"})
+
+	a, ok := r.ForReward("Light")
+	if !ok || a.Name != "light" {
+		t.Fatalf("ForReward(Light) = %v, want the legacy light action", a)
+	}
+	if err := trigger(t, r, "light", Caller{User: "bob", Reward: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Published(), []string{"ENTRADA/036=1", "ENTRADA/036=0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+
+	// A configured action for the reward replaces it
+	r, _ = testRegistry(t, config.GeneralConfig{
+		LightRewardTitle: "Light",
+		Actions: []config.ActionConfig{
+			{Name: "lamp", Reward: "Light", Steps: []config.ActionStep{{Type: "mqtt", Topic: "lamp"}}},
+		},
+	})
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"lamp"}) {
+		t.Errorf("Names() = %v, want only the configured action", names)
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	r, p := testRegistry(t, config.GeneralConfig{Actions: []config.ActionConfig{
+		{
+			Name:       "Door",
+			Command:    "!door",
+			Permission: "moderator",
+			Cooldown:   "30s",
+			Steps: []config.ActionStep{
+				{Type: "mqtt", Topic: "door", Payload: "open"},
+				{Type: "mqtt", Topic: "door", Payload: "close", Delay: "1ms"},
+			},
+		},
+	}})
+	now := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	r.SetNow(func() time.Time { return now })
+
+	if a, ok := r.ForCommand("!DOOR"); !ok || a.Name != "door" {
+		t.Errorf("ForCommand(!DOOR) = %v", a)
+	}
+	if err := r.Trigger("window", Caller{}); err != ErrUnknownAction {
+		t.Errorf("unknown action = %v, want ErrUnknownAction", err)
+	}
+	if err := r.Trigger("door", Caller{User: "bob", Level: permissions.Subscriber}); err != ErrPermission {
+		t.Errorf("subscriber = %v, want ErrPermission", err)
+	}
+	if len(p.Published()) != 0 {
+		t.Errorf("denied action published %q", p.Published())
+	}
+
+	if err := trigger(t, r, "door", Caller{User: "mod", Level: permissions.Moderator}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Published(), []string{"door=open", "door=close"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+
+	err := r.Trigger("door", Caller{User: "mod", Level: permissions.Moderator})
+	if e, ok := err.(*cooldown.Error); !ok || e.Remaining != 30*time.Second {
+		t.Errorf("Trigger in cooldown = %v, want a 30s *cooldown.Error", err)
+	}
+
+	// Rewards and already allowed callers skip the permission, not the cooldown
+	now = now.Add(30 * time.Second)
+	if err := trigger(t, r, "door", Caller{User: "bob", Reward: true}); err != nil {
+		t.Errorf("reward: %s", err)
+	}
+	now = now.Add(30 * time.Second)
+	if err := trigger(t, r, "door", Caller{User: "bob", Allowed: true}); err != nil {
+		t.Errorf("allowed: %s", err)
+	}
+	if err := r.Trigger("door", Caller{User: "bob", Reward: true}); err == nil {
+		t.Errorf("reward in cooldown was allowed")
+	}
+}
+
+func TestTriggerStepError(t *testing.T) {
+	r, p := testRegistry(t, config.GeneralConfig{Actions: []config.ActionConfig{
+		{Name: "door", Steps: []config.ActionStep{
+			{Type: "mqtt", Topic: "door", Payload: "open"},
+			{Type: "mqtt", Topic: "door", Payload: "close"},
+		}},
+	}})
+	p.err = errors.New("broker down")
+
+	err := trigger(t, r, "door", Caller{User: "bob"})
+	if err == nil || !strings.Contains(err.Error(), "step 1") || !strings.Contains(err.Error(), "broker down") {
+		t.Errorf("Done error = %v, want the step 1 error", err)
+	}
+	if len(p.Published()) != 1 {
+		t.Errorf("published %q, want the steps to stop on the error", p.Published())
+	}
+}
+
+func TestHTTPSteps(t *testing.T) {
+	type request struct {
+		method, path, auth, custom, body string
+	}
+	requests := make(chan request, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{r.Method, r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("X-Custom"), string(body)}
+		if r.URL.Path == "/fail" {
+			http.Error(w, "entity not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r, _ := testRegistry(t, config.GeneralConfig{
+		HomeAssistantUrl:   server.URL,
+		HomeAssistantToken: "secret",
+		Actions: []config.ActionConfig{
+			{Name: "hook", Steps: []config.ActionStep{
+				{Type: "http", Method: "put", Url: server.URL + "/hook", Body: "hello", Headers: map[string]string{"X-Custom": "yes"}},
+			}},
+			{Name: "lamp", Steps: []config.ActionStep{
+				{Type: "homeassistant", Service: "light.toggle", EntityId: "light.office"},
+			}},
+			{Name: "fail", Steps: []config.ActionStep{
+				{Type: "http", Url: server.URL + "/fail"},
+			}},
+		},
+	})
+
+	if err := trigger(t, r, "hook", Caller{}); err != nil {
+		t.Errorf("hook: %s", err)
+	}
+	if got, want := <-requests, (request{"PUT", "/hook", "", "yes", "hello"}); got != want {
+		t.Errorf("hook request = %+v, want %+v", got, want)
+	}
+
+	if err := trigger(t, r, "lamp", Caller{}); err != nil {
+		t.Errorf("lamp: %s", err)
+	}
+	if got, want := <-requests, (request{"POST", "/api/services/light/toggle", "Bearer secret", "", `{"entity_id":"light.office"}`}); got != want {
+		t.Errorf("homeassistant request = %+v, want %+v", got, want)
+	}
+
+	err := trigger(t, r, "fail", Caller{})
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "entity not found") {
+		t.Errorf("fail = %v, want the 404 status and body", err)
+	}
+	<-requests
+}
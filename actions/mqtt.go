@@ -0,0 +1,64 @@
+package actions
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+)
+
+// Publisher sends the mqtt steps
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+// mqttPublisher connects to the broker on the first publish
+type mqttPublisher struct {
+	sync.Mutex
+	host string
+	user string
+	pass string
+	mq   mqtt.Client
+}
+
+func (p *mqttPublisher) client() (mqtt.Client, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.mq != nil {
+		return p.mq, nil
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(fmt.Sprintf("tcp://%s:1883", p.host))
+	opts.SetUsername(p.user)
+	opts.SetPassword(p.pass)
+	opts.SetAutoReconnect(true)
+
+	mq := mqtt.NewClient(opts)
+	log.Debug("Connecting to MQTT at %s", p.host)
+	tkn := mq.Connect()
+	if !tkn.WaitTimeout(time.Second * 5) {
+		return nil, fmt.Errorf("timeout connecting to MQTT at %s", p.host)
+	}
+	if tkn.Error() != nil {
+		return nil, fmt.Errorf("cannot connect to MQTT at %s: %s", p.host, tkn.Error())
+	}
+
+	p.mq = mq
+	return mq, nil
+}
+
+func (p *mqttPublisher) Publish(topic string, payload []byte) error {
+	mq, err := p.client()
+	if err != nil {
+		return err
+	}
+
+	tkn := mq.Publish(topic, 0, false, payload)
+	if !tkn.WaitTimeout(time.Second * 5) {
+		return fmt.Errorf("timeout publishing to %s", topic)
+	}
+	return tkn.Error()
+}
@@ -0,0 +1,139 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+)
+
+// Step types
+const (
+	stepMQTT          = "mqtt"
+	stepHTTP          = "http"
+	stepHomeAssistant = "homeassistant"
+)
+
+var httpClient = &http.Client{Timeout: time.Second * 5}
+
+// step is a parsed config.ActionStep
+type step struct {
+	kind    string
+	delay   time.Duration
+	topic   string
+	payload string
+	method  string
+	url     string
+	body    string
+	headers map[string]string
+}
+
+func parseStep(sc config.ActionStep, cfg config.GeneralConfig) (step, error) {
+	s := step{
+		kind: strings.ToLower(strings.TrimSpace(sc.Type)),
+	}
+
+	if sc.Delay != "" {
+		d, err := time.ParseDuration(sc.Delay)
+		if err != nil || d < 0 {
+			return s, fmt.Errorf("invalid delay %q", sc.Delay)
+		}
+		s.delay = d
+	}
+
+	switch s.kind {
+	case stepMQTT:
+		if sc.Topic == "" {
+			return s, fmt.Errorf("mqtt step without topic")
+		}
+		s.topic = sc.Topic
+		s.payload = sc.Payload
+	case stepHTTP:
+		if sc.Url == "" {
+			return s, fmt.Errorf("http step without url")
+		}
+		s.method = strings.ToUpper(sc.Method)
+		if s.method == "" {
+			s.method = http.MethodPost
+		}
+		s.url = sc.Url
+		s.body = sc.Body
+		s.headers = sc.Headers
+	case stepHomeAssistant:
+		return parseHomeAssistant(s, sc, cfg)
+	default:
+		return s, fmt.Errorf("unknown step type %q", sc.Type)
+	}
+
+	return s, nil
+}
+
+// parseHomeAssistant turns a service call into a request to the Home Assistant REST API
+func parseHomeAssistant(s step, sc config.ActionStep, cfg config.GeneralConfig) (step, error) {
+	if cfg.HomeAssistantUrl == "" {
+		return s, fmt.Errorf("homeassistant step without HomeAssistantUrl")
+	}
+
+	service := strings.SplitN(sc.Service, ".", 2)
+	if len(service) != 2 || service[0] == "" || service[1] == "" {
+		return s, fmt.Errorf("invalid service %q, expected domain.service", sc.Service)
+	}
+
+	data := map[string]string{}
+	for k, v := range sc.Data {
+		data[k] = v
+	}
+	if sc.EntityId != "" {
+		data["entity_id"] = sc.EntityId
+	}
+	body, _ := json.Marshal(data)
+
+	s.method = http.MethodPost
+	s.url = fmt.Sprintf("%s/api/services/%s/%s", strings.TrimRight(cfg.HomeAssistantUrl, "/"), service[0], service[1])
+	s.body = string(body)
+	s.headers = map[string]string{
+		"Authorization": "Bearer " + cfg.HomeAssistantToken,
+		"Content-Type":  "application/json",
+	}
+
+	return s, nil
+}
+
+func (s step) String() string {
+	if s.kind == stepMQTT {
+		return fmt.Sprintf("mqtt %s=%q", s.topic, s.payload)
+	}
+	return fmt.Sprintf("%s %s %s", s.kind, s.method, s.url)
+}
+
+func (s step) run(publisher Publisher) error {
+	if s.kind == stepMQTT {
+		return publisher.Publish(s.topic, []byte(s.payload))
+	}
+
+	req, err := http.NewRequest(s.method, s.url, strings.NewReader(s.body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("status %s: %s", res.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
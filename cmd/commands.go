@@ -0,0 +1,482 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/commands"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/i18n"
+	"github.com/racerxdl/twitchled/openai"
+	"github.com/racerxdl/twitchled/permissions"
+	"github.com/racerxdl/twitchled/twitch"
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+	"github.com/racerxdl/twitchled/wimatrix"
+)
+
+var chatCommands *commands.Registry
+var permissionChecker *permissions.Checker
+
+// panelCooldown limits the commands that change the panels, that are on camera
+var panelCooldown = cooldown.Rules{
+	User:  cooldown.Limit{Every: 10 * time.Second, Burst: 3},
+	Group: panelGroup,
+}
+
+// chatCall is the chat message a command came from, passed as the call context
+type chatCall struct {
+	chat       *twitch.Chat
+	event      *twitch.MessageEventData
+	userPrefix string
+}
+
+func chatOf(call *commands.Call) *chatCall {
+	return call.Context.(*chatCall)
+}
+
+// builtinCommands are the commands handled by the bot itself
+func builtinCommands() []*commands.Command {
+	return []*commands.Command{
+		{
+			Name:    cmdHelp,
+			Aliases: []string{cmdCommands},
+			Help:    help("help_huebot", nil),
+			Handler: cmdListCommands,
+		},
+		{
+			Name:    cmdHelpCmd,
+			Args:    []commands.Arg{{Name: "command", Optional: true}},
+			Help:    help("help_hue", nil),
+			Handler: cmdCommandHelp(""),
+		},
+		{
+			Name:    cmdHelpEnglishCmd,
+			Args:    []commands.Arg{{Name: "command", Optional: true}},
+			Help:    help("help_huenglish", nil),
+			Handler: cmdCommandHelp(commands.LangEN),
+		},
+		{
+			Name:     cmdColor,
+			Cooldown: panelCooldown,
+			Args:     []commands.Arg{{Name: "color"}},
+			Target:   true,
+			Help:     help("help_color", nil),
+			Handler:  CmdColor,
+		},
+		{
+			Name:     cmdBgColor,
+			Cooldown: panelCooldown,
+			Args:     []commands.Arg{{Name: "color"}},
+			Target:   true,
+			Help:     help("help_bgcolor", nil),
+			Handler:  CmdBGColor,
+		},
+		{
+			Name:     cmdBright,
+			Cooldown: panelCooldown,
+			Args:     []commands.Arg{{Name: "value", Type: commands.Float}},
+			Target:   true,
+			Help:     help("help_bright", nil),
+			Handler:  CmdBright,
+		},
+		{
+			Name:     cmdBgBright,
+			Cooldown: panelCooldown,
+			Args:     []commands.Arg{{Name: "value", Type: commands.Float}},
+			Target:   true,
+			Help:     help("help_bgbright", nil),
+			Handler:  CmdBGBright,
+		},
+		{
+			Name:    cmdSource,
+			Help:    help("help_source", nil),
+			Handler: cmdShowSource,
+		},
+		{
+			// Subscriber only. The text goes through the panel moderation like the reward ones
+			Name:       cmdPanel,
+			Cooldown:   panelCooldown,
+			Args:       []commands.Arg{{Name: "message", Type: commands.Text}},
+			Target:     true,
+			Permission: permissions.Rule{Level: permissions.Subscriber},
+			Help:       help("help_painel", nil),
+			Handler:    CmdMessage,
+		},
+		{
+			Name:     cmdSpeed,
+			Cooldown: panelCooldown,
+			Args:     []commands.Arg{{Name: "speed", Type: commands.Int}},
+			Target:   true,
+			Help:     help("help_speed", nil),
+			Handler:  CmdSpeed,
+		},
+		{
+			Name:     cmdLang,
+			Args:     []commands.Arg{{Name: "language", Optional: true}},
+			Cooldown: langCooldown,
+			Help:     help("help_lang", nil),
+			Handler:  cmdSetLanguage,
+		},
+		{
+			Name:    cmdHere,
+			Hidden:  true,
+			Handler: func(call *commands.Call) error { return say(chatOf(call).chat, call.Caller.User, "here", nil) },
+		},
+
+		// Moderators and owner
+		{
+			Name:       cmdResetAI,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdResetHistory,
+		},
+		{
+			Name:       cmdStreamTitle,
+			Args:       []commands.Arg{{Name: "title", Type: commands.Text, Optional: true}},
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdSetStreamTitle,
+		},
+		{
+			Name:       cmdListClip,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdListClips,
+		},
+		{
+			Name:       fakeSub,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdFakeSub,
+		},
+		{
+			Name:       fakeBits,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdFakeBits,
+		},
+		{
+			Name:       fakeFollow,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdFakeFollow,
+		},
+		{
+			Name:       cmdMode,
+			Args:       []commands.Arg{{Name: "mode"}},
+			Target:     true,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    CmdPanelMode,
+		},
+		{
+			Name:       cmdPending,
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdListPending,
+		},
+		{
+			Name:       cmdApprove,
+			Args:       []commands.Arg{{Name: "id", Type: commands.Int}},
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdApprovePending,
+		},
+		{
+			Name:       cmdReject,
+			Args:       []commands.Arg{{Name: "id", Type: commands.Int}, {Name: "reason", Type: commands.Text, Optional: true}},
+			Hidden:     true,
+			Permission: permissions.Rule{Level: permissions.Moderator},
+			Handler:    cmdRejectPending,
+		},
+	}
+}
+
+// actionCommands are the commands bound to home automation actions
+func actionCommands() []*commands.Command {
+	var list []*commands.Command
+	for _, name := range homeActions.Names() {
+		a, _ := homeActions.Get(name)
+		if a.Command == "" {
+			continue
+		}
+		list = append(list, &commands.Command{
+			Name:       a.Command,
+			Permission: permissions.Rule{Level: a.Permission},
+			Help:       help("help_action", i18n.Args{"command": a.Command, "action": a.Name}),
+			Handler: func(call *commands.Call) error {
+				c := chatOf(call)
+				CmdAction(c.chat, c.userPrefix, c.event, a)
+				return nil
+			},
+		})
+	}
+	return list
+}
+
+// loadPermissions builds the permission checker. Follows are looked up on the channel
+func loadPermissions(cfg config.GeneralConfig, channelId string) *permissions.Checker {
+	checker := permissions.NewChecker(func(userId string) (bool, error) {
+		return twitch.IsFollower(channelId, userId)
+	})
+	checker.SetDeny(cfg.Permissions.Deny)
+
+	if cfg.Permissions.FollowerCacheTime != "" {
+		ttl, err := time.ParseDuration(cfg.Permissions.FollowerCacheTime)
+		if err != nil {
+			log.Error("Invalid FollowerCacheTime %q. The default will be used", cfg.Permissions.FollowerCacheTime)
+		} else {
+			checker.SetCacheTime(ttl)
+		}
+	}
+
+	return checker
+}
+
+// loadCommands builds the chat command registry and applies the permission and cooldown overrides of the config
+func loadCommands(cfg config.GeneralConfig, checker *permissions.Checker, limiter *cooldown.Limiter) *commands.Registry {
+	r := commands.NewRegistry()
+	r.SetChecker(checker)
+	r.SetLimiter(limiter)
+	r.SetExempt(cooldownExempt(cfg.Cooldowns.Exempt))
+	r.SetTargets(func(target string) bool {
+		return panels != nil && panels.IsTarget(target)
+	})
+
+	if err := r.Register(builtinCommands()...); err != nil {
+		log.Fatal("Invalid built-in commands: %s", err)
+	}
+	for _, c := range actionCommands() {
+		if err := r.Register(c); err != nil {
+			log.Error("%s. The action command will be skipped", err)
+		}
+	}
+	if customStore != nil {
+		registerCustomCommands(r)
+	}
+
+	for name, pr := range cfg.Permissions.Commands {
+		rule, err := permissions.ParseRule(pr)
+		if err == nil {
+			err = r.SetPermission(name, rule)
+		}
+		if err != nil {
+			log.Error("Permission of %s: %s. The default will be used", name, err)
+		}
+	}
+
+	for name, cr := range cfg.Cooldowns.Commands {
+		rules, err := cooldown.ParseRules(cr)
+		if err == nil {
+			err = r.SetCooldown(name, rules)
+		}
+		if err != nil {
+			log.Error("Cooldown of %s: %s. The default will be used", name, err)
+		}
+	}
+
+	return r
+}
+
+// runCommand runs the command in the message. Returns false if it is not a command
+func runCommand(chat *twitch.Chat, userPrefix string, event *twitch.MessageEventData) bool {
+	caller := commands.Caller{User: event.Username, Roles: permissions.FromMessage(event)}
+	c, err := chatCommands.Execute(event.Message, caller, &chatCall{chat: chat, event: event, userPrefix: userPrefix})
+	if c == nil {
+		return false
+	}
+
+	args := i18n.Args{"prefix": userPrefix, "user": event.Username, "command": c.Name}
+	switch e := err.(type) {
+	case nil:
+	case *permissions.Denied:
+		if e.Reason == permissions.ReasonDenied {
+			_ = say(chat, event.Username, "command_denied", args)
+			return true
+		}
+		for _, lang := range replyLanguages(chat, event.Username) {
+			args["rule"] = describeRule(e.Rule, lang)
+			_ = chat.SendMessage(i18n.T(lang, "command_restricted", args))
+		}
+	case *commands.ArgError:
+		args["error"] = e
+		args["usage"] = c.Usage()
+		_ = say(chat, event.Username, "command_usage", args)
+	case *cooldown.Error:
+		args["remaining"] = e.Remaining.Round(time.Second)
+		if e.Scope == cooldown.ScopeLockout {
+			if e.Started {
+				_ = say(chat, event.Username, "command_lockout", args)
+			}
+			return true
+		}
+		_ = say(chat, event.Username, "command_cooldown", args)
+	default:
+		log.Error("Error running %s: %s", c.Name, err)
+	}
+
+	return true
+}
+
+// levelKeys are the message keys of the permission levels
+var levelKeys = map[permissions.Level]string{
+	permissions.Everyone:    "level_everyone",
+	permissions.Follower:    "level_follower",
+	permissions.Subscriber:  "level_subscriber",
+	permissions.VIP:         "level_vip",
+	permissions.Moderator:   "level_moderator",
+	permissions.Broadcaster: "level_broadcaster",
+}
+
+// describeRule explains who can use a command in lang, like "subs tier 2 com 6+ meses"
+func describeRule(r permissions.Rule, lang string) string {
+	s := i18n.T(lang, levelKeys[r.Level], nil)
+	if r.Level == permissions.Subscriber && r.MinTier > 1 {
+		s = i18n.T(lang, "rule_tier", i18n.Args{"rule": s, "tier": r.MinTier})
+	}
+	if r.Level == permissions.Subscriber && r.MinMonths > 0 {
+		s = i18n.T(lang, "rule_months", i18n.Args{"rule": s, "months": r.MinMonths})
+	}
+	return s
+}
+
+func cmdListCommands(call *commands.Call) error {
+	c := chatOf(call)
+	list := commands.List(chatCommands.Visible(call.Caller.Roles))
+	return say(c.chat, call.Caller.User, "command_list", i18n.Args{"prefix": c.userPrefix, "user": call.Caller.User, "commands": list})
+}
+
+// cmdCommandHelp returns the handler of !hue and !huenglish. An empty lang is the first reply language of the caller
+func cmdCommandHelp(lang string) commands.Handler {
+	return func(call *commands.Call) error {
+		c := chatOf(call)
+		name := strings.TrimLeft(call.String("command"), "!")
+		if name == "" {
+			name = call.Name
+		}
+		lang := lang
+		if lang == "" {
+			lang = replyLanguages(c.chat, call.Caller.User)[0]
+		}
+
+		cmd, ok := chatCommands.Get(name)
+		if !ok || cmd.Hidden {
+			return c.chat.SendMessage(i18n.T(lang, "command_unknown", i18n.Args{"prefix": c.userPrefix, "user": call.Caller.User, "command": name}))
+		}
+
+		text := strings.Replace(cmd.HelpText(lang), "{panels}", panelTargets(), -1)
+		return c.chat.SendMessage(i18n.T(lang, "command_help", i18n.Args{"prefix": c.userPrefix, "user": call.Caller.User, "help": text}))
+	}
+}
+
+func cmdShowSource(call *commands.Call) error {
+	c := chatOf(call)
+	return say(c.chat, call.Caller.User, "source", i18n.Args{"prefix": c.userPrefix, "user": call.Caller.User})
+}
+
+func cmdResetHistory(call *commands.Call) error {
+	history = nil
+	return say(chatOf(call).chat, call.Caller.User, "ai_reset", nil)
+}
+
+func cmdSetStreamTitle(call *commands.Call) error {
+	chat := chatOf(call).chat
+	if title := call.String("title"); title != "" {
+		openai.SetLivestreamTitle(title)
+		return say(chat, call.Caller.User, "stream_title_set", i18n.Args{"title": title})
+	}
+	return say(chat, call.Caller.User, "stream_title_current", i18n.Args{"title": openai.GetLivestreamTitle()})
+}
+
+func cmdListClips(call *commands.Call) error {
+	chat := chatOf(call).chat
+	clips, _ := twitch.GetClips("44043625", time.Now().Add(time.Minute*-10))
+	_ = say(chat, call.Caller.User, "clips_list", nil)
+	for _, v := range clips {
+		_ = chat.SendMessage(v)
+	}
+	return nil
+}
+
+func cmdFakeSub(call *commands.Call) error {
+	chat := chatOf(call).chat
+	_ = say(chat, call.Caller.User, "fake_sub", nil)
+	e := twitch.MakeSubscribeEventData("FAKE", twitchdata.ChannelSubscribeMessageData{
+		UserName:    "JohnCena",
+		DisplayName: "JohnCena",
+		SubMessage: twitchdata.ChannelSubscriberMessage{
+			Message: "MY NAME IS JOHN CENA!!!",
+		},
+		StreakMonths:     1000,
+		CumulativeMonths: 1000,
+		ChatMessage:      "MY NAME IS JOHN CENA!!!",
+	})
+	OnSub(chat, e.(*twitch.SubscribeEventData))
+	return nil
+}
+
+func cmdFakeBits(call *commands.Call) error {
+	chat := chatOf(call).chat
+	_ = say(chat, call.Caller.User, "fake_bits", nil)
+	e := twitch.MakeBitsV2EventData("FAKE", twitchdata.BitEventsV2{
+		IsAnonymous: false,
+		Data: twitchdata.BitEventsData{
+			UserName:      "JohnCena",
+			BitsUsed:      1000000,
+			TotalBitsUsed: 1000000,
+			ChatMessage:   "MY NAME IS JOHN CENA!!!",
+		},
+	})
+	OnBits(chat, e.(*twitch.BitsV2EventData))
+	return nil
+}
+
+func cmdFakeFollow(call *commands.Call) error {
+	chat := chatOf(call).chat
+	_ = say(chat, call.Caller.User, "fake_follow", nil)
+	e := twitch.MakeFollowEventData("FAKE", "JohnScena", "1000000")
+	OnFollow(chat, e.(*twitch.FollowEventData))
+	return nil
+}
+
+// CmdPanelMode changes the display mode of the panels
+func CmdPanelMode(call *commands.Call) error {
+	chat := chatOf(call).chat
+	data := call.String("mode")
+
+	user := call.Caller.User
+
+	printValidModes := func() {
+		var modes []string
+		for _, v := range wimatrix.Modes {
+			modes = append(modes, fmt.Sprintf("%d: %s", int(v), v.String()))
+		}
+		_ = say(chat, user, "mode_list", i18n.Args{"modes": strings.Join(modes, " ")})
+	}
+
+	v, err := strconv.Atoi(data)
+	if err != nil {
+		_ = say(chat, user, "mode_invalid", i18n.Args{"mode": data})
+		printValidModes()
+		return nil
+	}
+	ok := false
+	for _, mode := range wimatrix.Modes {
+		if v == int(mode) {
+			ok = true
+			break
+		}
+	}
+
+	if !ok {
+		_ = say(chat, user, "mode_invalid", i18n.Args{"mode": data})
+		printValidModes()
+		return nil
+	}
+
+	ev.Publish(wimatrix.Target(wimatrix.EvNewMode, call.Target), wimatrix.Mode(v))
+	return say(chat, user, "mode_set", i18n.Args{"mode": v, "name": wimatrix.Mode(v).String()})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/permissions"
+)
+
+// panelGroup is the cooldown group of the commands that change the panels
+const panelGroup = "panel"
+
+// cooldowns is shared by the chat commands, the actions and the AI, so spamming one of them
+// counts towards the lockout of all
+var cooldowns *cooldown.Limiter
+
+var aiCooldown = cooldown.Rules{
+	Key:  cooldown.Limit{Every: 5 * time.Second},
+	User: cooldown.Limit{Every: 30 * time.Second},
+}
+
+var javascriptoCooldown = cooldown.Rules{
+	Key: cooldown.Limit{Every: 10 * time.Minute},
+}
+
+// loadCooldowns builds the limiter and reads the AI and javascripto limits of the config
+func loadCooldowns(cfg config.GeneralConfig) *cooldown.Limiter {
+	c := cfg.Cooldowns
+	l := cooldown.NewLimiter()
+
+	l.SetGroup(panelGroup, cooldown.Limit{Every: 2 * time.Second, Burst: 5})
+	for name, cl := range c.Groups {
+		limit, err := cooldown.ParseLimit(cl)
+		if err != nil {
+			log.Error("Cooldown group %s: %s. It will be skipped", name, err)
+			continue
+		}
+		l.SetGroup(name, limit)
+	}
+
+	strikes, window, lockout := 5, time.Minute, 5*time.Minute
+	if c.Strikes != 0 {
+		strikes = c.Strikes
+	}
+	if d, ok := parseCooldownDuration("StrikeWindow", c.StrikeWindow); ok {
+		window = d
+	}
+	if d, ok := parseCooldownDuration("Lockout", c.Lockout); ok {
+		lockout = d
+	}
+	l.SetLockout(strikes, window, lockout)
+
+	if c.AI != (config.CooldownRule{}) {
+		if rules, err := cooldown.ParseRules(c.AI); err != nil {
+			log.Error("AI cooldown: %s. The default will be used", err)
+		} else {
+			aiCooldown = rules
+		}
+	}
+	if c.Javascripto != (config.CooldownRule{}) {
+		if rules, err := cooldown.ParseRules(c.Javascripto); err != nil {
+			log.Error("Javascripto cooldown: %s. The default will be used", err)
+		} else {
+			javascriptoCooldown = rules
+		}
+	}
+
+	return l
+}
+
+func parseCooldownDuration(field, value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Error("Invalid cooldown %s %q. The default will be used", field, value)
+		return 0, false
+	}
+	return d, true
+}
+
+// cooldownExempt parses the lowest level that skips the cooldowns
+func cooldownExempt(name string) permissions.Level {
+	switch strings.ToLower(name) {
+	case "":
+		return permissions.Subscriber
+	case "none":
+		return permissions.Broadcaster + 1
+	}
+
+	level, err := permissions.ParseLevel(name)
+	if err != nil {
+		log.Error("Invalid cooldown Exempt %q. Subscribers and above will be exempt", name)
+		return permissions.Subscriber
+	}
+	return level
+}
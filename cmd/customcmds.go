@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racerxdl/twitchled/commands"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/customcmd"
+	"github.com/racerxdl/twitchled/i18n"
+	"github.com/racerxdl/twitchled/permissions"
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+const (
+	cmdAddCmd   = "!addcmd"
+	cmdEditCmd  = "!editcmd"
+	cmdDelCmd   = "!delcmd"
+	cmdAddTimer = "!addtimer"
+	cmdDelTimer = "!deltimer"
+	cmdTimers   = "!timers"
+)
+
+var customStore *customcmd.Store
+var customTimers *customcmd.Scheduler
+
+// customCooldown keeps a text command from being repeated in a row
+var customCooldown = cooldown.Rules{
+	Key: cooldown.Limit{Every: 5 * time.Second},
+}
+
+// streamInfo is the state of the stream used by the ${uptime} and ${game} variables
+var streamInfo struct {
+	sync.Mutex
+	online    bool
+	startedAt time.Time
+	game      string
+}
+
+// openCustomStore opens the database of the custom commands and timers. They are disabled if it fails
+func openCustomStore() {
+	s, err := customcmd.Open(config.GetDatabaseFileName())
+	if err != nil {
+		log.Error("Cannot open %s: %s. Custom commands and timers are disabled", config.GetDatabaseFileName(), err)
+		return
+	}
+	customStore = s
+}
+
+// startCustomTimers builds the scheduler that posts the timers on chat
+func startCustomTimers(chat *twitch.Chat) {
+	if customStore == nil {
+		return
+	}
+	customTimers = customcmd.NewScheduler(customStore, func(text string) {
+		_ = chat.SendMessage(text)
+	}, func() customcmd.Vars {
+		return streamVars("")
+	})
+}
+
+// seedStreamInfo loads the stream state from Twitch at startup. The events only tell the changes
+func seedStreamInfo(channelId string) {
+	info, err := twitch.GetStreamInfo(channelId)
+	if err != nil {
+		log.Error("Cannot get the stream state: %s", err)
+		return
+	}
+	setStreamOnline(info.Online, info.StartedAt)
+	setStreamGame(info.Game)
+}
+
+func setStreamOnline(online bool, startedAt time.Time) {
+	streamInfo.Lock()
+	defer streamInfo.Unlock()
+	streamInfo.online = online
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+	streamInfo.startedAt = startedAt
+}
+
+func setStreamGame(game string) {
+	streamInfo.Lock()
+	defer streamInfo.Unlock()
+	streamInfo.game = game
+}
+
+func streamVars(user string) customcmd.Vars {
+	streamInfo.Lock()
+	defer streamInfo.Unlock()
+	v := customcmd.Vars{User: user, Game: streamInfo.game}
+	if streamInfo.online {
+		v.Uptime = time.Since(streamInfo.startedAt)
+	}
+	return v
+}
+
+// customAdminCommands are the moderator commands that manage the custom commands and timers
+func customAdminCommands() []*commands.Command {
+	mod := permissions.Rule{Level: permissions.Moderator}
+	return []*commands.Command{
+		{
+			Name:       cmdAddCmd,
+			Args:       []commands.Arg{{Name: "name"}, {Name: "response", Type: commands.Text}},
+			Permission: mod,
+			Help:       help("help_addcmd", nil),
+			Handler:    cmdAddCustom,
+		},
+		{
+			Name:       cmdEditCmd,
+			Args:       []commands.Arg{{Name: "name"}, {Name: "response", Type: commands.Text}},
+			Permission: mod,
+			Help:       help("help_editcmd", nil),
+			Handler:    cmdEditCustom,
+		},
+		{
+			Name:       cmdDelCmd,
+			Args:       []commands.Arg{{Name: "name"}},
+			Permission: mod,
+			Help:       help("help_delcmd", nil),
+			Handler:    cmdDeleteCustom,
+		},
+		{
+			Name:       cmdAddTimer,
+			Args:       []commands.Arg{{Name: "name"}, {Name: "minutes", Type: commands.Int}, {Name: "message", Type: commands.Text}},
+			Permission: mod,
+			Help:       help("help_addtimer", nil),
+			Handler:    cmdAddCustomTimer,
+		},
+		{
+			Name:       cmdDelTimer,
+			Args:       []commands.Arg{{Name: "name"}},
+			Permission: mod,
+			Help:       help("help_deltimer", nil),
+			Handler:    cmdDeleteTimer,
+		},
+		{
+			Name:       cmdTimers,
+			Permission: mod,
+			Help:       help("help_timers", nil),
+			Handler:    cmdListTimers,
+		},
+	}
+}
+
+// customCommand makes the chat command of a stored text command
+func customCommand(name string) *commands.Command {
+	return &commands.Command{
+		Name:     name,
+		Cooldown: customCooldown,
+		Help:     help("help_custom", i18n.Args{"command": name}),
+		Handler: func(call *commands.Call) error {
+			c, err := customStore.UseCommand(name)
+			if err != nil {
+				return err
+			}
+			v := streamVars(call.Caller.User)
+			v.Count = c.Count
+			return chatOf(call).chat.SendMessage(customcmd.Render(c.Response, v))
+		},
+	}
+}
+
+// registerCustomCommands adds the moderator commands and the stored text commands to r
+func registerCustomCommands(r *commands.Registry) {
+	if err := r.Register(customAdminCommands()...); err != nil {
+		log.Fatal("Invalid custom command commands: %s", err)
+	}
+
+	list, err := customStore.Commands()
+	if err != nil {
+		log.Error("Cannot read the custom commands: %s", err)
+	}
+	for _, c := range list {
+		if err := r.Register(customCommand(c.Name)); err != nil {
+			log.Error("%s. The custom command will be skipped", err)
+		}
+	}
+}
+
+// validCustomName tells if name, with the "!", can be used by a new command
+func validCustomName(name string) bool {
+	return len(name) >= 2 && !strings.ContainsAny(name[1:], "!@")
+}
+
+func cmdAddCustom(call *commands.Call) error {
+	chat := chatOf(call).chat
+	name := customcmd.NormalizeName(call.String("name"))
+	args := i18n.Args{"user": call.Caller.User, "command": name}
+	if !validCustomName(name) {
+		return say(chat, call.Caller.User, "custom_invalid_name", args)
+	}
+	if _, ok := chatCommands.Get(name); ok {
+		if _, err := customStore.GetCommand(name); err == nil {
+			return say(chat, call.Caller.User, "custom_exists", args)
+		}
+		return say(chat, call.Caller.User, "custom_builtin", args)
+	}
+
+	c := customcmd.Command{
+		Name:      name,
+		Response:  call.String("response"),
+		CreatedBy: call.Caller.User,
+		UpdatedBy: call.Caller.User,
+		UpdatedAt: time.Now(),
+	}
+	if err := customStore.SaveCommand(c); err != nil {
+		return err
+	}
+	if err := chatCommands.Register(customCommand(name)); err != nil {
+		return err
+	}
+
+	log.Info("%s added the command %s", call.Caller.User, name)
+	return say(chat, call.Caller.User, "custom_added", args)
+}
+
+func cmdEditCustom(call *commands.Call) error {
+	chat := chatOf(call).chat
+	name := customcmd.NormalizeName(call.String("name"))
+	args := i18n.Args{"user": call.Caller.User, "command": name}
+	c, err := customStore.GetCommand(name)
+	if err == customcmd.ErrNotFound {
+		return say(chat, call.Caller.User, "custom_not_found", args)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Response = call.String("response")
+	c.UpdatedBy = call.Caller.User
+	c.UpdatedAt = time.Now()
+	if err := customStore.SaveCommand(c); err != nil {
+		return err
+	}
+
+	log.Info("%s changed the command %s", call.Caller.User, c.Name)
+	return say(chat, call.Caller.User, "custom_changed", args)
+}
+
+func cmdDeleteCustom(call *commands.Call) error {
+	chat := chatOf(call).chat
+	name := customcmd.NormalizeName(call.String("name"))
+	args := i18n.Args{"user": call.Caller.User, "command": name}
+	err := customStore.DeleteCommand(name)
+	if err == customcmd.ErrNotFound {
+		return say(chat, call.Caller.User, "custom_not_found", args)
+	}
+	if err != nil {
+		return err
+	}
+	chatCommands.Unregister(name)
+
+	log.Info("%s deleted the command %s", call.Caller.User, name)
+	return say(chat, call.Caller.User, "custom_deleted", args)
+}
+
+func cmdAddCustomTimer(call *commands.Call) error {
+	chat := chatOf(call).chat
+	minutes := call.Int("minutes")
+	args := i18n.Args{"user": call.Caller.User, "timer": strings.ToLower(call.String("name")), "minutes": minutes}
+	if minutes < 1 {
+		return say(chat, call.Caller.User, "timer_min_interval", args)
+	}
+
+	t := customcmd.Timer{
+		Name:      call.String("name"),
+		Message:   call.String("message"),
+		Interval:  time.Duration(minutes) * time.Minute,
+		CreatedBy: call.Caller.User,
+		UpdatedAt: time.Now(),
+	}
+	if err := customStore.SaveTimer(t); err != nil {
+		return err
+	}
+
+	log.Info("%s set the timer %s every %d minutes", call.Caller.User, t.Name, minutes)
+	return say(chat, call.Caller.User, "timer_set", args)
+}
+
+func cmdDeleteTimer(call *commands.Call) error {
+	chat := chatOf(call).chat
+	name := strings.ToLower(call.String("name"))
+	args := i18n.Args{"user": call.Caller.User, "timer": name}
+	err := customStore.DeleteTimer(name)
+	if err == customcmd.ErrNotFound {
+		return say(chat, call.Caller.User, "timer_not_found", args)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("%s deleted the timer %s", call.Caller.User, name)
+	return say(chat, call.Caller.User, "timer_deleted", args)
+}
+
+func cmdListTimers(call *commands.Call) error {
+	chat := chatOf(call).chat
+	timers, err := customStore.Timers()
+	if err != nil {
+		return err
+	}
+	if len(timers) == 0 {
+		return say(chat, call.Caller.User, "timers_empty", nil)
+	}
+
+	var list []string
+	for _, t := range timers {
+		list = append(list, fmt.Sprintf("%s (%dmin)", t.Name, int(t.Interval/time.Minute)))
+	}
+	return say(chat, call.Caller.User, "timers_list", i18n.Args{"timers": strings.Join(list, ", ")})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/commands"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/i18n"
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+const cmdLang = "!lang"
+
+// langCooldown limits !lang, that writes the database
+var langCooldown = cooldown.Rules{
+	User: cooldown.Limit{Every: 30 * time.Second},
+}
+
+// loadLanguages loads the built-in message catalogs and the ones of Language.Dir
+func loadLanguages(cfg config.GeneralConfig) {
+	c := i18n.Builtin()
+	if cfg.Language.Dir != "" {
+		if err := c.LoadDir(cfg.Language.Dir); err != nil {
+			log.Error("Cannot load the languages of %s: %s", cfg.Language.Dir, err)
+		}
+	}
+
+	for _, err := range c.Validate() {
+		log.Warn("Language catalog: %s", err)
+	}
+
+	langs := []string{cfg.Language.PanelLanguage()}
+	langs = append(langs, cfg.Language.ChannelLanguages("")...)
+	for _, channelLangs := range cfg.Language.Channels {
+		langs = append(langs, channelLangs...)
+	}
+	for _, lang := range langs {
+		if !c.Has(lang) {
+			log.Error("There are no messages in %q. The %s ones will be used", lang, i18n.Fallback)
+		}
+	}
+
+	i18n.SetDefault(c)
+}
+
+// replyLanguages returns the languages of a reply to user on chat, in the order they are sent.
+// Viewers that picked a language with !lang get only that one
+func replyLanguages(chat *twitch.Chat, user string) []string {
+	if customStore != nil && user != "" {
+		lang, err := customStore.UserLanguage(user)
+		if err != nil {
+			log.Error("Cannot read the language of %s: %s", user, err)
+		}
+		if lang != "" {
+			return []string{lang}
+		}
+	}
+	return config.GetConfig().Language.ChannelLanguages(chat.Channel())
+}
+
+// say sends the message key once per reply language of user
+func say(chat *twitch.Chat, user, key string, args i18n.Args) error {
+	return sayWithPriority(chat, twitch.PriorityNormal, user, key, args)
+}
+
+// sayWithPriority is say with the priority of the send queue. A text that is the same
+// in two languages, like "/me F", is sent once
+func sayWithPriority(chat *twitch.Chat, priority twitch.MessagePriority, user, key string, args i18n.Args) error {
+	var err error
+	sent := map[string]bool{}
+	for _, lang := range replyLanguages(chat, user) {
+		text := i18n.T(lang, key, args)
+		if sent[text] {
+			continue
+		}
+		sent[text] = true
+		if e := chat.SendMessageWithPriority(text, priority); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// announce sends the message key to the channel, in the channel languages
+func announce(chat *twitch.Chat, priority twitch.MessagePriority, key string, args i18n.Args) {
+	_ = sayWithPriority(chat, priority, "", key, args)
+}
+
+// help returns the help text of a command in every language of the catalog
+func help(key string, args i18n.Args) map[string]string {
+	c := i18n.Default()
+	h := map[string]string{}
+	for _, lang := range c.Languages() {
+		h[lang] = c.T(lang, key, args)
+	}
+	return h
+}
+
+// cmdSetLanguage sets the reply language of the caller. "default" goes back to the channel languages
+func cmdSetLanguage(call *commands.Call) error {
+	chat := chatOf(call).chat
+	user := call.Caller.User
+	lang := strings.ToLower(call.String("language"))
+	args := i18n.Args{"user": user, "lang": lang, "languages": strings.Join(i18n.Default().Languages(), " ")}
+
+	switch {
+	case lang == "":
+		return say(chat, user, "lang_usage", args)
+	case lang != "default" && !i18n.Default().Has(lang):
+		return say(chat, user, "lang_unknown", args)
+	case customStore == nil:
+		return fmt.Errorf("the database %s is not open", config.GetDatabaseFileName())
+	}
+
+	if lang == "default" {
+		if err := customStore.SetUserLanguage(user, ""); err != nil {
+			return err
+		}
+		return say(chat, user, "lang_default", args)
+	}
+
+	if err := customStore.SetUserLanguage(user, lang); err != nil {
+		return err
+	}
+	return say(chat, user, "lang_set", args)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/racerxdl/twitchled/commands"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/discord"
+	"github.com/racerxdl/twitchled/i18n"
+	"github.com/racerxdl/twitchled/moderation"
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+const (
+	cmdApprove = "!approve"
+	cmdReject  = "!reject"
+	cmdPending = "!pending"
+)
+
+var panelFilter *moderation.Filter
+var approvals *moderation.Queue
+
+// startModeration builds the panel text filter and the approval queue
+func startModeration(cfg config.GeneralConfig) {
+	f, errs := moderation.MakeFilter(cfg.Moderation)
+	for _, err := range errs {
+		log.Error("%s. The pattern will be skipped", err)
+	}
+	panelFilter = f
+
+	var timeout time.Duration
+	if cfg.Moderation.ApprovalTimeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(cfg.Moderation.ApprovalTimeout)
+		if err != nil {
+			log.Error("Invalid ApprovalTimeout %q. The default will be used", cfg.Moderation.ApprovalTimeout)
+		}
+	}
+
+	approvals = moderation.MakeQueue(timeout, func(p *moderation.Pending) {
+		discord.Log("Moderation", "", fmt.Sprintf("**Expired** %s %d from **%s**: %s", p.Source, p.Id, p.User, p.Text))
+	})
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			approvals.Expire()
+		}
+	}()
+}
+
+// submitPanelText filters text a viewer wants on the panel. publish is called when it can be shown,
+// right away or after a moderator approves it. cancel, if set, is called when it is rejected or expires
+func submitPanelText(chat *twitch.Chat, user, text, source string, publish, cancel func()) {
+	if err := panelFilter.Check(text); err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		log.Warn("Rejected %s from %s: %s", source, user, err)
+		discord.Log("Moderation", "", fmt.Sprintf("**Rejected** %s from **%s** (%s): %s", source, user, err, text))
+		_ = say(chat, user, "panel_rejected", i18n.Args{"user": user, "error": err})
+		return
+	}
+
+	if !config.GetConfig().Moderation.RequireApproval {
+		publish()
+		return
+	}
+
+	id := approvals.Submit(&moderation.Pending{
+		User:    user,
+		Text:    text,
+		Source:  source,
+		Publish: publish,
+		Cancel:  cancel,
+	})
+	discord.Log("Moderation", "", fmt.Sprintf("%s %d from **%s** waiting for approval: %s", source, id, user, text))
+	_ = say(chat, user, "panel_waiting", i18n.Args{"user": user})
+}
+
+func cmdListPending(call *commands.Call) error {
+	chat := chatOf(call).chat
+	pending := approvals.List()
+	if len(pending) == 0 {
+		return say(chat, call.Caller.User, "pending_empty", nil)
+	}
+	for _, p := range pending {
+		_ = say(chat, call.Caller.User, "pending_item", i18n.Args{"id": p.Id, "source": p.Source, "user": p.User, "text": p.Text})
+	}
+	return nil
+}
+
+func cmdApprovePending(call *commands.Call) error {
+	chat := chatOf(call).chat
+	id := call.Int("id")
+	p, err := approvals.Approve(id)
+	if err == moderation.ErrNotPending {
+		return say(chat, call.Caller.User, "pending_not_found", i18n.Args{"id": id})
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("%s approved %s %d from %s", call.Caller.User, p.Source, p.Id, p.User)
+	discord.Log("Moderation", "", fmt.Sprintf("**Approved** by %s: %s %d from **%s**: %s", call.Caller.User, p.Source, p.Id, p.User, p.Text))
+	return nil
+}
+
+func cmdRejectPending(call *commands.Call) error {
+	chat := chatOf(call).chat
+	id := call.Int("id")
+	reason := call.String("reason")
+	if reason == "" {
+		reason = "no reason"
+	}
+	p, err := approvals.Reject(id)
+	if err == moderation.ErrNotPending {
+		return say(chat, call.Caller.User, "pending_not_found", i18n.Args{"id": id})
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("%s rejected %s %d from %s: %s", call.Caller.User, p.Source, p.Id, p.User, reason)
+	discord.Log("Moderation", "", fmt.Sprintf("**Rejected** by %s (%s): %s %d from **%s**: %s", call.Caller.User, reason, p.Source, p.Id, p.User, p.Text))
+	return say(chat, p.User, "panel_not_approved", i18n.Args{"user": p.User})
+}
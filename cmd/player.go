@@ -1,11 +1,5 @@
 package main
 
-import (
-	"time"
-)
-
-var lastJavascriptoPlay time.Time
-
 func PlayJavascripto() error {
 	return nil
 	// f, err := os.Open("mata-javascripto.mp3")
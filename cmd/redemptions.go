@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/racerxdl/twitchled/discord"
+	"github.com/racerxdl/twitchled/twitch"
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+	"github.com/racerxdl/twitchled/wimatrix"
+)
+
+// completeRedemption fulfills the redemption, or cancels it to refund the points, in background
+func completeRedemption(r twitchdata.RedemptionData, fulfilled bool, reason string) {
+	if r.Status != "" && !strings.EqualFold(r.Status, twitch.RedemptionUnfulfilled) {
+		// Rewards that skip the request queue are fulfilled by Twitch
+		return
+	}
+
+	status := twitch.RedemptionFulfilled
+	if !fulfilled {
+		status = twitch.RedemptionCanceled
+	}
+
+	go func() {
+		err := twitch.UpdateRedemptionStatus(r.ChannelId, r.Reward.Id, r.Id, status)
+		if err != nil {
+			log.Warn("Cannot set redemption %s of %s from %s to %s: %s", r.Id, r.Reward.Title, r.User.DisplayName, status, err)
+			return
+		}
+
+		log.Info("Redemption %s of %s from %s set to %s", r.Id, r.Reward.Title, r.User.DisplayName, status)
+		if !fulfilled {
+			discord.Log("Redemptions", "", fmt.Sprintf("**Refunded** %s to **%s**: %s", r.Reward.Title, r.User.DisplayName, reason))
+		}
+	}()
+}
+
+// redemptionAlert completes r when the alert sent to target is shown, or refunds it if no panel shows it
+func redemptionAlert(r twitchdata.RedemptionData, target string) wimatrix.AlertDone {
+	return wimatrix.TrackAlert(len(panels.Resolve(target)), func(shown bool) {
+		completeRedemption(r, shown, "not shown on the panel")
+	})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/twitch"
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+	"github.com/racerxdl/twitchled/wimatrix"
+)
+
+// Reward actions handled by the bot itself. Any other action is the name of an [[actions]] entry
+const (
+	rewardPanel      = "panel"
+	rewardCodeReview = "codereview"
+)
+
+// managedRewards keeps the [[rewards]] by Twitch id and pauses them with the panels and the stream
+type managedRewards struct {
+	sync.Mutex
+	channelId    string
+	byId         map[string]config.RewardConfig
+	paused       map[string]bool
+	panelsOnline map[string]bool
+	streamKnown  bool
+	streamOnline bool
+
+	// updating keeps the Helix calls in the same order as the status changes
+	updating sync.Mutex
+}
+
+var rewardManager = &managedRewards{
+	byId:         map[string]config.RewardConfig{},
+	paused:       map[string]bool{},
+	panelsOnline: map[string]bool{},
+}
+
+// rewardSettings converts rc to the Helix reward fields
+func rewardSettings(rc config.RewardConfig) (twitch.CustomRewardSettings, error) {
+	s := twitch.CustomRewardSettings{
+		Title:               rc.Title,
+		Cost:                rc.Cost,
+		Prompt:              rc.Prompt,
+		IsEnabled:           true,
+		IsUserInputRequired: rc.InputRequired,
+	}
+
+	if rc.Title == "" || rc.Cost <= 0 {
+		return s, fmt.Errorf("reward needs a title and a cost")
+	}
+
+	if rc.Cooldown != "" {
+		d, err := time.ParseDuration(rc.Cooldown)
+		if err != nil || d < time.Second {
+			return s, fmt.Errorf("invalid cooldown %q", rc.Cooldown)
+		}
+		s.IsGlobalCooldownEnabled = true
+		s.GlobalCooldownSeconds = int(d.Seconds())
+	}
+
+	if rc.Color != "" {
+		c, err := wimatrix.ParseColor(rc.Color)
+		if err != nil {
+			return s, fmt.Errorf("invalid color %q", rc.Color)
+		}
+		r, g, b, _ := c.RGBA()
+		s.BackgroundColor = fmt.Sprintf("#%02X%02X%02X", r>>8, g>>8, b>>8)
+	}
+
+	return s, nil
+}
+
+// validRewardAction returns true if the reward action is handled by the bot or is a configured action
+func validRewardAction(action string) bool {
+	action = strings.ToLower(action)
+	if action == rewardPanel || action == rewardCodeReview {
+		return true
+	}
+	_, ok := homeActions.Get(action)
+	return ok
+}
+
+// syncRewards creates the configured rewards missing on the channel and updates the others
+func (m *managedRewards) syncRewards(channelId string) {
+	m.Lock()
+	m.channelId = channelId
+	for _, d := range panels.Devices() {
+		m.panelsOnline[d.Name()] = d.State().Online
+	}
+	m.Unlock()
+
+	configured := config.GetConfig().Rewards
+	if len(configured) == 0 {
+		return
+	}
+
+	existing, err := twitch.GetCustomRewards(channelId, true)
+	if err != nil {
+		log.Error("Cannot list the channel rewards: %s", err)
+		return
+	}
+
+	for _, rc := range configured {
+		if !validRewardAction(rc.Action) {
+			log.Error("Reward %q has unknown action %q. It will be skipped", rc.Title, rc.Action)
+			continue
+		}
+		settings, err := rewardSettings(rc)
+		if err != nil {
+			log.Error("Reward %q: %s. It will be skipped", rc.Title, err)
+			continue
+		}
+
+		var current *twitch.CustomReward
+		for j, r := range existing {
+			if (rc.Id != "" && r.Id == rc.Id) || (rc.Id == "" && strings.EqualFold(r.Title, rc.Title)) {
+				current = &existing[j]
+				break
+			}
+		}
+
+		var reward twitch.CustomReward
+		if current != nil {
+			log.Info("Updating reward %q", rc.Title)
+			reward, err = twitch.UpdateCustomReward(channelId, current.Id, settings)
+		} else {
+			log.Info("Creating reward %q", rc.Title)
+			reward, err = twitch.CreateCustomReward(channelId, settings)
+		}
+		if err != nil {
+			// Rewards created on the dashboard cannot be managed, they must be deleted first
+			log.Error("Cannot save reward %q: %s", rc.Title, err)
+			continue
+		}
+
+		config.SetRewardId(rc.Title, reward.Id)
+		rc.Id = reward.Id
+
+		m.Lock()
+		m.byId[reward.Id] = rc
+		m.paused[reward.Id] = reward.IsPaused
+		m.Unlock()
+	}
+
+	m.updatePaused()
+}
+
+// actionFor returns what the redeemed reward does. Rewards not managed by the bot are matched by title
+func (m *managedRewards) actionFor(r twitchdata.RewardData) string {
+	m.Lock()
+	rc, ok := m.byId[r.Id]
+	m.Unlock()
+	if ok {
+		return strings.ToLower(rc.Action)
+	}
+
+	cfg := config.GetConfig()
+	switch r.Title {
+	case cfg.RewardTitle:
+		return rewardPanel
+	case cfg.CodeReviewRewardTitle:
+		return rewardCodeReview
+	}
+	if a, ok := homeActions.ForReward(r.Title); ok {
+		return a.Name
+	}
+
+	return ""
+}
+
+func (m *managedRewards) setPanelOnline(name string, online bool) {
+	m.Lock()
+	m.panelsOnline[name] = online
+	m.Unlock()
+	m.updatePaused()
+}
+
+func (m *managedRewards) setStreamOnline(online bool) {
+	m.Lock()
+	m.streamKnown = true
+	m.streamOnline = online
+	m.Unlock()
+	m.updatePaused()
+}
+
+// updatePaused pauses the rewards that cannot be redeemed now and unpauses the others
+func (m *managedRewards) updatePaused() {
+	m.updating.Lock()
+	defer m.updating.Unlock()
+
+	m.Lock()
+	anyPanelOnline := false
+	for _, online := range m.panelsOnline {
+		anyPanelOnline = anyPanelOnline || online
+	}
+
+	changes := map[string]bool{}
+	for id, rc := range m.byId {
+		paused := (rc.PauseWhenPanelOffline && !anyPanelOnline) ||
+			(rc.PauseWhenStreamOffline && m.streamKnown && !m.streamOnline)
+		if paused != m.paused[id] {
+			changes[id] = paused
+			m.paused[id] = paused
+		}
+	}
+	channelId := m.channelId
+	m.Unlock()
+
+	for id, paused := range changes {
+		log.Info("Setting reward %s paused: %t", id, paused)
+		if err := twitch.SetCustomRewardPaused(channelId, id, paused); err != nil {
+			log.Error("Cannot pause reward %s: %s", id, err)
+			m.Lock()
+			m.paused[id] = !paused
+			m.Unlock()
+		}
+	}
+}
+
+// watchRewardPause follows the panel status to pause the rewards
+func watchRewardPause() {
+	_ = ev.Subscribe(wimatrix.EvDeviceOnline, func(name string) {
+		go rewardManager.setPanelOnline(name, true)
+	})
+	_ = ev.Subscribe(wimatrix.EvDeviceOffline, func(name string) {
+		go rewardManager.setPanelOnline(name, false)
+	})
+}
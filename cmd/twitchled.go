@@ -10,10 +10,11 @@ import (
 	"time"
 
 	"github.com/asaskevich/EventBus"
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/actions"
 	"github.com/racerxdl/twitchled/config"
 	"github.com/racerxdl/twitchled/discord"
+	"github.com/racerxdl/twitchled/i18n"
 	"github.com/racerxdl/twitchled/openai"
 	"github.com/racerxdl/twitchled/twitch"
 	"github.com/racerxdl/twitchled/twitch/websub"
@@ -22,8 +23,9 @@ import (
 
 var log = slog.Scope("TwitchLED")
 var cfg config.GeneralConfig
-var mqttClient mqtt.Client
 var ev EventBus.Bus
+var panels *wimatrix.Registry
+var homeActions *actions.Registry
 
 func OnReward(chat *twitch.Chat, reward *twitch.RewardRedemptionEventData) {
 	userRewardName := fmt.Sprintf("REWARD(%s)", reward.Data.Reward.Title)
@@ -31,31 +33,66 @@ func OnReward(chat *twitch.Chat, reward *twitch.RewardRedemptionEventData) {
 
 	log.Debug("User %s rewarded %s", reward.Data.User.DisplayName, reward.Data.Reward.Title)
 
-	switch reward.Data.Reward.Title {
-	case config.GetConfig().RewardTitle:
+	if reward.Data.ChannelId == "" {
+		reward.Data.ChannelId = reward.ChannelId
+	}
+
+	action := rewardManager.actionFor(reward.Data.Reward)
+
+	if action != rewardPanel {
+		ev.Publish(wimatrix.EvNewReward, reward.Data.User.DisplayName, reward.Data.Reward.Title, reward.Data.UserInput, reward.Data.Reward.Cost, nil)
+	}
+
+	switch action {
+	case "":
+		return
+	case rewardPanel:
 		msg := fmt.Sprintf("%s by %s", reward.Data.UserInput, reward.Data.User.DisplayName)
 		log.Info("User %s sent %s", reward.Data.User.DisplayName, reward.Data.UserInput)
-		discord.SendMessage(userRewardName, userRewardAvatar, fmt.Sprintf("Panel from %s", reward.Data.User.DisplayName))
-		ev.Publish(wimatrix.EvNewMsg, msg)
-		_ = chat.SendMessage(fmt.Sprintf("Panel set to: %s", msg))
-	case config.GetConfig().LightRewardTitle:
-		log.Info("User %s toggled the light", reward.Data.User.DisplayName)
-		discord.SendMessage(userRewardName, userRewardAvatar, fmt.Sprintf("Light toggle from %s", reward.Data.User.DisplayName))
-		ev.Publish(wimatrix.EvSetLight)
-	case config.GetConfig().CodeReviewRewardTitle:
+		submitPanelText(chat, reward.Data.User.DisplayName, reward.Data.UserInput, "redemption", func() {
+			discord.SendMessage(userRewardName, userRewardAvatar, fmt.Sprintf("Panel from %s", reward.Data.User.DisplayName))
+			ev.Publish(wimatrix.EvNewMsg, msg, redemptionAlert(reward.Data, wimatrix.TargetAll))
+			announce(chat, twitch.PriorityNormal, "panel_set", i18n.Args{"text": msg})
+		}, func() {
+			completeRedemption(reward.Data, false, "text rejected")
+		})
+	case rewardCodeReview:
 		log.Info("User %s requested a code review: %s", reward.Data.User.DisplayName, reward.Data.UserInput)
 		discord.SendMessage(userRewardName, userRewardAvatar, fmt.Sprintf("@here - Code Review from **%s**: %s", reward.Data.User.DisplayName, reward.Data.UserInput))
 		openai.UpdateContext("last_code_review", time.Now().String())
 		openai.UpdateContext("last_code_review_user", reward.Data.User.DisplayName)
 		openai.UpdateContext("last_code_review_text", reward.Data.UserInput)
+	default:
+		a, ok := homeActions.Get(action)
+		if !ok {
+			return
+		}
+		caller := actions.Caller{
+			User:   reward.Data.User.DisplayName,
+			Reward: true,
+			Done: func(err error) {
+				if err != nil {
+					completeRedemption(reward.Data, false, fmt.Sprintf("%s failed: %s", a.Name, err))
+					return
+				}
+				completeRedemption(reward.Data, true, "")
+			},
+		}
+		if err := homeActions.Trigger(a.Name, caller); err != nil {
+			completeRedemption(reward.Data, false, fmt.Sprintf("%s: %s", a.Name, err))
+			log.Warn("User %s cannot trigger %s: %s", reward.Data.User.DisplayName, a.Name, err)
+			_ = say(chat, reward.Data.User.Name, "action_failed", i18n.Args{"user": reward.Data.User.DisplayName, "action": a.Name, "error": err})
+			return
+		}
+		discord.SendMessage(userRewardName, userRewardAvatar, fmt.Sprintf("Action %s from %s", a.Name, reward.Data.User.DisplayName))
 	}
 }
 
 func OnFollow(chat *twitch.Chat, data *twitch.FollowEventData) {
 	msg := fmt.Sprintf("User %s followed", data.Username)
 	log.Debug(msg)
-	_ = chat.SendMessage(fmt.Sprintf("Thanks %s for the follow!", data.Username))
-	_ = chat.SendMessage(fmt.Sprintf("Obrigado %s pelo follow!", data.Username))
+	ev.Publish(wimatrix.EvNewFollower, data.Username)
+	_ = sayWithPriority(chat, twitch.PriorityHigh, data.Username, "follow_thanks", i18n.Args{"user": data.Username})
 	discord.SendMessage("FOLLOW", "", strings.Replace(msg, data.Username, "**"+data.Username+"**", -1))
 	openai.UpdateContext("last_follow", time.Now().String())
 	openai.UpdateContext("last_follow_user", data.Username)
@@ -71,38 +108,167 @@ func OnBits(chat *twitch.Chat, bits *twitch.BitsV2EventData) {
 	msg := fmt.Sprintf("User %s send %d bits: %s!", username, numBits, message)
 	log.Info(msg)
 	ev.Publish(wimatrix.EvNewBits, username, numBits, message)
-	_ = chat.SendMessage(fmt.Sprintf("Thanks %s for %d bits!!", username, numBits))
-	_ = chat.SendMessage(fmt.Sprintf("Obrigado %s por %d bits!!", username, numBits))
+	_ = sayWithPriority(chat, twitch.PriorityHigh, username, "bits_thanks", i18n.Args{"user": username, "bits": numBits})
 	discord.SendMessage("BITS", "", msg)
 	openai.UpdateContext("last_bits", time.Now().String())
 	openai.UpdateContext("last_bits_user", username)
 	openai.UpdateContext("last_bits_amount", fmt.Sprintf("%d", numBits))
 }
 
+// recentSubs holds subs already announced, since they can arrive both from EventSub and chat USERNOTICE
+var recentSubs = map[string]time.Time{}
+
+const recentSubsWindow = time.Minute * 5
+
+// isDuplicatedSub returns true if the same sub was already received from another source
+func isDuplicatedSub(subscribe *twitch.SubscribeEventData) bool {
+	// EventSub notifies a gift once for the gifter while chat has one notice per recipient,
+	// so gifts are keyed by the gifter
+	key := "sub:" + strings.ToLower(subscribe.Data.UserName)
+	if subscribe.Data.IsGift {
+		key = "gift:" + strings.ToLower(subscribe.Data.UserName)
+	}
+
+	for k, v := range recentSubs {
+		if time.Since(v) > recentSubsWindow {
+			delete(recentSubs, k)
+		}
+	}
+
+	if _, ok := recentSubs[key]; ok {
+		return true
+	}
+
+	recentSubs[key] = time.Now()
+	return false
+}
+
 func OnSub(chat *twitch.Chat, subscribe *twitch.SubscribeEventData) {
+	if isDuplicatedSub(subscribe) {
+		log.Debug("Ignoring duplicated sub from %s", subscribe.Data.DisplayName)
+		return
+	}
+
 	msg := fmt.Sprintf("User %s subscribed for %d months!", subscribe.Data.DisplayName, subscribe.Data.StreakMonths+1)
 	log.Info(msg)
-	ev.Publish(wimatrix.EvNewSub, subscribe.Data.DisplayName, subscribe.Data.StreakMonths+1)
-	_ = chat.SendMessage(fmt.Sprintf("Thanks @%s for %d months subscription!!", subscribe.Data.DisplayName, subscribe.Data.StreakMonths+1))
-	_ = chat.SendMessage(fmt.Sprintf("Obrigado @%s pelo sub de %d meses!!", subscribe.Data.DisplayName, subscribe.Data.StreakMonths+1))
+	ev.Publish(wimatrix.EvNewSub, subscribe.Data.DisplayName, subscribe.Data.StreakMonths+1, subscribe.Data.SubPlan)
+	_ = sayWithPriority(chat, twitch.PriorityHigh, subscribe.Data.UserName, "sub_thanks", i18n.Args{"user": subscribe.Data.DisplayName, "months": subscribe.Data.StreakMonths + 1})
 	discord.SendMessage("SUBSCRIBE", "", msg)
 	openai.UpdateContext("last_sub", time.Now().String())
 	openai.UpdateContext("last_sub_user", subscribe.Data.DisplayName)
 	openai.UpdateContext("last_sub_months", fmt.Sprintf("%d", subscribe.Data.StreakMonths+1))
 }
 
+func OnRaid(chat *twitch.Chat, notice *twitch.UserNoticeEventData) {
+	msg := fmt.Sprintf("User %s raided with %d viewers!", notice.DisplayName(), notice.RaidViewers())
+	log.Info(msg)
+	ev.Publish(wimatrix.EvNewRaid, notice.DisplayName(), notice.RaidViewers())
+	_ = sayWithPriority(chat, twitch.PriorityHigh, notice.DisplayName(), "raid_thanks", i18n.Args{"user": notice.DisplayName(), "viewers": notice.RaidViewers()})
+	discord.SendMessage("RAID", "", msg)
+	openai.UpdateContext("last_raid", time.Now().String())
+	openai.UpdateContext("last_raid_user", notice.DisplayName())
+	openai.UpdateContext("last_raid_viewers", fmt.Sprintf("%d", notice.RaidViewers()))
+}
+
+func OnUserNotice(chat *twitch.Chat, notice *twitch.UserNoticeEventData) {
+	log.Debug("USERNOTICE %s: %s", notice.MsgId(), notice.SystemMessage())
+	switch {
+	case notice.IsSub():
+		OnSub(chat, notice.AsSubscribeEvent())
+	case notice.IsRaid():
+		OnRaid(chat, notice)
+	}
+}
+
 func OnStreamChange(chat *twitch.Chat, data *twitch.StreamStatusEventData) {
+	ev.Publish(wimatrix.EvStreamStatus, data.Online, data.Title)
+	setStreamOnline(data.Online, data.StartedAt)
+	go rewardManager.setStreamOnline(data.Online)
 	if data.Online {
 		openai.SetLivestreamTitle(data.Title)
 		openai.UpdateContext("live_start", time.Now().String())
-		_ = chat.SendMessage(fmt.Sprintf("/me LIVE ON!! %s", data.Title))
+		announce(chat, twitch.PriorityHigh, "stream_online", i18n.Args{"title": data.Title})
 		discord.Log("TwitchLED", "", "**LIVE ON** everyone! https://twitch.tv/racerxdl")
 	} else {
 		openai.UpdateContext("live_end", time.Now().String())
 		openai.UpdateContext("last_live", openai.GetContext("livestream_title"))
-		_ = chat.SendMessage("/me F")
-		_ = chat.SendMessage("/me GOODBYE WORLD")
+		announce(chat, twitch.PriorityNormal, "stream_offline", nil)
+		announce(chat, twitch.PriorityNormal, "stream_goodbye", nil)
+	}
+}
+
+func OnChannelUpdate(data *twitch.ChannelUpdateEventData) {
+	log.Info("Stream title changed to %q", data.Title)
+	openai.SetLivestreamTitle(data.Title)
+	ev.Publish(wimatrix.EvStreamTitle, data.Title)
+	setStreamGame(data.CategoryName)
+}
+
+func OnConnectionEvent(data *twitch.ConnectionEventData) {
+	switch data.GetType() {
+	case twitch.EventDisconnected:
+		log.Warn("%s disconnected: %s", data.Source, data.Error())
+		discord.Log("TwitchLED", "", fmt.Sprintf("**%s disconnected**: %s", data.Source, data.Error()))
+	case twitch.EventReconnecting:
+		log.Info("%s reconnecting in %s (attempt %d)", data.Source, data.Delay, data.Attempt)
+	case twitch.EventReconnected:
+		log.Info("%s reconnected after %s", data.Source, data.Downtime)
+		discord.Log("TwitchLED", "", fmt.Sprintf("**%s reconnected** after %s (%d attempts)", data.Source, data.Downtime.Round(time.Second), data.Attempt))
+	}
+}
+
+func OnDeviceOnline(chat *twitch.Chat, name string) {
+	log.Info("Panel %s is online", name)
+	discord.Log("TwitchLED", "", fmt.Sprintf("Panel **%s** is online", name))
+	announce(chat, twitch.PriorityLow, "panel_online", nil)
+}
+
+func OnDeviceOffline(chat *twitch.Chat, name string) {
+	log.Warn("Panel %s is offline", name)
+	discord.Log("TwitchLED", "", fmt.Sprintf("Panel **%s** is offline", name))
+	announce(chat, twitch.PriorityLow, "panel_offline", nil)
+}
+
+// startPanels creates and starts every configured device
+func startPanels(cfg config.GeneralConfig) *wimatrix.Registry {
+	registry := wimatrix.MakeRegistry()
+
+	for _, dc := range cfg.GetDevices() {
+		log.Info("Connecting to Device %s", dc.Name)
+		display, err := wimatrix.MakeDisplay(wimatrix.DisplayConfig{
+			Driver:   dc.Display,
+			Name:     dc.Name,
+			MQTTHost: dc.Host,
+			MQTTUser: dc.User,
+			MQTTPass: dc.Pass,
+			WLEDHost: dc.WLEDHost,
+			Width:    dc.Width,
+			Height:   dc.Height,
+		})
+		if err != nil {
+			log.Error("Cannot start %q display for %s, using terminal simulator: %s", dc.Display, dc.Name, err)
+			discord.Log("TwitchLED", "", fmt.Sprintf("Cannot start display %s: %s", dc.Name, err))
+		}
+
+		led := wimatrix.MakeWiiMatrix(dc.Name, display, ev)
+		led.SetTags(dc.Tags...)
+		led.SetStateFile(config.GetStateFileName(dc.Name))
+		led.Start()
+
+		registry.Add(led)
+	}
+
+	return registry
+}
+
+// loadActions parses the configured actions, skipping the invalid ones
+func loadActions(cfg config.GeneralConfig) *actions.Registry {
+	r, errs := actions.Load(cfg)
+	for _, err := range errs {
+		log.Error("%s. The action will be skipped", err)
 	}
+	log.Info("Actions: %s", strings.Join(r.Names(), " "))
+	return r
 }
 
 func main() {
@@ -111,29 +277,21 @@ func main() {
 
 	// discord.SendMessage("TwitchLED", "", "**HUEHUE BEGINS**")
 	// defer discord.SendMessage("TwitchLED", "", "**GOODBYE WORLD**")
-	log.Info("Connecting to Device %s", cfg.DeviceName)
 	openai.UpdateContext("bot_start", time.Now().String())
 	openai.UpdateContext("livestream_title", "Hackinagens e jogos")
 
-	// opts := mqtt.NewClientOptions()
-	// opts.AddBroker(fmt.Sprintf("tcp://%s:1883", cfg.Host))
-	// opts.SetUsername(cfg.User)
-	// opts.SetPassword(cfg.Pass)
-
-	// mqttClient = mqtt.NewClient(opts)
-	// log.Debug("Connecting to MQTT at %s", cfg.Host)
-	// if !mqttClient.Connect().WaitTimeout(time.Second * 5) {
-	// 	discord.SendMessage("TwitchLED", "", "Cannot connect to MQTT")
-	// 	log.Fatal("Cannot connect to MQTT")
-	// }
-
 	ev = EventBus.New()
 
-	// led := wimatrix.MakeWiiMatrix(cfg.DeviceName, mqttClient, ev)
+	loadLanguages(cfg)
+	cooldowns = loadCooldowns(cfg)
+	homeActions = loadActions(cfg)
+	homeActions.SetLimiter(cooldowns)
+	startModeration(cfg)
 
-	// led.Start()
+	wimatrix.SetAvatarLookup(twitch.GetProfilePic)
+	panels = startPanels(cfg)
+	defer panels.CloseAll()
 
-	// defer led.Stop()
 	token, _ := twitch.GetAccessToken()
 
 	// ev.Publish(wimatrix.EvSetSpeed, int(20))
@@ -142,7 +300,7 @@ func main() {
 	// ev.Publish(wimatrix.EvSetBgColor, colornames.Darkblue)
 	// ev.Publish(wimatrix.EvSetBgBrightness, float32(0.01))
 	// ev.Publish(wimatrix.EvSetTextBrightness, float32(0.1))
-	// ev.Publish(wimatrix.EvNewMsg, "LIVE ON!")
+	// ev.Publish(wimatrix.EvNewMsg, "LIVE ON!", nil)
 
 	channelId, err := twitch.GetChannelId()
 
@@ -151,6 +309,18 @@ func main() {
 	}
 	openai.UpdateContext("channel_id", channelId)
 
+	openCustomStore()
+	if customStore != nil {
+		defer customStore.Close()
+	}
+	seedStreamInfo(channelId)
+
+	permissionChecker = loadPermissions(cfg, channelId)
+	chatCommands = loadCommands(cfg, permissionChecker, cooldowns)
+
+	watchRewardPause()
+	rewardManager.syncRewards(channelId)
+
 	channelName, _ := twitch.GetChannelName()
 
 	log.Info("Channel ID is %s and name is %s", channelId, channelName)
@@ -182,6 +352,13 @@ func main() {
 		log.Fatal("Error starting chat: %s", err)
 	}
 
+	defer chat.Close()
+
+	startCustomTimers(chat)
+
+	_ = ev.Subscribe(wimatrix.EvDeviceOnline, func(name string) { OnDeviceOnline(chat, name) })
+	_ = ev.Subscribe(wimatrix.EvDeviceOffline, func(name string) { OnDeviceOffline(chat, name) })
+
 	// _ = chat.SendMessage("/me HUEHUE BEGINS")
 
 	// msgTimer := time.NewTicker(time.Minute * 5)
@@ -207,6 +384,9 @@ func main() {
 	recheckToken := time.NewTicker(time.Minute * 5)
 	defer recheckToken.Stop()
 
+	checkTimers := time.NewTicker(time.Second * 30)
+	defer checkTimers.Stop()
+
 	lastClip := time.Now().Add(time.Hour * -1)
 	clips, _ := twitch.GetClips(channelId, lastClip)
 	cachedClips := map[string]struct{}{}
@@ -235,13 +415,20 @@ func main() {
 	for running {
 		select {
 		case <-recheckToken.C:
-			twitch.RefreshToken() // It does not refresh if still valid
+			// It does not refresh if still valid
+			if err := twitch.RefreshToken(); err != nil {
+				log.Error("Cannot refresh the token: %s", err)
+			}
+		case <-checkTimers.C:
+			if customTimers != nil {
+				customTimers.Tick()
+			}
 		case <-recheckClips.C:
 			clips, _ := twitch.GetClips(channelId, lastClip)
 			for _, v := range clips {
 				if _, ok := cachedClips[v]; !ok {
 					cachedClips[v] = struct{}{}
-					chat.SendMessage(fmt.Sprintf("New clip: %s", v))
+					announce(chat, twitch.PriorityNormal, "new_clip", i18n.Args{"url": v})
 					openai.UpdateContext("last_clip", v)
 					discord.Clip("ClipBot", "", v)
 					saveCachedClips()
@@ -254,6 +441,8 @@ func main() {
 				OnFollow(chat, e.GetData().(*twitch.FollowEventData))
 			case twitch.EventStreamStatus:
 				OnStreamChange(chat, e.GetData().(*twitch.StreamStatusEventData))
+			case twitch.EventChannelUpdate:
+				OnChannelUpdate(e.GetData().(*twitch.ChannelUpdateEventData))
 			}
 		case e := <-mon.EventChannel():
 			switch e.GetType() {
@@ -263,11 +452,25 @@ func main() {
 				OnBits(chat, e.(*twitch.BitsV2EventData))
 			case twitch.EventSubscribe:
 				OnSub(chat, e.(*twitch.SubscribeEventData))
+			case twitch.EventDisconnected, twitch.EventReconnecting, twitch.EventReconnected:
+				OnConnectionEvent(e.(*twitch.ConnectionEventData))
 			}
 		case e := <-chat.Events:
 			switch e.GetType() {
 			case twitch.EventMessage:
+				if customTimers != nil {
+					customTimers.Activity()
+				}
 				ParseChat(chat, e.GetData().(*twitch.MessageEventData))
+			case twitch.EventUserNotice:
+				OnUserNotice(chat, e.(*twitch.UserNoticeEventData))
+			case twitch.EventClearChat:
+				cc := e.(*twitch.ClearChatEventData)
+				if cc.IsPermanentBan() {
+					discord.Log("TwitchLED", "", fmt.Sprintf("User **%s** was banned", cc.TargetUser))
+				} else if !cc.IsChatClear() {
+					discord.Log("TwitchLED", "", fmt.Sprintf("User **%s** was timed out for %s", cc.TargetUser, cc.BanDuration()))
+				}
 			case twitch.EventError:
 				er := e.GetData().(*twitch.ErrorEventData)
 				log.Error(er.Error())
@@ -277,6 +480,14 @@ func main() {
 				log.Error(er.Message)
 			case twitch.EventLoginSuccess:
 				log.Info("Logged in into Twitch Chat")
+			case twitch.EventDisconnected, twitch.EventReconnecting, twitch.EventReconnected:
+				OnConnectionEvent(e.(*twitch.ConnectionEventData))
+			case twitch.EventMessageDropped:
+				d := e.(*twitch.SendQueueEventData)
+				log.Warn("Chat message dropped (%s, %s priority): %s", d.Reason, d.Priority, d.Message)
+			case twitch.EventMessageDelayed:
+				d := e.(*twitch.SendQueueEventData)
+				log.Warn("Chat message delayed %s (%s priority): %s", d.Delay.Round(time.Second), d.Priority, d.Message)
 			}
 		// case <-msgTimer.C:
 		// 	ev.Publish(wimatrix.EvSetSpeed, int(20))
@@ -285,5 +496,4 @@ func main() {
 		}
 	}
 
-	// mqttClient.Disconnect(0)
 }
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/permissions"
+)
+
+var log = slog.Scope("Commands")
+
+// Languages of the help text
+const (
+	LangPT = "pt"
+	LangEN = "en"
+)
+
+// Handler runs a parsed command
+type Handler func(call *Call) error
+
+// Command is a chat command and the arguments it takes
+type Command struct {
+	// Name is like "!color". Names with spaces, like "fake sub", match the first words of the message
+	Name    string
+	Aliases []string
+	Args    []Arg
+	// Target accepts an optional @panel at the start or the end of the arguments
+	Target bool
+	// Permission is who can use the command. See Registry.SetPermission to override it
+	Permission permissions.Rule
+	// Cooldown limits the uses by anyone, by each user and by the command group.
+	// See Registry.SetCooldown to override it
+	Cooldown cooldown.Rules
+	// Hidden commands are not listed by Visible
+	Hidden bool
+	// Help text by language, see LangPT and LangEN
+	Help    map[string]string
+	Handler Handler
+}
+
+// Caller is who sent the command
+type Caller struct {
+	User  string
+	Roles permissions.User
+}
+
+// Registry has the chat commands by name and alias
+type Registry struct {
+	sync.Mutex
+	commands []*Command
+	byName   map[string]*Command
+	maxWords int
+	isTarget func(target string) bool
+	checker  *permissions.Checker
+	limiter  *cooldown.Limiter
+	exempt   permissions.Level
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byName:  map[string]*Command{},
+		checker: permissions.NewChecker(nil),
+		limiter: cooldown.NewLimiter(),
+		exempt:  permissions.Subscriber,
+	}
+}
+
+// SetTargets sets the function that tells if "@name" is a panel target, used by the Target commands
+func (r *Registry) SetTargets(isTarget func(target string) bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.isTarget = isTarget
+}
+
+// SetChecker sets the permission checker, that looks up followers and has the deny list
+func (r *Registry) SetChecker(checker *permissions.Checker) {
+	r.Lock()
+	defer r.Unlock()
+	r.checker = checker
+}
+
+// SetPermission replaces the permission of the command with the name or alias
+func (r *Registry) SetPermission(name string, rule permissions.Rule) error {
+	c, ok := r.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	c.Permission = rule
+
+	return nil
+}
+
+// SetCooldown replaces the cooldown of the command with the name or alias
+func (r *Registry) SetCooldown(name string, rules cooldown.Rules) error {
+	c, ok := r.Get(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	c.Cooldown = rules
+
+	return nil
+}
+
+// SetLimiter replaces the cooldown limiter, to share it with other users of the cooldowns
+func (r *Registry) SetLimiter(limiter *cooldown.Limiter) {
+	r.Lock()
+	defer r.Unlock()
+	r.limiter = limiter
+}
+
+// SetExempt sets the lowest badge level that skips the cooldowns. Defaults to subscriber
+func (r *Registry) SetExempt(level permissions.Level) {
+	r.Lock()
+	defer r.Unlock()
+	r.exempt = level
+}
+
+// Register adds the commands. Nothing is added if any of them is invalid or uses a name already taken
+func (r *Registry) Register(cmds ...*Command) error {
+	r.Lock()
+	defer r.Unlock()
+
+	taken := map[string]bool{}
+	for _, c := range cmds {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("command %q: %s", c.Name, err)
+		}
+		for _, name := range c.names() {
+			if _, ok := r.byName[name]; ok || taken[name] {
+				return fmt.Errorf("command %q: name %q already registered", c.Name, name)
+			}
+			taken[name] = true
+		}
+	}
+
+	for _, c := range cmds {
+		r.commands = append(r.commands, c)
+		for _, name := range c.names() {
+			r.byName[name] = c
+			if n := len(strings.Fields(name)); n > r.maxWords {
+				r.maxWords = n
+			}
+		}
+	}
+
+	return nil
+}
+
+// Unregister removes the command with the name or alias, with all its names
+func (r *Registry) Unregister(name string) bool {
+	c, ok := r.Get(name)
+	if !ok {
+		return false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, n := range c.names() {
+		delete(r.byName, n)
+	}
+	for i, rc := range r.commands {
+		if rc == c {
+			r.commands = append(r.commands[:i], r.commands[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// Get returns the command with the name or alias. The "!" is optional
+func (r *Registry) Get(name string) (*Command, bool) {
+	name = normalizeName(name)
+
+	r.Lock()
+	defer r.Unlock()
+
+	if c, ok := r.byName[name]; ok {
+		return c, true
+	}
+	c, ok := r.byName["!"+name]
+	return c, ok
+}
+
+// Commands returns the commands in the order they were registered
+func (r *Registry) Commands() []*Command {
+	r.Lock()
+	defer r.Unlock()
+	return append([]*Command{}, r.commands...)
+}
+
+// Visible returns the commands not hidden that u can use, in the order they were registered.
+// Follower commands are listed without looking up the follow
+func (r *Registry) Visible(u permissions.User) []*Command {
+	var list []*Command
+	for _, c := range r.Commands() {
+		r.Lock()
+		rule := c.Permission
+		r.Unlock()
+		if !c.Hidden && (rule.Level <= permissions.Follower || u.Level() >= rule.Level) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Execute parses msg and runs the command. It returns the command found, or nil if msg is not a command.
+// The error is a *permissions.Denied, an *ArgError, a *cooldown.Error or the one returned by the handler
+func (r *Registry) Execute(msg string, caller Caller, context interface{}) (*Command, error) {
+	c, name, rest := r.match(msg)
+	if c == nil {
+		return nil, nil
+	}
+
+	r.Lock()
+	checker := r.checker
+	rule := c.Permission
+	limiter := r.limiter
+	rules := c.Cooldown
+	exempt := caller.Roles.Level() >= r.exempt
+	r.Unlock()
+
+	if err := checker.Check(caller.Roles, rule); err != nil {
+		return c, err
+	}
+
+	call, err := r.parseCall(c, name, rest)
+	if err != nil {
+		return c, err
+	}
+	call.Caller = caller
+	call.Context = context
+
+	if !exempt {
+		if err := limiter.Allow(c.Name, caller.User, rules); err != nil {
+			return c, err
+		}
+	}
+
+	log.Debug("%s used %s", caller.User, c.Name)
+	return c, c.Handler(call)
+}
+
+func (c *Command) validate() error {
+	if normalizeName(c.Name) == "" {
+		return fmt.Errorf("command without name")
+	}
+	if c.Handler == nil {
+		return fmt.Errorf("command without handler")
+	}
+
+	optional := false
+	for i, a := range c.Args {
+		if a.Name == "" {
+			return fmt.Errorf("argument %d without name", i+1)
+		}
+		if a.Type == Text && i != len(c.Args)-1 {
+			return fmt.Errorf("text argument %q must be the last one", a.Name)
+		}
+		if optional && !a.Optional {
+			return fmt.Errorf("required argument %q after an optional one", a.Name)
+		}
+		optional = optional || a.Optional
+	}
+
+	return nil
+}
+
+func (c *Command) names() []string {
+	names := []string{normalizeName(c.Name)}
+	for _, alias := range c.Aliases {
+		names = append(names, normalizeName(alias))
+	}
+	return names
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
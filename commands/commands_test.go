@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/racerxdl/twitchled/cooldown"
+	"github.com/racerxdl/twitchled/permissions"
+)
+
+func nop(call *Call) error { return nil }
+
+// testRegistry has commands like the bot ones, with the panels @left and @all
+func testRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	r.SetTargets(func(target string) bool {
+		return target == "@left" || target == "@all"
+	})
+
+	err := r.Register(
+		&Command{Name: "!color", Aliases: []string{"!cor"}, Args: []Arg{{Name: "color"}}, Target: true, Handler: nop},
+		&Command{Name: "!bright", Args: []Arg{{Name: "value", Type: Float}}, Target: true, Handler: nop},
+		&Command{Name: "!speed", Args: []Arg{{Name: "speed", Type: Int}}, Target: true, Handler: nop},
+		&Command{Name: "!painel", Args: []Arg{{Name: "message", Type: Text}}, Target: true, Handler: nop},
+		&Command{Name: "!streamtitle", Args: []Arg{{Name: "title", Type: Text, Optional: true}}, Handler: nop},
+		&Command{Name: "!timer", Args: []Arg{{Name: "name"}, {Name: "minutes", Type: Int, Optional: true}}, Handler: nop},
+		&Command{Name: "!here", Handler: nop},
+		&Command{Name: "fake", Handler: nop},
+		&Command{Name: "fake sub", Args: []Arg{{Name: "months", Type: Int, Optional: true}}, Handler: nop},
+		&Command{Name: "fake sub gift", Handler: nop},
+	)
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	r := testRegistry(t)
+
+	tests := []struct {
+		msg     string
+		command string
+		name    string
+		target  string
+		args    map[string]interface{}
+	}{
+		{"!color red", "!color", "!color", "", map[string]interface{}{"color": "red"}},
+		{"!COLOR red", "!color", "!color", "", map[string]interface{}{"color": "red"}},
+		{"!cor #FF0000", "!color", "!cor", "", map[string]interface{}{"color": "#FF0000"}},
+		{"!color @left red", "!color", "!color", "@left", map[string]interface{}{"color": "red"}},
+		{"!color red @all", "!color", "!color", "@all", map[string]interface{}{"color": "red"}},
+		{"!bright 0.5", "!bright", "!bright", "", map[string]interface{}{"value": 0.5}},
+		{"!bright 1 @left", "!bright", "!bright", "@left", map[string]interface{}{"value": 1.0}},
+		{"!speed 60", "!speed", "!speed", "", map[string]interface{}{"speed": 60}},
+		{"!speed -3", "!speed", "!speed", "", map[string]interface{}{"speed": -3}},
+		{"!painel HUEBOT   is so cool", "!painel", "!painel", "", map[string]interface{}{"message": "HUEBOT is so cool"}},
+		{"!painel @left hello @someone", "!painel", "!painel", "@left", map[string]interface{}{"message": "hello @someone"}},
+		{"!painel hello @someone", "!painel", "!painel", "", map[string]interface{}{"message": "hello @someone"}},
+		// A lone @word is the message, not the target
+		{"!painel @left", "!painel", "!painel", "", map[string]interface{}{"message": "@left"}},
+		{"!color @left", "!color", "!color", "", map[string]interface{}{"color": "@left"}},
+		{"!streamtitle", "!streamtitle", "!streamtitle", "", map[string]interface{}{}},
+		{"!streamtitle Coding a bot", "!streamtitle", "!streamtitle", "", map[string]interface{}{"title": "Coding a bot"}},
+		{"!timer water", "!timer", "!timer", "", map[string]interface{}{"name": "water"}},
+		{"!timer water 15", "!timer", "!timer", "", map[string]interface{}{"name": "water", "minutes": 15}},
+		{"!here everyone?", "!here", "!here", "", map[string]interface{}{}},
+		{"fake", "fake", "fake", "", map[string]interface{}{}},
+		{"fake sub", "fake sub", "fake sub", "", map[string]interface{}{}},
+		{"FAKE  SUB 3", "fake sub", "fake sub", "", map[string]interface{}{"months": 3}},
+		{"fake sub gift", "fake sub gift", "fake sub gift", "", map[string]interface{}{}},
+		{"fake follow", "fake", "fake", "", map[string]interface{}{}},
+	}
+
+	for _, tt := range tests {
+		call, err := r.Parse(tt.msg)
+		if err != nil {
+			t.Errorf("Parse(%q): %s", tt.msg, err)
+			continue
+		}
+		if call == nil {
+			t.Errorf("Parse(%q) is not a command", tt.msg)
+			continue
+		}
+		if call.Command.Name != tt.command || call.Name != tt.name || call.Target != tt.target {
+			t.Errorf("Parse(%q) = %s as %q to %q, want %s as %q to %q", tt.msg, call.Command.Name, call.Name, call.Target, tt.command, tt.name, tt.target)
+		}
+		if !reflect.DeepEqual(call.args, tt.args) {
+			t.Errorf("Parse(%q) args = %v, want %v", tt.msg, call.args, tt.args)
+		}
+	}
+}
+
+func TestParseNotCommand(t *testing.T) {
+	r := testRegistry(t)
+
+	for _, msg := range []string{"", "   ", "hello !color red", "!colors red", "!unknown", "fakesub"} {
+		call, err := r.Parse(msg)
+		if call != nil || err != nil {
+			t.Errorf("Parse(%q) = %v, %v, want not a command", msg, call, err)
+		}
+	}
+}
+
+func TestParseArgErrors(t *testing.T) {
+	r := testRegistry(t)
+
+	tests := []struct {
+		msg string
+		arg string
+	}{
+		{"!color", "color"},
+		{"!color red blue", ""},
+		{"!bright high", "value"},
+		{"!speed 1.5", "speed"},
+		{"!speed", "speed"},
+		{"!painel", "message"},
+		{"!timer water soon", "minutes"},
+		{"!timer water 15 now", ""},
+		{"fake sub many", "months"},
+	}
+
+	for _, tt := range tests {
+		call, err := r.Parse(tt.msg)
+		argErr, ok := err.(*ArgError)
+		if !ok {
+			t.Errorf("Parse(%q) = %v, %v, want an *ArgError", tt.msg, call, err)
+			continue
+		}
+		if argErr.Arg != tt.arg {
+			t.Errorf("Parse(%q) error on %q, want %q: %s", tt.msg, argErr.Arg, tt.arg, err)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	r := NewRegistry()
+	var got *Call
+	err := r.Register(&Command{
+		Name:       "!title",
+		Args:       []Arg{{Name: "title", Type: Text}},
+		Permission: permissions.Rule{Level: permissions.Moderator},
+		Handler: func(call *Call) error {
+			got = call
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	mod := Caller{User: "mod", Roles: permissions.User{Login: "mod", Moderator: true}}
+	viewer := Caller{User: "viewer", Roles: permissions.User{Login: "viewer"}}
+
+	if c, err := r.Execute("hello", mod, nil); c != nil || err != nil {
+		t.Errorf("Execute(hello) = %v, %v, want not a command", c, err)
+	}
+
+	if _, err := r.Execute("!title hi", viewer, nil); err == nil {
+		t.Errorf("viewer ran a moderator command")
+	} else if _, ok := err.(*permissions.Denied); !ok {
+		t.Errorf("viewer error = %T, want *permissions.Denied", err)
+	}
+	if got != nil {
+		t.Errorf("handler ran for a denied call")
+	}
+
+	if _, err := r.Execute("!title", mod, nil); err == nil {
+		t.Errorf("Execute without the title did not fail")
+	}
+
+	if _, err := r.Execute("!title New title", mod, "context"); err != nil {
+		t.Fatalf("Execute: %s", err)
+	}
+	if got == nil || got.String("title") != "New title" || got.Caller.User != "mod" || got.Context != "context" {
+		t.Errorf("handler got %+v", got)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	r := testRegistry(t)
+
+	tests := []*Command{
+		{Name: "", Handler: nop},
+		{Name: "!nohandler"},
+		{Name: "!cor", Handler: nop},
+		{Name: "!new", Aliases: []string{"!COLOR"}, Handler: nop},
+		{Name: "!text", Args: []Arg{{Name: "text", Type: Text}, {Name: "n", Type: Int}}, Handler: nop},
+		{Name: "!optional", Args: []Arg{{Name: "a", Optional: true}, {Name: "b"}}, Handler: nop},
+	}
+
+	for _, c := range tests {
+		if err := r.Register(c); err == nil {
+			t.Errorf("Register(%q) did not fail", c.Name)
+		}
+	}
+
+	// A failed Register adds nothing
+	if err := r.Register(&Command{Name: "!ok", Handler: nop}, &Command{Name: "!here", Handler: nop}); err == nil {
+		t.Fatalf("Register with a taken name did not fail")
+	}
+	if _, ok := r.Get("!ok"); ok {
+		t.Errorf("!ok was registered by a failed Register")
+	}
+
+	if !r.Unregister("cor") {
+		t.Fatalf("Unregister(cor) = false")
+	}
+	if _, ok := r.Get("!color"); ok {
+		t.Errorf("!color is still registered after removing its alias")
+	}
+}
+
+func TestCooldownExempt(t *testing.T) {
+	r := NewRegistry()
+	limiter := cooldown.NewLimiter()
+	now := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	limiter.SetNow(func() time.Time { return now })
+	r.SetLimiter(limiter)
+
+	err := r.Register(&Command{
+		Name:     "!painel",
+		Args:     []Arg{{Name: "message", Type: Text}},
+		Cooldown: cooldown.Rules{User: cooldown.Limit{Every: time.Minute}},
+		Handler:  nop,
+	})
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	callers := []struct {
+		caller Caller
+		exempt bool
+	}{
+		{Caller{User: "viewer", Roles: permissions.User{Login: "viewer"}}, false},
+		{Caller{User: "vip", Roles: permissions.User{Login: "vip", VIP: true}}, true},
+		{Caller{User: "sub", Roles: permissions.User{Login: "sub", Subscriber: true, SubTier: 1}}, true},
+		{Caller{User: "mod", Roles: permissions.User{Login: "mod", Moderator: true}}, true},
+	}
+
+	// Subscribers and above skip the cooldowns by default
+	for _, c := range callers {
+		r.Execute("!painel hi", c.caller, nil)
+		_, err := r.Execute("!painel hi", c.caller, nil)
+		if _, ok := err.(*cooldown.Error); ok == c.exempt {
+			t.Errorf("%s second use = %v, exempt %v", c.caller.User, err, c.exempt)
+		}
+	}
+
+	// Only moderators and the broadcaster
+	r.SetExempt(permissions.Moderator)
+	now = now.Add(time.Hour)
+	for _, c := range callers {
+		r.Execute("!painel hi", c.caller, nil)
+		_, err := r.Execute("!painel hi", c.caller, nil)
+		exempt := c.caller.User == "mod"
+		if _, ok := err.(*cooldown.Error); ok == exempt {
+			t.Errorf("moderator exempt: %s second use = %v, exempt %v", c.caller.User, err, exempt)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"strings"
+)
+
+// Usage returns how to call the command, like "!bright VALUE [@panel]"
+func (c *Command) Usage() string {
+	parts := []string{c.Name}
+
+	for _, a := range c.Args {
+		name := strings.ToUpper(a.Name)
+		if a.Type == Text {
+			name += "..."
+		}
+		if a.Optional {
+			name = "[" + name + "]"
+		}
+		parts = append(parts, name)
+	}
+
+	if c.Target {
+		parts = append(parts, "[@panel]")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// HelpText returns the help of the command in lang, falling back to English and then to the usage
+func (c *Command) HelpText(lang string) string {
+	if h, ok := c.Help[lang]; ok {
+		return h
+	}
+	if h, ok := c.Help[LangEN]; ok {
+		return h
+	}
+	return c.Usage()
+}
+
+// List returns the names of the commands separated by spaces
+func List(cmds []*Command) string {
+	names := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, " ")
+}
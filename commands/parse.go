@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ArgType is how an argument is parsed
+type ArgType int
+
+const (
+	// Word is a single word
+	Word ArgType = iota
+	// Text is the rest of the message. It must be the last argument
+	Text
+	// Int is an integer number
+	Int
+	// Float is a decimal number, like 0.5
+	Float
+)
+
+// Arg is an argument of a command
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+}
+
+// ArgError is returned when the message does not match the command arguments
+type ArgError struct {
+	Command *Command
+	Arg     string
+	Reason  string
+}
+
+func (e *ArgError) Error() string {
+	if e.Arg == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Arg, e.Reason)
+}
+
+// Call is a parsed command message
+type Call struct {
+	Command *Command
+	// Name is the name or alias used
+	Name   string
+	Caller Caller
+	// Target is the @panel given to a Target command, or empty
+	Target string
+	// Context is passed as is from Execute, like the chat message being handled
+	Context interface{}
+	args    map[string]interface{}
+}
+
+// Has returns true if the optional argument was given
+func (c *Call) Has(name string) bool {
+	_, ok := c.args[name]
+	return ok
+}
+
+// String returns a Word or Text argument
+func (c *Call) String(name string) string {
+	v, _ := c.args[name].(string)
+	return v
+}
+
+// Int returns an Int argument
+func (c *Call) Int(name string) int {
+	v, _ := c.args[name].(int)
+	return v
+}
+
+// Float returns a Float argument
+func (c *Call) Float(name string) float64 {
+	v, _ := c.args[name].(float64)
+	return v
+}
+
+// Parse returns the command call in msg without running it. The call is nil if msg is not a command
+func (r *Registry) Parse(msg string) (*Call, error) {
+	c, name, rest := r.match(msg)
+	if c == nil {
+		return nil, nil
+	}
+	return r.parseCall(c, name, rest)
+}
+
+// match finds the command in the first words of msg. Longer names are tried first,
+// so "fake sub" wins over "fake"
+func (r *Registry) match(msg string) (*Command, string, string) {
+	fields := strings.Fields(msg)
+
+	r.Lock()
+	defer r.Unlock()
+
+	n := len(fields)
+	if n > r.maxWords {
+		n = r.maxWords
+	}
+	for ; n > 0; n-- {
+		name := strings.ToLower(strings.Join(fields[:n], " "))
+		if c, ok := r.byName[name]; ok {
+			return c, name, strings.Join(fields[n:], " ")
+		}
+	}
+
+	return nil, "", ""
+}
+
+func (r *Registry) parseCall(c *Command, name, rest string) (*Call, error) {
+	call := &Call{
+		Command: c,
+		Name:    name,
+		args:    map[string]interface{}{},
+	}
+
+	fields := strings.Fields(rest)
+
+	if c.Target {
+		r.Lock()
+		isTarget := r.isTarget
+		r.Unlock()
+		call.Target, fields = splitTarget(fields, isTarget)
+	}
+
+	for i, a := range c.Args {
+		if len(fields) == 0 {
+			if a.Optional {
+				break
+			}
+			return nil, &ArgError{Command: c, Arg: a.Name, Reason: "missing"}
+		}
+
+		if a.Type == Text {
+			call.args[a.Name] = strings.Join(fields, " ")
+			fields = nil
+			break
+		}
+
+		v, err := parseArg(a.Type, fields[0])
+		if err != nil {
+			return nil, &ArgError{Command: c, Arg: c.Args[i].Name, Reason: err.Error()}
+		}
+		call.args[a.Name] = v
+		fields = fields[1:]
+	}
+
+	// Commands without arguments ignore the rest of the message, like "!here everyone?"
+	if len(fields) > 0 && len(c.Args) > 0 {
+		return nil, &ArgError{Command: c, Reason: fmt.Sprintf("unexpected %q", strings.Join(fields, " "))}
+	}
+
+	return call, nil
+}
+
+func parseArg(t ArgType, s string) (interface{}, error) {
+	switch t {
+	case Int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return v, nil
+	case Float:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return v, nil
+	}
+	return s, nil
+}
+
+// splitTarget extracts an optional "@panel" target from the start or end of the fields.
+// A lone "@word" is kept as an argument
+func splitTarget(fields []string, isTarget func(string) bool) (string, []string) {
+	if len(fields) < 2 || isTarget == nil {
+		return "", fields
+	}
+
+	if first := fields[0]; strings.HasPrefix(first, "@") && isTarget(first) {
+		return first, fields[1:]
+	}
+
+	if last := fields[len(fields)-1]; strings.HasPrefix(last, "@") && isTarget(last) {
+		return last, fields[:len(fields)-1]
+	}
+
+	return "", fields
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/BurntSushi/toml"
 	"github.com/mewkiz/pkg/osutil"
@@ -15,6 +16,8 @@ type GeneralConfig struct {
 	User                  string
 	Pass                  string
 	DeviceName            string
+	Display               string
+	WLEDHost              string
 	TwitchOAuthClient     string
 	TwitchOAuthSecret     string
 	TwitchTokenData       string
@@ -29,11 +32,306 @@ type GeneralConfig struct {
 	DiscordClipOutputUrl  string
 	LogIgnoreList         string
 	OpenAIKey             string
+	Alerts                AlertTemplates
+	// Playlists rotate on the panel while there are no alerts
+	Playlists Playlists
+	// SubGoal is the sub goal shown on the subgoal playlist item
+	SubGoal int
+	// EmoteCDN is the base url of the emote images. Defaults to the twitch CDN
+	EmoteCDN string
+	// EmoteStandIns replace emotes by name on panels without pixel frames, like Kappa = ";)"
+	EmoteStandIns map[string]string
+	// EmoteStandIn replaces the emotes without a stand-in. Defaults to ":)"
+	EmoteStandIn string
+	// EffectMaxRate is the maximum panel updates per second sent by effects. Defaults to 10
+	EffectMaxRate float64
+	// Devices is the [[devices]] list. If empty, a single device is made from DeviceName
+	Devices []DeviceConfig
+	// Actions are the [[actions]] that chat commands and rewards can trigger
+	Actions []ActionConfig
+	// HomeAssistantUrl is the base url of the Home Assistant REST API, like "http://127.0.0.1:8123"
+	HomeAssistantUrl string
+	// HomeAssistantToken is a Home Assistant long-lived access token
+	HomeAssistantToken string
+	// Moderation filters the viewer text sent to the panel
+	Moderation ModerationConfig
+	// Rewards are the [[rewards]] created and updated on Twitch at startup. Managing rewards and
+	// redemptions needs the channel:manage:redemptions scope: tokens from older versions must be
+	// authorized again by clearing TwitchTokenData
+	Rewards []RewardConfig
+	// Permissions sets who can use the chat commands
+	Permissions PermissionsConfig
+	// Cooldowns limits how often the chat commands and the AI can be used
+	Cooldowns CooldownsConfig
+	// DatabaseFile stores the custom commands, the timers and the !lang choices. Defaults to twitchled.db
+	DatabaseFile string
+	// Language sets the languages of the chat replies and of the panel
+	Language LanguageConfig
+}
+
+// LanguageConfig sets the languages of the bot messages
+type LanguageConfig struct {
+	// Languages are the languages of the chat replies. Each reply is sent once per language,
+	// in order, so a single language stops the bilingual replies. Defaults to ["pt", "en"]
+	Languages []string
+	// Channels overrides Languages by channel name
+	Channels map[string][]string
+	// Panel is the language of the panel texts. Defaults to the first of Languages
+	Panel string
+	// Dir has LANG.toml catalogs that add languages or replace built-in messages
+	Dir string
+}
+
+// ChannelLanguages returns the reply languages of channel
+func (l LanguageConfig) ChannelLanguages(channel string) []string {
+	if langs := l.Channels[strings.ToLower(channel)]; len(langs) > 0 {
+		return langs
+	}
+	if len(l.Languages) > 0 {
+		return l.Languages
+	}
+	return []string{"pt", "en"}
+}
+
+// PanelLanguage returns the language of the panel texts
+func (l LanguageConfig) PanelLanguage() string {
+	if l.Panel != "" {
+		return l.Panel
+	}
+	return l.ChannelLanguages("")[0]
+}
+
+// CooldownsConfig are the rate limits of the viewers
+type CooldownsConfig struct {
+	// Exempt is the lowest level that skips the cooldowns, or "none". Defaults to subscriber
+	Exempt string
+	// Groups are limits shared by commands, like [Cooldowns.Groups.panel] for the panel commands
+	Groups map[string]CooldownLimit
+	// Users that hit a cooldown Strikes times within StrikeWindow are locked out of every command
+	// for Lockout. Defaults to 5 strikes in 1m for 5m, a negative Strikes disables it
+	Strikes      int
+	StrikeWindow string
+	Lockout      string
+	// Commands replaces the cooldown of the commands by name, like [Cooldowns.Commands."!color"]
+	Commands map[string]CooldownRule
+	// AI limits the answers to mentions of the streamer
+	AI CooldownRule
+	// Javascripto limits the javascripto sound
+	Javascripto CooldownRule
+}
+
+// CooldownLimit allows one use every Every, a Go duration, with up to Burst uses back to back
+type CooldownLimit struct {
+	Every string
+	Burst int
+}
+
+// CooldownRule are the limits of a command
+type CooldownRule struct {
+	// Command is shared by every user and User is per user
+	Command CooldownLimit
+	User    CooldownLimit
+	// Group is the name of a [Cooldowns.Groups] limit
+	Group string
+}
+
+// PermissionsConfig overrides the roles needed by the chat commands
+type PermissionsConfig struct {
+	// Deny has the user ids that cannot use any command
+	Deny []string
+	// FollowerCacheTime is how long a follower lookup is kept, like "10m". Defaults to 10m
+	FollowerCacheTime string
+	// Commands replaces the permission of the commands by name, like [Permissions.Commands."!color"]
+	Commands map[string]PermissionRule
+}
+
+// PermissionRule is who can use a command
+type PermissionRule struct {
+	// Level is everyone, follower, subscriber, vip, moderator or broadcaster
+	Level string
+	// MinTier (1 to 3) and MinMonths restrict the subscriber level
+	MinTier   int
+	MinMonths int
+	// Allow and Deny are user ids that can always or never use the command
+	Allow []string
+	Deny  []string
+}
+
+// RewardConfig is a channel point reward managed by the bot. Redemptions are matched by Id,
+// so renaming the reward does not break it
+type RewardConfig struct {
+	// Action is what the reward does: panel, codereview or the name of an [[actions]] entry
+	Action        string
+	Title         string
+	Cost          int
+	Prompt        string
+	InputRequired bool
+	// Cooldown is a Go duration, like "5m"
+	Cooldown string
+	// Color is the background color, like "#9147FF"
+	Color string
+	// PauseWhenPanelOffline pauses the reward while every panel is offline
+	PauseWhenPanelOffline bool
+	// PauseWhenStreamOffline pauses the reward while the stream is offline
+	PauseWhenStreamOffline bool
+	// Id is filled by the bot when the reward is created
+	Id string
+}
+
+// ModerationConfig are the rules of the viewer text shown on the panel
+type ModerationConfig struct {
+	// BannedWords are rejected as whole words, ignoring case, accents and leetspeak like "h4ck3r"
+	BannedWords []string
+	// BannedPatterns are regular expressions, matched ignoring case
+	BannedPatterns []string
+	// MaxLength is the maximum number of characters. Defaults to 140
+	MaxLength int
+	// AllowUrls accepts texts with links
+	AllowUrls bool
+	// RequireApproval holds the texts until a moderator approves them with !approve
+	RequireApproval bool
+	// ApprovalTimeout is a Go duration after which pending texts are rejected. Defaults to 10m
+	ApprovalTimeout string
+}
+
+// DeviceConfig is a LED panel. Empty connection fields use the general config
+type DeviceConfig struct {
+	Name string
+	// Tags are groups that can be targeted by chat commands, like "desk"
+	Tags     []string
+	Display  string
+	Host     string
+	User     string
+	Pass     string
+	WLEDHost string
+	// Width and Height are the panel resolution, used by the pixel frames
+	Width  int
+	Height int
+}
+
+// GetDevices returns the configured devices with the defaults filled
+func (c GeneralConfig) GetDevices() []DeviceConfig {
+	if len(c.Devices) == 0 {
+		return []DeviceConfig{{
+			Name:     c.DeviceName,
+			Display:  c.Display,
+			Host:     c.Host,
+			User:     c.User,
+			Pass:     c.Pass,
+			WLEDHost: c.WLEDHost,
+		}}
+	}
+
+	devices := make([]DeviceConfig, 0, len(c.Devices))
+	for _, d := range c.Devices {
+		if d.Display == "" {
+			d.Display = c.Display
+		}
+		if d.Host == "" {
+			d.Host = c.Host
+			d.User = c.User
+			d.Pass = c.Pass
+		}
+		if d.WLEDHost == "" {
+			d.WLEDHost = c.WLEDHost
+		}
+		devices = append(devices, d)
+	}
+
+	return devices
+}
+
+// ActionConfig is a named home automation action
+type ActionConfig struct {
+	Name string
+	// Command is the chat command that triggers the action, like "!light"
+	Command string
+	// Reward is the title of the channel point reward that triggers the action
+	Reward string
+	// Permission is everyone, subscriber, vip, moderator or broadcaster. Defaults to everyone.
+	// Rewards are paid, so they skip it
+	Permission string
+	// Cooldown is a Go duration, like "30s"
+	Cooldown string
+	Steps    []ActionStep
+}
+
+// ActionStep is a step of an action. Steps run in order
+type ActionStep struct {
+	// Type is mqtt, http or homeassistant
+	Type string
+	// Delay is a Go duration waited before the step, like "10ms"
+	Delay string
+	// Topic and Payload are published by mqtt steps
+	Topic   string
+	Payload string
+	// Method, Url, Body and Headers are the request of http steps. Method defaults to POST
+	Method  string
+	Url     string
+	Body    string
+	Headers map[string]string
+	// Service is the Home Assistant service, like "light.toggle"
+	Service string
+	// EntityId is the Home Assistant entity, like "light.office"
+	EntityId string
+	// Data is added to the service call data
+	Data map[string]string
+}
+
+// AlertTemplate configures how an alert is shown on the panel. Empty fields use the defaults
+type AlertTemplate struct {
+	// Text has named variables like {user}, {months}, {bits}, {viewers} and {reward}.
+	// Empty uses the alert message of the panel language
+	Text       string
+	Mode       string
+	TextColor  string
+	BGColor    string
+	Brightness float32
+	Speed      int
+	// Duration is a Go duration, like "20s"
+	Duration string
+	// Repeat shows the text again after each duration
+	Repeat int
+	// Effect is an effect with parameters, like "rainbow period=2s" or "pulse min=0.2 target=bg"
+	Effect string
+	// MinBits and MinMonths select the variant for bits and subs. The highest one reached is used
+	MinBits   int
+	MinMonths int
+}
+
+// PlaylistItem is a screen of the idle playlist. Empty fields use the panel settings
+type PlaylistItem struct {
+	// Type is clock, title, follower, subgoal, message or panel (the last panel message)
+	Type string
+	// Text is the message of the message type, or replaces the default text of the others.
+	// It has the variables {title}, {user}, {subs} and {goal}
+	Text      string
+	Mode      string
+	TextColor string
+	BGColor   string
+	Effect    string
+	// Duration is a Go duration, like "30s"
+	Duration string
+}
+
+// Playlists are the idle playlists used while the stream is online and offline
+type Playlists struct {
+	Online  []PlaylistItem
+	Offline []PlaylistItem
+}
+
+// AlertTemplates has the template variants of each alert type
+type AlertTemplates struct {
+	Sub    []AlertTemplate
+	Bits   []AlertTemplate
+	Follow []AlertTemplate
+	Raid   []AlertTemplate
+	Reward []AlertTemplate
 }
 
 func IsOnIgnoreList(username string) bool {
 	username = strings.ToLower(username)
-	ignoreList := strings.Split(config.LogIgnoreList, ",")
+	ignoreList := strings.Split(GetConfig().LogIgnoreList, ",")
 
 	for _, v := range ignoreList {
 		v = strings.ToLower(strings.Trim(v, " \r\n"))
@@ -47,6 +345,8 @@ func IsOnIgnoreList(username string) bool {
 
 const configFile = "twitchled.toml"
 
+// configLock guards config, that is changed and saved by the token refreshes of other goroutines
+var configLock sync.RWMutex
 var config GeneralConfig
 
 var log = slog.Scope("MCP2MQTT")
@@ -55,18 +355,63 @@ func GetCacheFileName() string {
 	return os.Getenv("TW_CACHE_PREFIX") + "cacheclips.bin"
 }
 
+func GetStateFileName(device string) string {
+	return os.Getenv("TW_CACHE_PREFIX") + "wimatrix-" + device + ".json"
+}
+
+// GetDatabaseFileName returns the DatabaseFile of the config or the default one
+func GetDatabaseFileName() string {
+	if f := GetConfig().DatabaseFile; f != "" {
+		return f
+	}
+	return os.Getenv("TW_CACHE_PREFIX") + "twitchled.db"
+}
+
 func GetConfig() GeneralConfig {
+	configLock.RLock()
+	defer configLock.RUnlock()
 	return config
 }
 
+// SetConfig replaces the loaded config without saving it to disk
+func SetConfig(c GeneralConfig) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config = c
+}
+
 func SetTwitchToken(tokenData []byte) {
+	configLock.Lock()
+	defer configLock.Unlock()
 	config.TwitchTokenData = base64.StdEncoding.EncodeToString(tokenData)
-	SaveConfig()
+	saveConfig()
 }
 
 func SetTwitchAppTokenData(tokenData []byte) {
+	configLock.Lock()
+	defer configLock.Unlock()
 	config.TwitchAppTokenData = base64.StdEncoding.EncodeToString(tokenData)
-	SaveConfig()
+	saveConfig()
+}
+
+// SetRewardId stores the Twitch id of the reward with the title.
+// The rewards are copied, since GetConfig callers share the old slice
+func SetRewardId(title, id string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	for i, r := range config.Rewards {
+		if r.Title != title {
+			continue
+		}
+		if r.Id == id {
+			return
+		}
+		rewards := append([]RewardConfig(nil), config.Rewards...)
+		rewards[i].Id = id
+		config.Rewards = rewards
+		saveConfig()
+		return
+	}
 }
 
 func LoadConfig() {
@@ -77,6 +422,8 @@ func LoadConfig() {
 		os.Exit(1)
 	}
 
+	configLock.Lock()
+	defer configLock.Unlock()
 	_, err := toml.DecodeFile(cfg, &config)
 	if err != nil {
 		log.Error("Error decoding file %s: %s", cfg, err)
@@ -85,12 +432,19 @@ func LoadConfig() {
 }
 
 func SaveConfig() {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	saveConfig()
+}
+
+func saveConfig() {
 	cfg := os.Getenv("TW_CONFIG_PREFIX") + configFile
 	log.Info("Saving config %s", cfg)
 	f, err := os.Create(cfg)
 	if err != nil {
 		log.Fatal("Error opening %s: %s", cfg, err)
 	}
+	defer f.Close()
 	e := toml.NewEncoder(f)
 	err = e.Encode(&config)
 	if err != nil {
@@ -0,0 +1,39 @@
+package cooldown
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+)
+
+// ParseLimit converts a limit of the config. An empty Every has no limit
+func ParseLimit(cl config.CooldownLimit) (Limit, error) {
+	if cl.Every == "" {
+		return Limit{}, nil
+	}
+
+	every, err := time.ParseDuration(cl.Every)
+	if err != nil || every < 0 {
+		return Limit{}, fmt.Errorf("invalid cooldown %q", cl.Every)
+	}
+	if cl.Burst < 0 {
+		return Limit{}, fmt.Errorf("invalid burst %d", cl.Burst)
+	}
+
+	return Limit{Every: every, Burst: cl.Burst}, nil
+}
+
+// ParseRules converts the limits of a command of the config
+func ParseRules(cr config.CooldownRule) (Rules, error) {
+	key, err := ParseLimit(cr.Command)
+	if err != nil {
+		return Rules{}, err
+	}
+	user, err := ParseLimit(cr.User)
+	if err != nil {
+		return Rules{}, err
+	}
+
+	return Rules{Key: key, User: user, Group: cr.Group}, nil
+}
@@ -0,0 +1,261 @@
+package cooldown
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quan-to/slog"
+)
+
+var log = slog.Scope("Cooldown")
+
+// Limit allows one use every Every, with up to Burst uses back to back. A zero Every has no limit
+type Limit struct {
+	Every time.Duration
+	// Burst defaults to 1, a plain cooldown
+	Burst int
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// Rules are the limits checked for a use of a key, like a command name
+type Rules struct {
+	// Key is shared by every user of the key
+	Key Limit
+	// User is per user of the key
+	User Limit
+	// Group is a name set with Limiter.SetGroup, shared by every key of the group
+	Group string
+}
+
+// Scope is the limit that denied a use
+type Scope int
+
+const (
+	ScopeUser Scope = iota
+	ScopeKey
+	ScopeGroup
+	// ScopeLockout means the user is locked out for hitting the cooldowns too often
+	ScopeLockout
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeUser:
+		return "user"
+	case ScopeKey:
+		return "key"
+	case ScopeGroup:
+		return "group"
+	case ScopeLockout:
+		return "lockout"
+	}
+	return fmt.Sprintf("Scope(%d)", int(s))
+}
+
+// Error is returned by Allow while a limit is cooling down
+type Error struct {
+	Scope     Scope
+	Remaining time.Duration
+	// Started is set on the use that locked the user out, later ones are better ignored
+	Started bool
+}
+
+func (e *Error) Error() string {
+	if e.Scope == ScopeLockout {
+		return fmt.Sprintf("locked out for %s", e.Remaining.Round(time.Second))
+	}
+	return fmt.Sprintf("on cooldown for %s", e.Remaining.Round(time.Second))
+}
+
+// bucket is a token bucket. tokens is the amount at time at
+type bucket struct {
+	limit  Limit
+	tokens float64
+	at     time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += float64(now.Sub(b.at)) / float64(b.limit.Every)
+	if b.tokens > b.limit.burst() {
+		b.tokens = b.limit.burst()
+	}
+	b.at = now
+}
+
+// wait returns how long until the bucket has a token
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.limit.Every))
+}
+
+type strikes struct {
+	at          []time.Time
+	lockedUntil time.Time
+}
+
+// Limiter keeps the uses of the keys. It is safe for concurrent use
+type Limiter struct {
+	sync.Mutex
+	now     func() time.Time
+	buckets map[string]*bucket
+	groups  map[string]Limit
+	users   map[string]*strikes
+	calls   int
+
+	maxStrikes   int
+	strikeWindow time.Duration
+	lockout      time.Duration
+}
+
+// NewLimiter returns a limiter without groups or lockout
+func NewLimiter() *Limiter {
+	return &Limiter{
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+		groups:  map[string]Limit{},
+		users:   map[string]*strikes{},
+	}
+}
+
+// SetNow replaces the time source
+func (l *Limiter) SetNow(now func() time.Time) {
+	l.Lock()
+	defer l.Unlock()
+	l.now = now
+}
+
+// SetGroup sets the limit shared by the keys of the group
+func (l *Limiter) SetGroup(name string, limit Limit) {
+	l.Lock()
+	defer l.Unlock()
+	l.groups[name] = limit
+}
+
+// SetLockout locks users out of every key for duration once they hit a cooldown
+// maxStrikes times within window. Zero maxStrikes disables it
+func (l *Limiter) SetLockout(maxStrikes int, window, duration time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.maxStrikes = maxStrikes
+	l.strikeWindow = window
+	l.lockout = duration
+}
+
+// Allow takes a use of key by user. It returns an *Error, and takes nothing, if any limit is cooling down.
+// An empty user skips the user limit and the lockout
+func (l *Limiter) Allow(key, user string, rules Rules) error {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	user = strings.ToLower(user)
+
+	l.calls++
+	if l.calls%256 == 0 {
+		l.sweep(now)
+	}
+
+	if user != "" {
+		if s, ok := l.users[user]; ok && now.Before(s.lockedUntil) {
+			return &Error{Scope: ScopeLockout, Remaining: s.lockedUntil.Sub(now)}
+		}
+	}
+
+	type check struct {
+		scope Scope
+		id    string
+		limit Limit
+	}
+	checks := []check{{ScopeKey, "k\x00" + key, rules.Key}}
+	if user != "" {
+		checks = append(checks, check{ScopeUser, "u\x00" + key + "\x00" + user, rules.User})
+	}
+	if group, ok := l.groups[rules.Group]; ok && rules.Group != "" {
+		checks = append(checks, check{ScopeGroup, "g\x00" + rules.Group, group})
+	}
+
+	var taken []*bucket
+	for _, c := range checks {
+		if c.limit.Every <= 0 {
+			continue
+		}
+		b, ok := l.buckets[c.id]
+		if !ok {
+			b = &bucket{tokens: c.limit.burst(), at: now}
+			l.buckets[c.id] = b
+		}
+		b.limit = c.limit
+		b.refill(now)
+		if wait := b.wait(); wait > 0 {
+			return l.strike(user, now, &Error{Scope: c.scope, Remaining: wait})
+		}
+		taken = append(taken, b)
+	}
+
+	for _, b := range taken {
+		b.tokens--
+	}
+
+	return nil
+}
+
+// strike counts a denied use of user and locks them out after too many
+func (l *Limiter) strike(user string, now time.Time, err *Error) error {
+	if user == "" || l.maxStrikes <= 0 {
+		return err
+	}
+
+	s, ok := l.users[user]
+	if !ok {
+		s = &strikes{}
+		l.users[user] = s
+	}
+
+	recent := s.at[:0]
+	for _, t := range s.at {
+		if now.Sub(t) < l.strikeWindow {
+			recent = append(recent, t)
+		}
+	}
+	s.at = append(recent, now)
+
+	if len(s.at) >= l.maxStrikes {
+		s.at = nil
+		s.lockedUntil = now.Add(l.lockout)
+		log.Warn("%s locked out for %s", user, l.lockout)
+		return &Error{Scope: ScopeLockout, Remaining: l.lockout, Started: true}
+	}
+
+	return err
+}
+
+// Reset clears the lockout and strikes of user
+func (l *Limiter) Reset(user string) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.users, strings.ToLower(user))
+}
+
+// sweep drops the buckets that are full again and the expired strikes. Called with the lock held
+func (l *Limiter) sweep(now time.Time) {
+	for id, b := range l.buckets {
+		if b.refill(now); b.tokens >= b.limit.burst() {
+			delete(l.buckets, id)
+		}
+	}
+	for user, s := range l.users {
+		if now.After(s.lockedUntil) && (len(s.at) == 0 || now.Sub(s.at[len(s.at)-1]) > l.strikeWindow) {
+			delete(l.users, user)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package cooldown
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLimiter returns a limiter on a fake clock, moved with the returned function
+func testLimiter() (*Limiter, func(d time.Duration)) {
+	now := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	var lock sync.Mutex
+
+	l := NewLimiter()
+	l.SetNow(func() time.Time {
+		lock.Lock()
+		defer lock.Unlock()
+		return now
+	})
+
+	return l, func(d time.Duration) {
+		lock.Lock()
+		defer lock.Unlock()
+		now = now.Add(d)
+	}
+}
+
+// scopeOf returns the scope of an *Error, or -1 if err is nil
+func scopeOf(t *testing.T, err error) Scope {
+	t.Helper()
+	if err == nil {
+		return -1
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("error %T is not a *cooldown.Error: %s", err, err)
+	}
+	return e.Scope
+}
+
+func TestBurst(t *testing.T) {
+	l, advance := testLimiter()
+	rules := Rules{Key: Limit{Every: 10 * time.Second, Burst: 3}}
+
+	for i := 0; i < 3; i++ {
+		if err := l.Allow("!painel", "bob", rules); err != nil {
+			t.Fatalf("use %d: %s", i+1, err)
+		}
+	}
+
+	err := l.Allow("!painel", "bob", rules)
+	if scopeOf(t, err) != ScopeKey {
+		t.Fatalf("use after the burst = %v, want a key cooldown", err)
+	}
+	if e := err.(*Error); e.Remaining != 10*time.Second {
+		t.Errorf("remaining = %s, want 10s", e.Remaining)
+	}
+
+	// One token every 10s
+	advance(5 * time.Second)
+	if err := l.Allow("!painel", "bob", rules); err == nil {
+		t.Errorf("use after 5s was allowed")
+	}
+	advance(5 * time.Second)
+	if err := l.Allow("!painel", "bob", rules); err != nil {
+		t.Errorf("use after 10s: %s", err)
+	}
+	if err := l.Allow("!painel", "bob", rules); err == nil {
+		t.Errorf("second use after 10s was allowed")
+	}
+
+	// The bucket does not fill over the burst
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := l.Allow("!painel", "bob", rules); err != nil {
+			t.Fatalf("use %d after refill: %s", i+1, err)
+		}
+	}
+	if err := l.Allow("!painel", "bob", rules); err == nil {
+		t.Errorf("use over the burst after an hour was allowed")
+	}
+}
+
+func TestUserAndKeyIndependent(t *testing.T) {
+	l, advance := testLimiter()
+	rules := Rules{
+		Key:  Limit{Every: 5 * time.Second},
+		User: Limit{Every: 30 * time.Second},
+	}
+
+	if err := l.Allow("!ai", "bob", rules); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow("!ai", "alice", rules); scopeOf(t, err) != ScopeKey {
+		t.Errorf("alice right after bob = %v, want a key cooldown", err)
+	}
+
+	advance(5 * time.Second)
+	if err := l.Allow("!ai", "bob", rules); scopeOf(t, err) != ScopeUser {
+		t.Errorf("bob after 5s = %v, want a user cooldown", err)
+	}
+	// A denied use takes nothing, so alice still gets the key
+	if err := l.Allow("!ai", "alice", rules); err != nil {
+		t.Errorf("alice after 5s: %s", err)
+	}
+
+	// Other keys have their own buckets
+	if err := l.Allow("!so", "bob", rules); err != nil {
+		t.Errorf("bob on another key: %s", err)
+	}
+
+	advance(25 * time.Second)
+	if err := l.Allow("!ai", "bob", rules); err != nil {
+		t.Errorf("bob after 30s: %s", err)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	l, advance := testLimiter()
+	l.SetGroup("panel", Limit{Every: 2 * time.Second, Burst: 2})
+	rules := Rules{Group: "panel"}
+
+	if err := l.Allow("!color", "bob", rules); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow("!bright", "alice", rules); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Allow("!speed", "carol", rules); scopeOf(t, err) != ScopeGroup {
+		t.Errorf("third panel command = %v, want a group cooldown", err)
+	}
+
+	advance(2 * time.Second)
+	if err := l.Allow("!speed", "carol", rules); err != nil {
+		t.Errorf("panel command after 2s: %s", err)
+	}
+
+	// Unknown groups have no limit
+	for i := 0; i < 5; i++ {
+		if err := l.Allow("!x", "bob", Rules{Group: "unknown"}); err != nil {
+			t.Fatalf("unknown group: %s", err)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l, advance := testLimiter()
+	l.SetLockout(3, time.Minute, 5*time.Minute)
+	rules := Rules{User: Limit{Every: time.Hour}}
+
+	if err := l.Allow("!lang", "bob", rules); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two strikes, then the old one leaves the window
+	l.Allow("!lang", "bob", rules)
+	advance(50 * time.Second)
+	l.Allow("!lang", "bob", rules)
+	advance(20 * time.Second)
+	if err := l.Allow("!lang", "bob", rules); scopeOf(t, err) != ScopeUser {
+		t.Fatalf("third strike out of the window = %v, want a user cooldown", err)
+	}
+
+	err := l.Allow("!lang", "bob", rules)
+	if scopeOf(t, err) != ScopeLockout || !err.(*Error).Started {
+		t.Fatalf("third strike in the window = %v, want the lockout start", err)
+	}
+
+	// Every key is locked, for everyone else they work
+	err = l.Allow("!other", "BOB", Rules{})
+	if scopeOf(t, err) != ScopeLockout || err.(*Error).Started {
+		t.Errorf("other key while locked = %v, want a lockout", err)
+	}
+	if e, ok := err.(*Error); ok && e.Remaining != 5*time.Minute {
+		t.Errorf("lockout remaining = %s, want 5m", e.Remaining)
+	}
+	if err := l.Allow("!other", "alice", Rules{}); err != nil {
+		t.Errorf("alice while bob is locked: %s", err)
+	}
+	// Uses without user skip the lockout
+	if err := l.Allow("!other", "", Rules{}); err != nil {
+		t.Errorf("use without user: %s", err)
+	}
+
+	advance(5*time.Minute - time.Second)
+	if err := l.Allow("!other", "bob", Rules{}); scopeOf(t, err) != ScopeLockout {
+		t.Errorf("other key before the lockout ends = %v, want a lockout", err)
+	}
+	advance(time.Second)
+	if err := l.Allow("!other", "bob", Rules{}); err != nil {
+		t.Errorf("other key after the lockout: %s", err)
+	}
+
+	// Reset clears a lockout early
+	for i := 0; i < 3; i++ {
+		l.Allow("!lang", "bob", rules)
+	}
+	if err := l.Allow("!other", "bob", Rules{}); scopeOf(t, err) != ScopeLockout {
+		t.Fatalf("not locked out again: %v", err)
+	}
+	l.Reset("Bob")
+	if err := l.Allow("!other", "bob", Rules{}); err != nil {
+		t.Errorf("after Reset: %s", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, advance := testLimiter()
+	rules := Rules{User: Limit{Every: time.Second}}
+
+	for i := 0; i < 300; i++ {
+		l.Allow("!x", fmt.Sprintf("user%d", i), rules)
+	}
+	advance(time.Minute)
+	for i := 0; i < 256; i++ {
+		l.Allow("!y", "", Rules{})
+	}
+
+	l.Lock()
+	n := len(l.buckets)
+	l.Unlock()
+	if n != 0 {
+		t.Errorf("%d buckets left after the sweep, want 0", n)
+	}
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	l, advance := testLimiter()
+	l.SetLockout(100, time.Minute, time.Minute)
+	rules := Rules{Key: Limit{Every: time.Second, Burst: 50}}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	allowed := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if l.Allow("!painel", fmt.Sprintf("user%d", i), rules) == nil {
+					lock.Lock()
+					allowed++
+					lock.Unlock()
+				}
+				if j%10 == 0 {
+					advance(time.Millisecond)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 50 of the burst, plus at most one for the 80ms the clock moved
+	if allowed < 50 || allowed > 51 {
+		t.Errorf("%d uses allowed, want 50 or 51", allowed)
+	}
+}
@@ -0,0 +1,227 @@
+package customcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quan-to/slog"
+	bolt "go.etcd.io/bbolt"
+)
+
+var log = slog.Scope("CustomCommands")
+
+// ErrNotFound is returned when the command or timer does not exist
+var ErrNotFound = errors.New("not found")
+
+var (
+	bucketCommands  = []byte("commands")
+	bucketTimers    = []byte("timers")
+	bucketLanguages = []byte("languages")
+)
+
+// Command is a text command added from chat
+type Command struct {
+	// Name is like "!discord"
+	Name string `json:"name"`
+	// Response is the text sent, with the ${...} variables. See Render
+	Response  string    `json:"response"`
+	Count     int       `json:"count"`
+	CreatedBy string    `json:"createdBy"`
+	UpdatedBy string    `json:"updatedBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Timer is a message posted every Interval while the chat is active
+type Timer struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// Interval is the time between two posts
+	Interval time.Duration `json:"interval"`
+	// MinMessages is how many chat messages are needed since the last post
+	MinMessages int       `json:"minMessages"`
+	CreatedBy   string    `json:"createdBy"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Store keeps the commands, the timers and the viewer languages in a bbolt database file
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database file
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketCommands, bucketTimers, bucketLanguages} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// NormalizeName lowercases a command name and adds the "!" if missing
+func NormalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name != "" && !strings.HasPrefix(name, "!") {
+		name = "!" + name
+	}
+	return name
+}
+
+// Commands returns the commands sorted by name
+func (s *Store) Commands() ([]Command, error) {
+	var list []Command
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketCommands).ForEach(func(k, v []byte) error {
+			var c Command
+			if err := json.Unmarshal(v, &c); err != nil {
+				log.Error("Invalid command %s: %s", k, err)
+				return nil
+			}
+			list = append(list, c)
+			return nil
+		})
+	})
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list, err
+}
+
+// GetCommand returns the command called name
+func (s *Store) GetCommand(name string) (Command, error) {
+	var c Command
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return get(tx.Bucket(bucketCommands), NormalizeName(name), &c)
+	})
+	return c, err
+}
+
+// SaveCommand adds or replaces a command
+func (s *Store) SaveCommand(c Command) error {
+	c.Name = NormalizeName(c.Name)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketCommands), c.Name, c)
+	})
+}
+
+// DeleteCommand removes the command called name
+func (s *Store) DeleteCommand(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return del(tx.Bucket(bucketCommands), NormalizeName(name))
+	})
+}
+
+// UseCommand increments the count of the command and returns it
+func (s *Store) UseCommand(name string) (Command, error) {
+	var c Command
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCommands)
+		if err := get(b, NormalizeName(name), &c); err != nil {
+			return err
+		}
+		c.Count++
+		return put(b, c.Name, c)
+	})
+	return c, err
+}
+
+// Timers returns the timers sorted by name
+func (s *Store) Timers() ([]Timer, error) {
+	var list []Timer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketTimers).ForEach(func(k, v []byte) error {
+			var t Timer
+			if err := json.Unmarshal(v, &t); err != nil {
+				log.Error("Invalid timer %s: %s", k, err)
+				return nil
+			}
+			list = append(list, t)
+			return nil
+		})
+	})
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list, err
+}
+
+// SaveTimer adds or replaces a timer
+func (s *Store) SaveTimer(t Timer) error {
+	t.Name = strings.ToLower(strings.TrimSpace(t.Name))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketTimers), t.Name, t)
+	})
+}
+
+// DeleteTimer removes the timer called name
+func (s *Store) DeleteTimer(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return del(tx.Bucket(bucketTimers), strings.ToLower(strings.TrimSpace(name)))
+	})
+}
+
+// UserLanguage returns the reply language picked by the user login, or "" if none
+func (s *Store) UserLanguage(user string) (string, error) {
+	var lang string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		lang = string(tx.Bucket(bucketLanguages).Get([]byte(strings.ToLower(user))))
+		return nil
+	})
+	return lang, err
+}
+
+// SetUserLanguage stores the reply language of the user login. An empty lang removes it
+func (s *Store) SetUserLanguage(user, lang string) error {
+	key := []byte(strings.ToLower(user))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLanguages)
+		if lang == "" {
+			return b.Delete(key)
+		}
+		return b.Put(key, []byte(lang))
+	})
+}
+
+func get(b *bolt.Bucket, key string, v interface{}) error {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+func put(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+func del(b *bolt.Bucket, key string) error {
+	if b.Get([]byte(key)) == nil {
+		return ErrNotFound
+	}
+	return b.Delete([]byte(key))
+}
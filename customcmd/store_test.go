@@ -0,0 +1,76 @@
+package customcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "customcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUserLanguage(t *testing.T) {
+	s, done := openTestStore(t)
+	defer done()
+
+	if lang, err := s.UserLanguage("bob"); err != nil || lang != "" {
+		t.Fatalf("UserLanguage(bob) = %q, %v, want none", lang, err)
+	}
+
+	if err := s.SetUserLanguage("Bob", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if lang, _ := s.UserLanguage("BOB"); lang != "en" {
+		t.Errorf("UserLanguage(BOB) = %q, want en", lang)
+	}
+
+	if err := s.SetUserLanguage("bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	if lang, _ := s.UserLanguage("bob"); lang != "" {
+		t.Errorf("UserLanguage(bob) = %q after removing it", lang)
+	}
+	if err := s.SetUserLanguage("alice", ""); err != nil {
+		t.Errorf("removing a missing language: %s", err)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	s, done := openTestStore(t)
+	defer done()
+
+	if err := s.SaveCommand(Command{Name: "Discord", Response: "discord.gg/x"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.UseCommand("!DISCORD")
+	if err != nil || c.Count != 1 {
+		t.Fatalf("UseCommand = %+v, %v, want count 1", c, err)
+	}
+	if c, _ := s.UseCommand("!discord"); c.Count != 2 {
+		t.Errorf("count = %d after two uses, want 2", c.Count)
+	}
+
+	if err := s.DeleteCommand("discord"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetCommand("!discord"); err != ErrNotFound {
+		t.Errorf("GetCommand after delete = %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteCommand("!discord"); err != ErrNotFound {
+		t.Errorf("second DeleteCommand = %v, want ErrNotFound", err)
+	}
+}
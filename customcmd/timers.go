@@ -0,0 +1,108 @@
+package customcmd
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMinMessages is how many chat messages a timer needs between posts when not set
+const DefaultMinMessages = 5
+
+type timerState struct {
+	last time.Time
+	// baseline is the chat message count at the last post
+	baseline int
+}
+
+// Scheduler posts the stored timers, only when the chat was active since their last post
+type Scheduler struct {
+	sync.Mutex
+	store  *Store
+	post   func(text string)
+	vars   func() Vars
+	now    func() time.Time
+	states map[string]*timerState
+
+	// started is when the scheduler was created. messages counts the chat messages since then,
+	// and tickMessages is the count on the last Tick
+	started      time.Time
+	messages     int
+	tickMessages int
+}
+
+// NewScheduler returns a scheduler that sends the timers with post. vars returns the values of the variables
+func NewScheduler(store *Store, post func(text string), vars func() Vars) *Scheduler {
+	return &Scheduler{
+		store:   store,
+		post:    post,
+		vars:    vars,
+		now:     time.Now,
+		states:  map[string]*timerState{},
+		started: time.Now(),
+	}
+}
+
+// SetNow replaces the time source. The scheduler is considered started at its current time
+func (s *Scheduler) SetNow(now func() time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.now = now
+	s.started = now()
+}
+
+// Activity counts a chat message
+func (s *Scheduler) Activity() {
+	s.Lock()
+	defer s.Unlock()
+	s.messages++
+}
+
+// Tick posts the timers that are due. A timer is due Interval after its last post, or after it
+// was saved, if the chat had MinMessages messages since then. The messages of a new timer are
+// counted since the previous Tick, so the ones sent before it was first seen are not lost
+func (s *Scheduler) Tick() {
+	timers, err := s.store.Timers()
+	if err != nil {
+		log.Error("Cannot read the timers: %s", err)
+		return
+	}
+
+	s.Lock()
+	now := s.now()
+	seen := map[string]bool{}
+	var due []Timer
+	for _, t := range timers {
+		seen[t.Name] = true
+		st, ok := s.states[t.Name]
+		if !ok {
+			st = &timerState{last: s.started, baseline: s.tickMessages}
+			if t.UpdatedAt.After(st.last) {
+				st.last = t.UpdatedAt
+			}
+			s.states[t.Name] = st
+		}
+
+		min := t.MinMessages
+		if min <= 0 {
+			min = DefaultMinMessages
+		}
+		if now.Sub(st.last) >= t.Interval && s.messages-st.baseline >= min {
+			st.last = now
+			st.baseline = s.messages
+			due = append(due, t)
+		}
+	}
+	for name := range s.states {
+		if !seen[name] {
+			delete(s.states, name)
+		}
+	}
+	s.tickMessages = s.messages
+	s.Unlock()
+
+	for _, t := range due {
+		log.Debug("Posting timer %s", t.Name)
+		v := s.vars()
+		s.post(Render(t.Message, v))
+	}
+}
@@ -0,0 +1,65 @@
+package customcmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	store, done := openTestStore(t)
+	defer done()
+
+	start := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	now := start
+
+	var posted []string
+	s := NewScheduler(store, func(text string) {
+		posted = append(posted, text)
+	}, func() Vars {
+		return Vars{}
+	})
+	s.SetNow(func() time.Time { return now })
+
+	tick := func(at time.Duration, messages int, want ...string) {
+		t.Helper()
+		for i := 0; i < messages; i++ {
+			s.Activity()
+		}
+		now = start.Add(at)
+		posted = nil
+		s.Tick()
+		if !reflect.DeepEqual(posted, want) {
+			t.Errorf("at %s posted %q, want %q", at, posted, want)
+		}
+	}
+
+	if err := store.SaveTimer(Timer{Name: "water", Message: "Drink water", Interval: 10 * time.Minute, MinMessages: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The messages sent before the first Tick count
+	tick(5*time.Minute, 4)
+	tick(10*time.Minute, 0, "Drink water")
+	// Interval reached, not enough messages
+	tick(20*time.Minute, 2)
+	tick(21*time.Minute, 1, "Drink water")
+	// Enough messages, interval not reached
+	tick(25*time.Minute, 5)
+	tick(31*time.Minute, 0, "Drink water")
+
+	// A timer added later waits its interval from when it was saved
+	if err := store.SaveTimer(Timer{Name: "discord", Message: "Join the discord", Interval: 5 * time.Minute, UpdatedAt: start.Add(32 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	tick(33*time.Minute, 5)
+	tick(37*time.Minute, 0, "Join the discord")
+
+	if err := store.DeleteTimer("water"); err != nil {
+		t.Fatal(err)
+	}
+	tick(45*time.Minute, 5, "Join the discord")
+	if _, ok := s.states["water"]; ok {
+		t.Errorf("state of the deleted timer was kept")
+	}
+}
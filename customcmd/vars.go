@@ -0,0 +1,87 @@
+package customcmd
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vars are the values of the variables of a response:
+// ${user}, ${count}, ${uptime}, ${game} and ${random MIN MAX}
+type Vars struct {
+	User  string
+	Count int
+	// Uptime is the time since the stream started, or zero if offline
+	Uptime time.Duration
+	Game   string
+	// Rand returns a number in [0, n). Defaults to math/rand
+	Rand func(n int) int
+}
+
+var varRegex = regexp.MustCompile(`\$\{\s*([a-zA-Z]+)((?:\s+-?\d+)*)\s*\}`)
+
+// Render replaces the variables of text. Unknown or invalid variables are kept as they are
+func Render(text string, v Vars) string {
+	return varRegex.ReplaceAllStringFunc(text, func(m string) string {
+		parts := varRegex.FindStringSubmatch(m)
+		args := strings.Fields(parts[2])
+
+		switch strings.ToLower(parts[1]) {
+		case "user":
+			return v.User
+		case "count":
+			return strconv.Itoa(v.Count)
+		case "uptime":
+			if v.Uptime <= 0 {
+				return "offline"
+			}
+			return FormatUptime(v.Uptime)
+		case "game":
+			return v.Game
+		case "random":
+			if r, ok := random(args, v.Rand); ok {
+				return strconv.Itoa(r)
+			}
+		}
+
+		return m
+	})
+}
+
+// maxRandomRange is the most numbers a ${random MIN MAX} can pick from
+const maxRandomRange = 1000000000
+
+// random returns a number between the two args, inclusive. MAX must not be lower than MIN
+func random(args []string, rnd func(n int) int) (int, bool) {
+	if len(args) != 2 {
+		return 0, false
+	}
+	min, err1 := strconv.Atoi(args[0])
+	max, err2 := strconv.Atoi(args[1])
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	// span wraps to a negative number when the range does not fit an int
+	span := max - min + 1
+	if max < min || span <= 0 || span > maxRandomRange {
+		return 0, false
+	}
+	if rnd == nil {
+		rnd = rand.Intn
+	}
+	return min + rnd(span), true
+}
+
+// FormatUptime formats a duration like "2h05m"
+func FormatUptime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d / time.Hour)
+	m := int((d % time.Hour) / time.Minute)
+	if h == 0 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", h, m)
+}
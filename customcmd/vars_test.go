@@ -0,0 +1,51 @@
+package customcmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	// lowest always picks the lowest number, so the result is MIN
+	lowest := func(n int) int { return 0 }
+	highest := func(n int) int { return n - 1 }
+
+	tests := []struct {
+		text string
+		vars Vars
+		want string
+	}{
+		{"hi ${user}", Vars{User: "bob"}, "hi bob"},
+		{"used ${count} times", Vars{Count: 3}, "used 3 times"},
+		{"up for ${uptime}", Vars{Uptime: 2*time.Hour + 5*time.Minute}, "up for 2h05m"},
+		{"up for ${uptime}", Vars{}, "up for offline"},
+		{"playing ${game}", Vars{Game: "Factorio"}, "playing Factorio"},
+		{"${ USER }", Vars{User: "bob"}, "bob"},
+		{"${random 1 6}", Vars{Rand: lowest}, "1"},
+		{"${random 1 6}", Vars{Rand: highest}, "6"},
+		{"${random -5 -1}", Vars{Rand: highest}, "-1"},
+		{"${random 3 3}", Vars{Rand: highest}, "3"},
+		{"${random 1 1000000000}", Vars{Rand: highest}, "1000000000"},
+		{"${random 6 1}", Vars{Rand: lowest}, "${random 6 1}"},
+		{"${random 0 1000000000}", Vars{Rand: lowest}, "${random 0 1000000000}"},
+		{"${random -9223372036854775808 9223372036854775807}", Vars{Rand: lowest}, "${random -9223372036854775808 9223372036854775807}"},
+		{"${random 1}", Vars{Rand: lowest}, "${random 1}"},
+		{"${random 1 99999999999999999999}", Vars{Rand: lowest}, "${random 1 99999999999999999999}"},
+		{"${unknown}", Vars{}, "${unknown}"},
+	}
+
+	for _, tt := range tests {
+		if got := Render(tt.text, tt.vars); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRenderRandomDefault(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		got := Render("${random 1 3}", Vars{})
+		if got != "1" && got != "2" && got != "3" {
+			t.Fatalf("Render(${random 1 3}) = %q, want 1, 2 or 3", got)
+		}
+	}
+}
@@ -19,6 +19,7 @@ require (
 	github.com/pkg/browser v0.0.0-20180916011732-0a3d74bf9ce4
 	github.com/quan-to/slog v0.1.1
 	github.com/stretchr/testify v1.7.1 // indirect
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/image v0.0.0-20190227222117-0694c2d4d067
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	gopkg.in/irc.v3 v3.1.0
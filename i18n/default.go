@@ -0,0 +1,38 @@
+package i18n
+
+import "sync"
+
+var (
+	defaultLock    sync.RWMutex
+	defaultCatalog = Builtin()
+)
+
+// Builtin returns a catalog with the messages shipped with the bot
+func Builtin() *Catalog {
+	c := NewCatalog()
+	for lang, data := range builtinLocales {
+		if err := c.Parse(lang, data); err != nil {
+			panic("i18n: built-in " + lang + " catalog: " + err.Error())
+		}
+	}
+	return c
+}
+
+// Default returns the catalog used by T
+func Default() *Catalog {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultCatalog
+}
+
+// SetDefault replaces the catalog used by T
+func SetDefault(c *Catalog) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultCatalog = c
+}
+
+// T returns the message of key in lang from the default catalog. See Catalog.T
+func T(lang, key string, args Args) string {
+	return Default().T(lang, key, args)
+}
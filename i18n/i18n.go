@@ -0,0 +1,284 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/quan-to/slog"
+)
+
+var log = slog.Scope("I18n")
+
+// Fallback is the language used for the keys missing in the others
+const Fallback = "en"
+
+// Args are the values of the named {placeholders} of a message
+type Args map[string]interface{}
+
+// Message is a text, or the plural forms of a text picked by the number in the Arg argument
+type Message struct {
+	Text string
+	// Arg is the argument that picks the plural form. Defaults to "count"
+	Arg string
+	// Forms are the texts by plural category, like "one" and "other"
+	Forms map[string]string
+}
+
+// IsPlural tells if the message has plural forms
+func (m Message) IsPlural() bool {
+	return len(m.Forms) > 0
+}
+
+// texts returns the text or every plural form
+func (m Message) texts() []string {
+	if !m.IsPlural() {
+		return []string{m.Text}
+	}
+	var list []string
+	for _, t := range m.Forms {
+		list = append(list, t)
+	}
+	return list
+}
+
+// Catalog has the messages of each language by key
+type Catalog struct {
+	locales map[string]map[string]Message
+}
+
+// NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{locales: map[string]map[string]Message{}}
+}
+
+// Parse adds the messages of lang from a TOML catalog, replacing the ones with the same key.
+// A key is a string, or a table of plural forms picked by the arg argument:
+//
+//	follow = "Thanks {user} for the follow!"
+//	sub = { arg = "months", one = "Thanks {user} for {months} month!", other = "Thanks {user} for {months} months!" }
+func (c *Catalog) Parse(lang, data string) error {
+	var raw map[string]interface{}
+	if _, err := toml.Decode(data, &raw); err != nil {
+		return err
+	}
+
+	lang = normalizeLang(lang)
+	msgs, ok := c.locales[lang]
+	if !ok {
+		msgs = map[string]Message{}
+		c.locales[lang] = msgs
+	}
+
+	for key, v := range raw {
+		switch v := v.(type) {
+		case string:
+			msgs[key] = Message{Text: v}
+		case map[string]interface{}:
+			m := Message{Arg: "count", Forms: map[string]string{}}
+			for form, text := range v {
+				s, ok := text.(string)
+				if !ok {
+					return fmt.Errorf("%s.%s should be a string", key, form)
+				}
+				if form == "arg" {
+					m.Arg = s
+					continue
+				}
+				m.Forms[form] = s
+			}
+			msgs[key] = m
+		default:
+			return fmt.Errorf("%s should be a string or a table of plural forms", key)
+		}
+	}
+
+	return nil
+}
+
+// LoadDir parses every LANG.toml file of dir, like pt.toml or en.toml
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.toml"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		lang := strings.TrimSuffix(filepath.Base(f), ".toml")
+		if err := c.Parse(lang, string(data)); err != nil {
+			return fmt.Errorf("%s: %s", f, err)
+		}
+		log.Debug("Loaded %s", f)
+	}
+
+	return nil
+}
+
+// Languages returns the languages of the catalog, sorted
+func (c *Catalog) Languages() []string {
+	var list []string
+	for lang := range c.locales {
+		list = append(list, lang)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Has tells if the catalog has messages in lang
+func (c *Catalog) Has(lang string) bool {
+	_, ok := c.locales[normalizeLang(lang)]
+	return ok
+}
+
+// Get returns the message of key in lang, or in its base language, or in the Fallback language
+func (c *Catalog) Get(lang, key string) (Message, bool) {
+	lang = normalizeLang(lang)
+	for _, l := range []string{lang, baseLang(lang), Fallback} {
+		if m, ok := c.locales[l][key]; ok {
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+// T returns the message of key in lang with the placeholders replaced by args. Placeholders without
+// a value are kept, so they can be replaced later. A missing key returns the key itself
+func (c *Catalog) T(lang, key string, args Args) string {
+	m, ok := c.Get(lang, key)
+	if !ok {
+		log.Warn("Missing message %q", key)
+		return key
+	}
+
+	text := m.Text
+	if m.IsPlural() {
+		rule := pluralRuleFor(lang)
+		text, ok = m.Forms[rule.Select(number(args[m.Arg]))]
+		if !ok {
+			text = m.Forms["other"]
+		}
+	}
+
+	return render(text, args)
+}
+
+var placeholderRegex = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+func render(text string, args Args) string {
+	return placeholderRegex.ReplaceAllStringFunc(text, func(p string) string {
+		if v, ok := args[p[1:len(p)-1]]; ok {
+			return fmt.Sprint(v)
+		}
+		return p
+	})
+}
+
+// placeholders returns the names of the placeholders of text
+func placeholders(text string) []string {
+	var names []string
+	for _, m := range placeholderRegex.FindAllStringSubmatch(text, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// number returns the count of a plural message, from an int or a numeric string
+func number(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
+
+// normalizeLang lowercases lang and uses "-" as separator, like "pt-br"
+func normalizeLang(lang string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(lang)), "_", "-", -1)
+}
+
+// baseLang returns the language without the region, like "pt" for "pt-br"
+func baseLang(lang string) string {
+	if i := strings.Index(lang, "-"); i > 0 {
+		return lang[:i]
+	}
+	return lang
+}
+
+// Validate checks that every language has every key of the Fallback language, the plural forms
+// its plural rule needs, and only the placeholders the Fallback message has
+func (c *Catalog) Validate() []error {
+	var errs []error
+
+	ref, ok := c.locales[Fallback]
+	if !ok {
+		return []error{fmt.Errorf("no %s messages", Fallback)}
+	}
+
+	for _, lang := range c.Languages() {
+		msgs := c.locales[lang]
+		rule := pluralRuleFor(lang)
+
+		for _, key := range sortedKeys(ref) {
+			m, ok := msgs[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: missing %q", lang, key))
+				continue
+			}
+
+			if m.IsPlural() {
+				for _, form := range rule.Forms {
+					if _, ok := m.Forms[form]; !ok {
+						errs = append(errs, fmt.Errorf("%s: %q has no %q form", lang, key, form))
+					}
+				}
+			}
+
+			known := map[string]bool{}
+			for _, t := range ref[key].texts() {
+				for _, p := range placeholders(t) {
+					known[p] = true
+				}
+			}
+			for _, t := range m.texts() {
+				for _, p := range placeholders(t) {
+					if !known[p] {
+						errs = append(errs, fmt.Errorf("%s: %q has the unknown placeholder {%s}", lang, key, p))
+					}
+				}
+			}
+		}
+
+		for _, key := range sortedKeys(msgs) {
+			if _, ok := ref[key]; !ok {
+				errs = append(errs, fmt.Errorf("%s: %q is not a %s key", lang, key, Fallback))
+			}
+		}
+	}
+
+	return errs
+}
+
+func sortedKeys(msgs map[string]Message) []string {
+	keys := make([]string, 0, len(msgs))
+	for k := range msgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,188 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinValidate(t *testing.T) {
+	c := Builtin()
+	for _, err := range c.Validate() {
+		t.Errorf("built-in catalog: %s", err)
+	}
+
+	for lang := range builtinLocales {
+		if !c.Has(lang) {
+			t.Errorf("built-in catalog has no %s messages", lang)
+		}
+	}
+}
+
+func TestPluralSelect(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"en", 0, "other"},
+		{"en", 1, "one"},
+		{"en", 2, "other"},
+		{"en", 21, "other"},
+		{"pt", 0, "one"},
+		{"pt", 1, "one"},
+		{"pt", 2, "other"},
+		{"pt", 100, "other"},
+		{"pt-BR", 0, "one"},
+		{"pt_br", 2, "other"},
+		{"es", 0, "other"},
+		{"es", 1, "one"},
+		{"es", 5, "other"},
+		// Languages without a rule use the English one
+		{"de", 0, "other"},
+		{"de", 1, "one"},
+	}
+
+	for _, tt := range tests {
+		if got := pluralRuleFor(tt.lang).Select(tt.n); got != tt.want {
+			t.Errorf("%s: Select(%d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBuiltinPlurals(t *testing.T) {
+	c := Builtin()
+
+	tests := []struct {
+		lang string
+		key  string
+		args Args
+		want string
+	}{
+		{"en", "bits_thanks", Args{"user": "bob", "bits": 1}, "Thanks bob for 1 bit!!"},
+		{"en", "bits_thanks", Args{"user": "bob", "bits": 100}, "Thanks bob for 100 bits!!"},
+		{"en", "sub_thanks", Args{"user": "bob", "months": 0}, "Thanks @bob for 0 months subscription!!"},
+		{"en", "raid_thanks", Args{"user": "bob", "viewers": "1"}, "Thanks @bob for the raid with 1 viewer!!"},
+		{"pt", "sub_thanks", Args{"user": "bob", "months": 0}, "Obrigado @bob pelo sub de 0 mês!!"},
+		{"pt", "sub_thanks", Args{"user": "bob", "months": 1}, "Obrigado @bob pelo sub de 1 mês!!"},
+		{"pt", "sub_thanks", Args{"user": "bob", "months": 12}, "Obrigado @bob pelo sub de 12 meses!!"},
+		{"pt-BR", "bits_thanks", Args{"user": "bob", "bits": 50}, "Obrigado bob por 50 bits!!"},
+		{"pt", "timer_set", Args{"user": "bob", "timer": "water", "minutes": 1}, "@bob, timer water a cada 1 minuto!"},
+		// Missing plural args count as 0
+		{"en", "alert_sub", Args{"user": "bob"}, "bob THANKS SUB {months} MONTHS!"},
+	}
+
+	for _, tt := range tests {
+		if got := c.T(tt.lang, tt.key, tt.args); got != tt.want {
+			t.Errorf("%s %s %v = %q, want %q", tt.lang, tt.key, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	c := NewCatalog()
+	mustParse(t, c, "en", `
+hello = "Hello {user}"
+bye = "Bye {user}"
+items = { one = "{count} item", other = "{count} items" }
+`)
+	mustParse(t, c, "pt", `
+hello = "Olá {user}"
+items = { one = "{count} item", other = "{count} itens" }
+`)
+	mustParse(t, c, "pt-br", `hello = "Oi {user}"`)
+	mustParse(t, c, "es", `items = { one = "{count} cosa", other = "{count} cosas" }`)
+
+	tests := []struct {
+		lang string
+		key  string
+		args Args
+		want string
+	}{
+		{"en", "hello", Args{"user": "bob"}, "Hello bob"},
+		{"pt", "hello", Args{"user": "bob"}, "Olá bob"},
+		{"pt-BR", "hello", Args{"user": "bob"}, "Oi bob"},
+		// pt-br falls back to pt, then to en
+		{"pt-br", "items", Args{"count": 0}, "0 item"},
+		{"pt-br", "items", Args{"count": 3}, "3 itens"},
+		{"pt-br", "bye", Args{"user": "bob"}, "Bye bob"},
+		{"es", "items", Args{"count": 0}, "0 cosas"},
+		{"es", "hello", Args{"user": "bob"}, "Hello bob"},
+		// Unknown languages use en, placeholders without a value are kept
+		{"de", "items", Args{"count": 1}, "1 item"},
+		{"de", "hello", nil, "Hello {user}"},
+		{"en", "missing", nil, "missing"},
+	}
+
+	for _, tt := range tests {
+		if got := c.T(tt.lang, tt.key, tt.args); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	c := NewCatalog()
+	mustParse(t, c, "en", `
+hello = "Hello {user}"
+items = { one = "{count} item", other = "{count} items" }
+`)
+	mustParse(t, c, "pt", `
+hello = "Olá {name}"
+items = { other = "{count} itens" }
+extra = "extra"
+`)
+	mustParse(t, c, "es", `hello = "Hola {user}"`)
+
+	// pt: unknown placeholder, missing plural form and extra key. es: missing key
+	if errs := c.Validate(); len(errs) != 4 {
+		t.Errorf("Validate() = %v, want 4 errors", errs)
+	}
+
+	if errs := NewCatalog().Validate(); len(errs) != 1 {
+		t.Errorf("Validate() of an empty catalog = %v, want 1 error", errs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, data := range []string{
+		`hello = 1`,
+		`items = { one = 1, other = "{count} items" }`,
+		`hello = "unterminated`,
+	} {
+		if err := NewCatalog().Parse("en", data); err == nil {
+			t.Errorf("Parse(%q) did not fail", data)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "es.toml"), []byte(`follow_thanks = "¡Gracias {user} por el follow!"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Builtin()
+	if err := c.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir: %s", err)
+	}
+	if got := c.T("es", "follow_thanks", Args{"user": "bob"}); got != "¡Gracias bob por el follow!" {
+		t.Errorf("es follow_thanks = %q", got)
+	}
+	if got := c.T("es", "bits_thanks", Args{"user": "bob", "bits": 2}); got != "Thanks bob for 2 bits!!" {
+		t.Errorf("es bits_thanks = %q, want the en fallback", got)
+	}
+}
+
+func mustParse(t *testing.T, c *Catalog, lang, data string) {
+	t.Helper()
+	if err := c.Parse(lang, data); err != nil {
+		t.Fatalf("Parse(%s): %s", lang, err)
+	}
+}
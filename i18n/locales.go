@@ -0,0 +1,229 @@
+package i18n
+
+// builtinLocales are the built-in catalogs by language. The panel texts have no accents, the panel font lacks them
+var builtinLocales = map[string]string{
+	"en": localeEN,
+	"pt": localePT,
+}
+
+const localeEN = `
+# Events
+follow_thanks = "Thanks {user} for the follow!"
+bits_thanks = { arg = "bits", one = "Thanks {user} for {bits} bit!!", other = "Thanks {user} for {bits} bits!!" }
+sub_thanks = { arg = "months", one = "Thanks @{user} for {months} month subscription!!", other = "Thanks @{user} for {months} months subscription!!" }
+raid_thanks = { arg = "viewers", one = "Thanks @{user} for the raid with {viewers} viewer!!", other = "Thanks @{user} for the raid with {viewers} viewers!!" }
+action_failed = "Sorry @{user}, {action}: {error}"
+stream_online = "/me LIVE ON!! {title}"
+stream_offline = "/me F"
+stream_goodbye = "/me GOODBYE WORLD"
+panel_online = "/me LED panel is back online!"
+panel_offline = "/me LED panel went offline :("
+panel_set = "Panel set to: {text}"
+new_clip = "New clip: {url}"
+javascripto = "/me EU AVO MATA O JAVASCRIPTOOOO!!!!!"
+ai_error = "Sorry, my brain had an error. Try again :("
+
+# Commands
+command_list = "Hi {prefix} @{user}! Want to do some HUEHUE? - Use !huenglish COMMAND for help of a command. Commands: {commands}"
+command_unknown = "{prefix} @{user}, sorry, but I don't know the command \"{command}\" :("
+command_denied = "Sorry {prefix} {user}, you cannot use {command}."
+command_restricted = "Sorry {prefix} {user}, {command} is for {rule} only."
+command_usage = "{prefix} @{user}, {error}. Usage: {usage}"
+command_cooldown = "Easy {prefix} @{user}, {command} can be used again in {remaining}."
+command_lockout = "Easy {prefix} @{user}, too many commands! Try again in {remaining}."
+source = "Hello {prefix} @{user}! My source code is in Github! https://github.com/racerxdl/twitchled - And the led panel as well: https://github.com/racerxdl/wimatrix"
+ai_reset = "Got it, AI history cleared!"
+stream_title_set = "Got it, the bot stream title is now {title}!"
+stream_title_current = "Got it, the bot stream title is {title}!"
+command_help = "{prefix} @{user}, {help}"
+here = "I'm here!!"
+clips_list = "Here are the clips"
+fake_sub = "OK my king. A new fake subscription is coming"
+fake_bits = "OK my king. A new fake bits"
+fake_follow = "OK my king. A new fake follow"
+mode_invalid = "Invalid mode \"{mode}\"."
+mode_list = "Valid modes are: {modes}"
+mode_set = "Mode set to {mode}: {name}"
+
+# Permission levels, as in "{command} is for {rule} only"
+level_everyone = "everyone"
+level_follower = "followers"
+level_subscriber = "subscribers"
+level_vip = "VIPs"
+level_moderator = "moderators"
+level_broadcaster = "the streamer"
+rule_tier = "{rule} tier {tier}"
+rule_months = { arg = "months", one = "{rule} with {months}+ month", other = "{rule} with {months}+ months" }
+
+# Command help. {panels} is replaced by the panel targets
+help_huebot = "I'm @RacerXDL bot!"
+help_hue = "what do you want to know? Use !hue COMMAND"
+help_huenglish = "shows the help of a command in English. Use !huenglish COMMAND"
+help_color = "the command changes the text color! You can give the name of the color or hex value. For example !color red or !color #FF0000. Add @panel at the end to pick a panel: {panels}"
+help_bgcolor = "the command changes the background color! You can give the name of the color or hex value. For example !bgcolor red or !bgcolor #FF0000. Add @panel at the end to pick a panel: {panels}"
+help_bright = "the command changes text brightness! The minimum value is 0 and maximum is 1. You can use !bright 1 or !bright 1 @panel. Panels: {panels}"
+help_bgbright = "the command changes background brightness! The minimum value is 0 and maximum is 1. You can use !bgbright 1 or !bgbright 1 @panel. Panels: {panels}"
+help_source = "the command source shows mine and led panel source code!"
+help_painel = "if you're a subscriber, sends a text message to the led panel! For example !painel HUEBOT is so cool"
+help_speed = "changes the scrolling speed of the text in the panel! For example !speed 60"
+help_lang = "picks the language of my replies to you, like !lang en. !lang default goes back to the channel languages"
+help_action = "the command {command} runs the {action} action!"
+help_custom = "{command} is a channel command!"
+help_addcmd = "adds a text command. Variables: ${user} ${count} ${uptime} ${game} ${random 1 100}"
+help_editcmd = "changes the response of a text command"
+help_delcmd = "deletes a text command"
+help_addtimer = "posts a message every N minutes, if the chat is active"
+help_deltimer = "deletes a timed message"
+help_timers = "lists the timed messages"
+
+# Panel moderation
+panel_rejected = "Sorry @{user}, your message cannot go to the panel: {error}"
+panel_waiting = "@{user}, your message goes to the panel once a moderator approves it."
+panel_not_approved = "@{user}, your message was not approved."
+pending_empty = "Nothing waiting for approval."
+pending_item = "{id}: {source} from {user}: {text}"
+pending_not_found = "{id}: no pending text with this id"
+
+# Custom commands and timers
+custom_invalid_name = "@{user}, invalid name \"{command}\""
+custom_exists = "@{user}, {command} already exists. Use !editcmd to change it"
+custom_builtin = "@{user}, {command} is a bot command"
+custom_not_found = "@{user}, there is no command {command}"
+custom_added = "@{user}, {command} added!"
+custom_changed = "@{user}, {command} changed!"
+custom_deleted = "@{user}, {command} deleted!"
+timer_min_interval = "@{user}, the interval is at least 1 minute"
+timer_set = { arg = "minutes", one = "@{user}, timer {timer} set every {minutes} minute!", other = "@{user}, timer {timer} set every {minutes} minutes!" }
+timer_not_found = "@{user}, there is no timer {timer}"
+timer_deleted = "@{user}, timer {timer} deleted!"
+timers_empty = "No timers."
+timers_list = "Timers: {timers}"
+
+# Reply language
+lang_set = "@{user}, I will reply to you in English!"
+lang_default = "@{user}, I will reply to you in the channel languages."
+lang_unknown = "@{user}, I don't speak \"{lang}\". Languages: {languages}"
+lang_usage = "@{user}, use !lang LANGUAGE. Languages: {languages}"
+
+# Panel alerts
+alert_sub = { arg = "months", one = "{user} THANKS SUB {months} MONTH!", other = "{user} THANKS SUB {months} MONTHS!" }
+alert_bits = { arg = "bits", one = "{user} THANKS {bits} BIT!! {message}", other = "{user} THANKS {bits} BITS!! {message}" }
+alert_follow = "{user} THANKS FOLLOW!"
+alert_raid = "{user} RAID WITH {viewers}!"
+alert_reward = "{user} REDEEMED {reward}!"
+followers_and = "{names} AND {last}"
+followers_more = "{names} AND {more} MORE"
+playlist_follower = "LAST FOLLOW: {user}"
+playlist_subgoal = "SUB GOAL: {subs}/{goal}"
+`
+
+const localePT = `
+# Eventos
+follow_thanks = "Obrigado {user} pelo follow!"
+bits_thanks = { arg = "bits", one = "Obrigado {user} por {bits} bit!!", other = "Obrigado {user} por {bits} bits!!" }
+sub_thanks = { arg = "months", one = "Obrigado @{user} pelo sub de {months} mês!!", other = "Obrigado @{user} pelo sub de {months} meses!!" }
+raid_thanks = { arg = "viewers", one = "Obrigado @{user} pela raid com {viewers} viewer!!", other = "Obrigado @{user} pela raid com {viewers} viewers!!" }
+action_failed = "Desculpe @{user}, {action}: {error}"
+stream_online = "/me LIVE ON!! {title}"
+stream_offline = "/me F"
+stream_goodbye = "/me GOODBYE WORLD"
+panel_online = "/me O painel de LED voltou!"
+panel_offline = "/me O painel de LED caiu :("
+panel_set = "Painel alterado para: {text}"
+new_clip = "Clip novo: {url}"
+javascripto = "/me EU AVO MATA O JAVASCRIPTOOOO!!!!!"
+ai_error = "Desculpe, houve um erro no meu cérebro. Tente novamente :("
+
+# Comandos
+command_list = "Olá {prefix} @{user}! Quer fazer uns HUEHUE? - Use !hue COMANDO para ajuda de um comando. Comandos: {commands}"
+command_unknown = "{prefix} @{user}, desculpa, mas eu não conheço o comando \"{command}\" :("
+command_denied = "Desculpe {prefix} {user}, você não pode usar {command}."
+command_restricted = "Desculpe {prefix} {user}, {command} é apenas para {rule}."
+command_usage = "{prefix} @{user}, {error}. Uso: {usage}"
+command_cooldown = "Calma {prefix} @{user}, {command} pode ser usado de novo em {remaining}."
+command_lockout = "Calma {prefix} @{user}, muitos comandos! Tente de novo em {remaining}."
+source = "Olá {prefix} @{user}! Meu código fonte está no Github! https://github.com/racerxdl/twitchled - E o código fonte do painel de LED também: https://github.com/racerxdl/wimatrix"
+ai_reset = "Entendido, historico da IA apagado!"
+stream_title_set = "Entendido, titulo da stream alterado no bot para {title}!"
+stream_title_current = "Entendido, titulo da stream atual no bot é {title}!"
+command_help = "{prefix} @{user}, {help}"
+here = "Estou aqui!!"
+clips_list = "Aqui estão os clips"
+fake_sub = "OK meu rei. Vem aí um sub de mentira"
+fake_bits = "OK meu rei. Vem aí bits de mentira"
+fake_follow = "OK meu rei. Vem aí um follow de mentira"
+mode_invalid = "Modo inválido \"{mode}\"."
+mode_list = "Os modos válidos são: {modes}"
+mode_set = "Modo alterado para {mode}: {name}"
+
+# Níveis de permissão, como em "{command} é apenas para {rule}"
+level_everyone = "todos"
+level_follower = "seguidores"
+level_subscriber = "subs"
+level_vip = "VIPs"
+level_moderator = "moderadores"
+level_broadcaster = "o streamer"
+rule_tier = "{rule} tier {tier}"
+rule_months = { arg = "months", one = "{rule} com {months}+ mês", other = "{rule} com {months}+ meses" }
+
+# Ajuda dos comandos. {panels} é trocado pelos painéis
+help_huebot = "eu sou o bot do @RacerXDL!"
+help_hue = "o que deseja saber? Use !hue COMANDO"
+help_huenglish = "mostra a ajuda de um comando em inglês. Use !huenglish COMANDO"
+help_color = "o comando color troca a cor do texto! Você pode dar o nome da cor ou em hexa. Por exemplo !color red ou !color #FF0000. Use @painel no final para escolher o painel: {panels}"
+help_bgcolor = "o comando bgcolor troca a cor do fundo! Você pode dar o nome da cor ou em hexa. Por exemplo !bgcolor red ou !bgcolor #FF0000. Use @painel no final para escolher o painel: {panels}"
+help_bright = "o comando bright troca o brilho do texto! O valor mínimo é 0 e máximo é 1. Você pode usar !bright 1 ou !bright 1 @painel. Painéis: {panels}"
+help_bgbright = "o comando bgbright troca o brilho do fundo! O valor mínimo é 0 e máximo é 1. Você pode usar !bgbright 1 ou !bgbright 1 @painel. Painéis: {panels}"
+help_source = "o comando source mostra o meu código fonte e o do painel de led!"
+help_painel = "se você for subscriber, o comando painel envia uma mensagem no painel de led! Por exemplo: !painel HUEBOT é muito legal"
+help_speed = "o comando speed muda a velocidade da mensagem no painel! Por exemplo: !speed 60"
+help_lang = "escolhe o idioma das minhas respostas para você, como !lang pt. !lang default volta para os idiomas do canal"
+help_action = "o comando {command} executa a ação {action}!"
+help_custom = "{command} é um comando do canal!"
+help_addcmd = "adiciona um comando de texto. Variáveis: ${user} ${count} ${uptime} ${game} ${random 1 100}"
+help_editcmd = "troca a resposta de um comando de texto"
+help_delcmd = "apaga um comando de texto"
+help_addtimer = "envia uma mensagem a cada N minutos, se o chat estiver ativo"
+help_deltimer = "apaga uma mensagem temporizada"
+help_timers = "lista as mensagens temporizadas"
+
+# Moderação do painel
+panel_rejected = "Desculpe @{user}, sua mensagem não pode ir para o painel: {error}"
+panel_waiting = "@{user}, sua mensagem vai para o painel quando um moderador aprovar."
+panel_not_approved = "@{user}, sua mensagem não foi aprovada."
+pending_empty = "Nada esperando aprovação."
+pending_item = "{id}: {source} de {user}: {text}"
+pending_not_found = "{id}: nenhum texto pendente com esse id"
+
+# Comandos de texto e mensagens temporizadas
+custom_invalid_name = "@{user}, nome inválido \"{command}\""
+custom_exists = "@{user}, {command} já existe. Use !editcmd para mudar"
+custom_builtin = "@{user}, {command} é um comando do bot"
+custom_not_found = "@{user}, não existe o comando {command}"
+custom_added = "@{user}, {command} adicionado!"
+custom_changed = "@{user}, {command} alterado!"
+custom_deleted = "@{user}, {command} apagado!"
+timer_min_interval = "@{user}, o intervalo mínimo é 1 minuto"
+timer_set = { arg = "minutes", one = "@{user}, timer {timer} a cada {minutes} minuto!", other = "@{user}, timer {timer} a cada {minutes} minutos!" }
+timer_not_found = "@{user}, não existe o timer {timer}"
+timer_deleted = "@{user}, timer {timer} apagado!"
+timers_empty = "Nenhum timer."
+timers_list = "Timers: {timers}"
+
+# Idioma das respostas
+lang_set = "@{user}, vou te responder em português!"
+lang_default = "@{user}, vou te responder nos idiomas do canal."
+lang_unknown = "@{user}, eu não falo \"{lang}\". Idiomas: {languages}"
+lang_usage = "@{user}, use !lang IDIOMA. Idiomas: {languages}"
+
+# Alertas do painel
+alert_sub = { arg = "months", one = "{user} TKS SUB {months} MES!", other = "{user} TKS SUB {months} MESES!" }
+alert_bits = { arg = "bits", one = "{user} TKS {bits} BIT!! {message}", other = "{user} TKS {bits} BITS!! {message}" }
+alert_follow = "{user} TKS FOLLOW!"
+alert_raid = "{user} RAID COM {viewers}!"
+alert_reward = "{user} RESGATOU {reward}!"
+followers_and = "{names} E {last}"
+followers_more = "{names} E MAIS {more}"
+playlist_follower = "ULTIMO FOLLOW: {user}"
+playlist_subgoal = "META DE SUBS: {subs}/{goal}"
+`
@@ -0,0 +1,48 @@
+package i18n
+
+// PluralRule picks the plural category of a number, like "one" or "other"
+type PluralRule struct {
+	// Forms are the categories Select returns, every plural message needs them
+	Forms  []string
+	Select func(n int) string
+}
+
+var oneOther = []string{"one", "other"}
+
+var pluralRules = map[string]PluralRule{
+	"en": {Forms: oneOther, Select: func(n int) string {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}},
+	// Portuguese uses the singular for 0 and 1
+	"pt": {Forms: oneOther, Select: func(n int) string {
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	}},
+	"es": {Forms: oneOther, Select: func(n int) string {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}},
+}
+
+// SetPluralRule sets the plural rule of lang. Languages without a rule use the English one
+func SetPluralRule(lang string, rule PluralRule) {
+	pluralRules[normalizeLang(lang)] = rule
+}
+
+func pluralRuleFor(lang string) PluralRule {
+	lang = normalizeLang(lang)
+	if r, ok := pluralRules[lang]; ok {
+		return r
+	}
+	if r, ok := pluralRules[baseLang(lang)]; ok {
+		return r
+	}
+	return pluralRules["en"]
+}
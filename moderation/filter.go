@@ -0,0 +1,150 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/config"
+)
+
+var log = slog.Scope("Moderation")
+
+// defaultMaxLength is used when MaxLength is not configured
+const defaultMaxLength = 140
+
+// maxCombiningMarks is how many combining marks a character can have before the text is taken as zalgo
+const maxCombiningMarks = 2
+
+var urlPattern = regexp.MustCompile(`(?i)(\b[a-z][a-z0-9+.-]*://|\bwww\.|\b[a-z0-9-]+\.(com|net|org|io|tv|gg|br|ly|me|xyz|co|link|app|dev|ru|info)\b)`)
+
+// leetspeak maps the digits and symbols used to dodge the banned words
+var leetspeak = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+)
+
+// accents maps the latin accented letters to their base letter
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// Rejection is the reason a text was not accepted
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return r.Reason
+}
+
+// Filter checks the viewer text before it is sent to the panel
+type Filter struct {
+	bannedWords    []*regexp.Regexp
+	bannedPatterns []*regexp.Regexp
+	maxLength      int
+	allowUrls      bool
+}
+
+// MakeFilter builds the filter of cfg. Invalid patterns are skipped and returned as errors
+func MakeFilter(cfg config.ModerationConfig) (*Filter, []error) {
+	f := &Filter{
+		maxLength: cfg.MaxLength,
+		allowUrls: cfg.AllowUrls,
+	}
+	if f.maxLength <= 0 {
+		f.maxLength = defaultMaxLength
+	}
+
+	var errs []error
+
+	for _, w := range cfg.BannedWords {
+		w = normalize(strings.TrimSpace(w))
+		if w == "" {
+			continue
+		}
+		f.bannedWords = append(f.bannedWords, regexp.MustCompile(`(^|[^\pL\pN])`+regexp.QuoteMeta(w)+`($|[^\pL\pN])`))
+	}
+
+	for _, p := range cfg.BannedPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid banned pattern %q: %s", p, err))
+			continue
+		}
+		f.bannedPatterns = append(f.bannedPatterns, re)
+	}
+
+	return f, errs
+}
+
+// normalize lowercases text and undoes accents and leetspeak. Combining marks are dropped,
+// so accents typed as a letter followed by a mark are undone too
+func normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, text)
+	return leetspeak.Replace(accents.Replace(strings.ToLower(text)))
+}
+
+// Check returns a *Rejection if text should not be shown
+func (f *Filter) Check(text string) error {
+	if n := len([]rune(text)); n > f.maxLength {
+		return &Rejection{Reason: fmt.Sprintf("too long (%d of %d characters)", n, f.maxLength)}
+	}
+
+	if reason := unicodeAbuse(text); reason != "" {
+		return &Rejection{Reason: reason}
+	}
+
+	if !f.allowUrls && urlPattern.MatchString(text) {
+		return &Rejection{Reason: "links are not allowed"}
+	}
+
+	normalized := normalize(text)
+	for _, re := range f.bannedWords {
+		if re.MatchString(normalized) {
+			return &Rejection{Reason: "banned word"}
+		}
+	}
+
+	for _, re := range f.bannedPatterns {
+		if re.MatchString(text) {
+			log.Debug("Text %q matches %s", text, re)
+			return &Rejection{Reason: "banned pattern"}
+		}
+	}
+
+	return nil
+}
+
+// unicodeAbuse returns why text abuses unicode: stacked combining marks (zalgo),
+// invisible or direction control characters
+func unicodeAbuse(text string) string {
+	marks := 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r):
+			marks++
+			if marks > maxCombiningMarks {
+				return "zalgo text"
+			}
+			continue
+		case unicode.Is(unicode.Cf, r):
+			return "invisible characters"
+		case unicode.IsControl(r):
+			return "control characters"
+		}
+		marks = 0
+	}
+	return ""
+}
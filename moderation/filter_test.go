@@ -0,0 +1,118 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/racerxdl/twitchled/config"
+)
+
+func testFilter(t *testing.T, cfg config.ModerationConfig) *Filter {
+	t.Helper()
+	f, errs := MakeFilter(cfg)
+	for _, err := range errs {
+		t.Fatalf("MakeFilter: %s", err)
+	}
+	return f
+}
+
+func TestFilter(t *testing.T) {
+	f := testFilter(t, config.ModerationConfig{
+		BannedWords:    []string{"hack", "Otário", "spam bot"},
+		BannedPatterns: []string{`f+r+e+e+\s*v-?bucks`},
+	})
+
+	tests := []struct {
+		text string
+		// reason is the rejection reason, empty if the text passes
+		reason string
+	}{
+		// Clean text
+		{"Hello chat, great stream!", ""},
+		{"Olá, ótimo stream! Ação às 20h", ""},
+		{"I have 1 question for 5 minutes", ""},
+		{"hackathon tomorrow", ""},
+		{"e\u0301 accented with one mark", ""},
+		{strings.Repeat("a", 140), ""},
+
+		// Length, in characters and not bytes
+		{strings.Repeat("a", 141), "too long (141 of 140 characters)"},
+		{strings.Repeat("ã", 140), ""},
+
+		// Unicode abuse
+		{"za\u0337\u0322\u031blgo", "zalgo text"},
+		{"ha\u200bck", "invisible characters"},
+		{"\u202eevil", "invisible characters"},
+		{"bell\x07", "control characters"},
+		{"new\nline", "control characters"},
+
+		// Links
+		{"visit https://example.com", "links are not allowed"},
+		{"go to www.example.com", "links are not allowed"},
+		{"bit.ly/free", "links are not allowed"},
+		{"follow me at twitch.tv/someone", "links are not allowed"},
+
+		// Banned words, with case, leetspeak and accents
+		{"hack", "banned word"},
+		{"let's HACK the panel", "banned word"},
+		{"h4ck", "banned word"},
+		{"#hack!", "banned word"},
+		{"hãck", "banned word"},
+		{"ha\u0303ck", "banned word"},
+		{"you otario", "banned word"},
+		{"you 0T4R10", "banned word"},
+		{"a $p4m b0t here", "banned word"},
+		{"spam", ""},
+
+		// Patterns are matched on the original text, ignoring case
+		{"FREEE vbucks here", "banned pattern"},
+		{"free v-bucks", "banned pattern"},
+		{"free bucks", ""},
+	}
+
+	for _, tt := range tests {
+		err := f.Check(tt.text)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %s, want accepted", tt.text, err)
+			}
+			continue
+		}
+		r, ok := err.(*Rejection)
+		if !ok {
+			t.Errorf("Check(%q) = %v, want rejected as %q", tt.text, err, tt.reason)
+			continue
+		}
+		if r.Reason != tt.reason {
+			t.Errorf("Check(%q) rejected as %q, want %q", tt.text, r.Reason, tt.reason)
+		}
+	}
+}
+
+func TestFilterConfig(t *testing.T) {
+	f := testFilter(t, config.ModerationConfig{MaxLength: 10, AllowUrls: true})
+
+	if err := f.Check("www.a.com"); err != nil {
+		t.Errorf("link with AllowUrls: %s", err)
+	}
+	if err := f.Check("12345678901"); err == nil {
+		t.Errorf("11 characters with MaxLength 10 were accepted")
+	}
+
+	f, errs := MakeFilter(config.ModerationConfig{
+		BannedWords:    []string{"  ", "ok"},
+		BannedPatterns: []string{"(", "valid"},
+	})
+	if len(errs) != 1 {
+		t.Errorf("MakeFilter errors = %v, want 1 for the invalid pattern", errs)
+	}
+	if err := f.Check("valid"); err == nil {
+		t.Errorf("the valid pattern was skipped")
+	}
+	if err := f.Check("ok"); err == nil {
+		t.Errorf("the banned word was skipped")
+	}
+	if err := f.Check("fine"); err != nil {
+		t.Errorf("Check(fine) = %s", err)
+	}
+}
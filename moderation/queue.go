@@ -0,0 +1,169 @@
+package moderation
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultApprovalTimeout is used when ApprovalTimeout is not configured
+const defaultApprovalTimeout = time.Minute * 10
+
+// ErrNotPending is returned when approving or rejecting an unknown or already decided text
+var ErrNotPending = errors.New("no pending text with this id")
+
+// Pending is a text waiting for a moderator
+type Pending struct {
+	Id     int
+	User   string
+	Text   string
+	Source string
+	At     time.Time
+	// Publish sends the text to the panel once approved
+	Publish func()
+	// Cancel, if set, is called when the text is rejected or expires
+	Cancel func()
+}
+
+// Queue holds the texts waiting for approval
+type Queue struct {
+	sync.Mutex
+	nextId    int
+	pending   map[int]*Pending
+	timeout   time.Duration
+	now       func() time.Time
+	onExpired func(p *Pending)
+}
+
+// MakeQueue makes an approval queue. Texts not decided in timeout are expired and passed to onExpired
+func MakeQueue(timeout time.Duration, onExpired func(p *Pending)) *Queue {
+	if timeout <= 0 {
+		timeout = defaultApprovalTimeout
+	}
+	return &Queue{
+		nextId:    1,
+		pending:   map[int]*Pending{},
+		timeout:   timeout,
+		now:       time.Now,
+		onExpired: onExpired,
+	}
+}
+
+// SetNow replaces the time source of the timeouts
+func (q *Queue) SetNow(now func() time.Time) {
+	q.Lock()
+	defer q.Unlock()
+	q.now = now
+}
+
+// Submit adds p to the queue and returns its id
+func (q *Queue) Submit(p *Pending) int {
+	expired := q.expire()
+	defer notifyExpired(q.onExpired, expired)
+
+	q.Lock()
+	defer q.Unlock()
+
+	p.Id = q.nextId
+	p.At = q.now()
+	q.nextId++
+	q.pending[p.Id] = p
+
+	log.Info("Text %d from %s waiting for approval: %s", p.Id, p.User, p.Text)
+
+	return p.Id
+}
+
+// Approve removes the text from the queue and publishes it
+func (q *Queue) Approve(id int) (*Pending, error) {
+	p, err := q.take(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Publish != nil {
+		p.Publish()
+	}
+	return p, nil
+}
+
+// Reject removes the text from the queue
+func (q *Queue) Reject(id int) (*Pending, error) {
+	p, err := q.take(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Cancel != nil {
+		p.Cancel()
+	}
+	return p, nil
+}
+
+// List returns the pending texts, oldest first
+func (q *Queue) List() []*Pending {
+	expired := q.expire()
+	defer notifyExpired(q.onExpired, expired)
+
+	q.Lock()
+	defer q.Unlock()
+
+	list := make([]*Pending, 0, len(q.pending))
+	for _, p := range q.pending {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+
+	return list
+}
+
+func (q *Queue) take(id int) (*Pending, error) {
+	expired := q.expire()
+	defer notifyExpired(q.onExpired, expired)
+
+	q.Lock()
+	defer q.Unlock()
+
+	p, ok := q.pending[id]
+	if !ok {
+		return nil, ErrNotPending
+	}
+	delete(q.pending, id)
+
+	return p, nil
+}
+
+// Expire rejects the texts past the timeout. The queue also expires them on every access
+func (q *Queue) Expire() {
+	notifyExpired(q.onExpired, q.expire())
+}
+
+// expire removes the texts past the timeout. They are notified without the lock held
+func (q *Queue) expire() []*Pending {
+	q.Lock()
+	defer q.Unlock()
+
+	var expired []*Pending
+	now := q.now()
+	for id, p := range q.pending {
+		if now.Sub(p.At) >= q.timeout {
+			expired = append(expired, p)
+			delete(q.pending, id)
+		}
+	}
+
+	return expired
+}
+
+func notifyExpired(onExpired func(p *Pending), expired []*Pending) {
+	for _, p := range expired {
+		log.Info("Text %d from %s expired without approval", p.Id, p.User)
+		if p.Cancel != nil {
+			p.Cancel()
+		}
+		if onExpired != nil {
+			onExpired(p)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+// testQueue returns a queue on a fake clock, moved with the returned function
+func testQueue(timeout time.Duration, onExpired func(p *Pending)) (*Queue, func(d time.Duration)) {
+	now := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC)
+	q := MakeQueue(timeout, onExpired)
+	q.SetNow(func() time.Time { return now })
+	return q, func(d time.Duration) {
+		now = now.Add(d)
+	}
+}
+
+// pending returns a text that records if it was published or cancelled
+func pending(user, text string, published, cancelled *[]string) *Pending {
+	return &Pending{
+		User:    user,
+		Text:    text,
+		Publish: func() { *published = append(*published, text) },
+		Cancel:  func() { *cancelled = append(*cancelled, text) },
+	}
+}
+
+func TestQueueApproveReject(t *testing.T) {
+	var published, cancelled []string
+	q, _ := testQueue(time.Minute, nil)
+
+	first := q.Submit(pending("bob", "hello", &published, &cancelled))
+	second := q.Submit(pending("alice", "hi", &published, &cancelled))
+	if first == second {
+		t.Fatalf("both texts have the id %d", first)
+	}
+
+	list := q.List()
+	if len(list) != 2 || list[0].Id != first || list[1].Id != second {
+		t.Fatalf("List() = %v, want ids %d and %d", list, first, second)
+	}
+
+	p, err := q.Approve(first)
+	if err != nil {
+		t.Fatalf("Approve: %s", err)
+	}
+	if p.Text != "hello" || len(published) != 1 || len(cancelled) != 0 {
+		t.Errorf("approved %q, published %v, cancelled %v", p.Text, published, cancelled)
+	}
+	if _, err := q.Approve(first); err != ErrNotPending {
+		t.Errorf("second Approve = %v, want ErrNotPending", err)
+	}
+
+	p, err = q.Reject(second)
+	if err != nil {
+		t.Fatalf("Reject: %s", err)
+	}
+	if p.Text != "hi" || len(published) != 1 || len(cancelled) != 1 || cancelled[0] != "hi" {
+		t.Errorf("rejected %q, published %v, cancelled %v", p.Text, published, cancelled)
+	}
+	if _, err := q.Reject(second); err != ErrNotPending {
+		t.Errorf("second Reject = %v, want ErrNotPending", err)
+	}
+	if _, err := q.Approve(42); err != ErrNotPending {
+		t.Errorf("Approve of an unknown id = %v, want ErrNotPending", err)
+	}
+	if len(q.List()) != 0 {
+		t.Errorf("queue is not empty")
+	}
+}
+
+func TestQueueExpire(t *testing.T) {
+	var published, cancelled []string
+	var expired []*Pending
+	q, advance := testQueue(time.Minute, func(p *Pending) {
+		expired = append(expired, p)
+	})
+
+	old := q.Submit(pending("bob", "old", &published, &cancelled))
+	advance(30 * time.Second)
+	recent := q.Submit(pending("alice", "recent", &published, &cancelled))
+
+	advance(30 * time.Second)
+	q.Expire()
+	if len(expired) != 1 || expired[0].Id != old {
+		t.Fatalf("expired %v, want the text %d", expired, old)
+	}
+	if len(cancelled) != 1 || cancelled[0] != "old" {
+		t.Errorf("cancelled %v, want [old]", cancelled)
+	}
+	if _, err := q.Approve(old); err != ErrNotPending {
+		t.Errorf("Approve of an expired text = %v, want ErrNotPending", err)
+	}
+
+	// Every access expires, Approve does not publish a text that just expired
+	advance(30 * time.Second)
+	if _, err := q.Approve(recent); err != ErrNotPending {
+		t.Errorf("Approve after the timeout = %v, want ErrNotPending", err)
+	}
+	if len(expired) != 2 || len(published) != 0 {
+		t.Errorf("expired %d texts and published %v, want 2 and none", len(expired), published)
+	}
+}
+
+func TestQueueDefaultTimeout(t *testing.T) {
+	q, advance := testQueue(0, nil)
+	id := q.Submit(&Pending{User: "bob", Text: "hello"})
+
+	advance(defaultApprovalTimeout - time.Second)
+	if len(q.List()) != 1 {
+		t.Fatalf("text expired before the default timeout")
+	}
+	advance(time.Second)
+	if _, err := q.Reject(id); err != ErrNotPending {
+		t.Errorf("Reject after the default timeout = %v, want ErrNotPending", err)
+	}
+}
@@ -0,0 +1,134 @@
+package permissions
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultFollowerCacheTime is how long a follower lookup is kept
+const DefaultFollowerCacheTime = 10 * time.Minute
+
+// FollowLookup tells if the user id follows the channel
+type FollowLookup func(userId string) (bool, error)
+
+type followEntry struct {
+	follower bool
+	at       time.Time
+}
+
+// Checker checks the rules, looking up follows on Twitch when a rule needs it
+type Checker struct {
+	sync.Mutex
+	deny    []string
+	lookup  FollowLookup
+	ttl     time.Duration
+	now     func() time.Time
+	follows map[string]followEntry
+}
+
+// NewChecker returns a checker that uses lookup to find followers. A nil lookup treats everyone as not following
+func NewChecker(lookup FollowLookup) *Checker {
+	return &Checker{
+		lookup:  lookup,
+		ttl:     DefaultFollowerCacheTime,
+		now:     time.Now,
+		follows: map[string]followEntry{},
+	}
+}
+
+// SetDeny sets the user ids that cannot use any command
+func (c *Checker) SetDeny(ids []string) {
+	c.Lock()
+	defer c.Unlock()
+	c.deny = append([]string{}, ids...)
+}
+
+// SetCacheTime sets how long a follower lookup is kept
+func (c *Checker) SetCacheTime(ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.ttl = ttl
+}
+
+// SetNow replaces the time source of the follower cache
+func (c *Checker) SetNow(now func() time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = now
+}
+
+// Check returns nil if u can use a command with rule r, or a *Denied error.
+// The broadcaster can use everything and users above the rule level skip the subscriber limits
+func (c *Checker) Check(u User, r Rule) error {
+	if u.Broadcaster {
+		return nil
+	}
+
+	c.Lock()
+	denied := contains(c.deny, u.Id)
+	c.Unlock()
+
+	if denied || contains(r.Deny, u.Id) {
+		return &Denied{Rule: r, Reason: ReasonDenied}
+	}
+	if contains(r.Allow, u.Id) {
+		return nil
+	}
+
+	level := u.Level()
+	switch {
+	case level > r.Level:
+		return nil
+	case level == r.Level:
+		if r.Level == Subscriber && (u.SubTier < r.MinTier || u.SubMonths < r.MinMonths) {
+			return &Denied{Rule: r, Reason: ReasonSubscription}
+		}
+		return nil
+	case r.Level == Follower && c.IsFollower(u.Id):
+		return nil
+	}
+
+	return &Denied{Rule: r, Reason: ReasonLevel}
+}
+
+// Level returns the highest level of u, looking up the follow when the badges are below Follower
+func (c *Checker) Level(u User) Level {
+	level := u.Level()
+	if level == Everyone && c.IsFollower(u.Id) {
+		return Follower
+	}
+	return level
+}
+
+// IsFollower returns true if the user id follows the channel. Lookup errors count as not following
+func (c *Checker) IsFollower(userId string) bool {
+	if userId == "" {
+		return false
+	}
+
+	c.Lock()
+	lookup := c.lookup
+	now := c.now()
+	e, ok := c.follows[userId]
+	ttl := c.ttl
+	c.Unlock()
+
+	if ok && now.Sub(e.at) < ttl {
+		return e.follower
+	}
+	if lookup == nil {
+		return false
+	}
+
+	follower, err := lookup(userId)
+	if err != nil {
+		log.Error("Cannot check if %s follows the channel: %s", userId, err)
+		return false
+	}
+
+	c.Lock()
+	c.follows[userId] = followEntry{follower: follower, at: now}
+	c.Unlock()
+
+	return follower
+}
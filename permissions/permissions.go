@@ -0,0 +1,178 @@
+package permissions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+var log = slog.Scope("Permissions")
+
+// Level is a chat role. Each level includes the ones below it
+type Level int
+
+const (
+	Everyone Level = iota
+	Follower
+	Subscriber
+	VIP
+	Moderator
+	Broadcaster
+)
+
+var levelNames = map[Level]string{
+	Everyone:    "everyone",
+	Follower:    "follower",
+	Subscriber:  "subscriber",
+	VIP:         "vip",
+	Moderator:   "moderator",
+	Broadcaster: "broadcaster",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses a level name. An empty name is Everyone
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Everyone, nil
+	}
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return Everyone, fmt.Errorf("unknown permission %q", name)
+}
+
+// Rule is who can use a command
+type Rule struct {
+	Level Level
+	// MinTier and MinMonths restrict the Subscriber level, like tier 2 or subscribed for 6 months
+	MinTier   int
+	MinMonths int
+	// Allow and Deny are user ids that can always or never use the command
+	Allow []string
+	Deny  []string
+}
+
+// ParseRule converts a rule of the config
+func ParseRule(pr config.PermissionRule) (Rule, error) {
+	level, err := ParseLevel(pr.Level)
+	if err != nil {
+		return Rule{}, err
+	}
+	if pr.MinTier < 0 || pr.MinTier > 3 {
+		return Rule{}, fmt.Errorf("invalid tier %d", pr.MinTier)
+	}
+	if pr.MinMonths < 0 {
+		return Rule{}, fmt.Errorf("invalid months %d", pr.MinMonths)
+	}
+
+	return Rule{
+		Level:     level,
+		MinTier:   pr.MinTier,
+		MinMonths: pr.MinMonths,
+		Allow:     pr.Allow,
+		Deny:      pr.Deny,
+	}, nil
+}
+
+func (r Rule) String() string {
+	s := r.Level.String()
+	if r.Level == Subscriber && r.MinTier > 1 {
+		s += fmt.Sprintf(" tier %d", r.MinTier)
+	}
+	if r.Level == Subscriber && r.MinMonths > 0 {
+		s += fmt.Sprintf(" %d+ months", r.MinMonths)
+	}
+	return s
+}
+
+// User is the chat roles of a user
+type User struct {
+	Id          string
+	Login       string
+	Broadcaster bool
+	Moderator   bool
+	VIP         bool
+	Subscriber  bool
+	// SubTier is 1 to 3 and SubMonths the months subscribed
+	SubTier   int
+	SubMonths int
+}
+
+// FromMessage returns the roles of the author of a chat message, from its badges and tags
+func FromMessage(event *twitch.MessageEventData) User {
+	return User{
+		Id:          event.UserId(),
+		Login:       strings.ToLower(event.Username),
+		Broadcaster: event.IsBroadcaster(),
+		Moderator:   event.IsModerator(),
+		VIP:         event.IsVIP(),
+		Subscriber:  event.IsSubscriber(),
+		SubTier:     event.SubscriberTier(),
+		SubMonths:   event.SubscriberMonths(),
+	}
+}
+
+// Level returns the highest level of the user badges. Follows are not in the badges,
+// so it is never Follower
+func (u User) Level() Level {
+	switch {
+	case u.Broadcaster:
+		return Broadcaster
+	case u.Moderator:
+		return Moderator
+	case u.VIP:
+		return VIP
+	case u.Subscriber:
+		return Subscriber
+	}
+	return Everyone
+}
+
+// Reason is why a rule denied a user
+type Reason int
+
+const (
+	// ReasonLevel means the user role is below the rule level
+	ReasonLevel Reason = iota
+	// ReasonSubscription means the sub tier or months are below the rule ones
+	ReasonSubscription
+	// ReasonDenied means the user id is in a deny list
+	ReasonDenied
+)
+
+// Denied is returned by Check when the user cannot use a command
+type Denied struct {
+	Rule   Rule
+	Reason Reason
+}
+
+func (e *Denied) Error() string {
+	if e.Reason == ReasonDenied {
+		return "user is denied"
+	}
+	return fmt.Sprintf("only for %s", e.Rule)
+}
+
+func contains(ids []string, id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
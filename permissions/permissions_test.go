@@ -0,0 +1,43 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+func TestFromMessageLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want Level
+	}{
+		{"viewer", map[string]string{}, Everyone},
+		{"subscriber badge", map[string]string{"badges": "subscriber/6"}, Subscriber},
+		{"founder badge", map[string]string{"badges": "founder/0", "badge-info": "founder/3"}, Subscriber},
+		{"subscriber tag", map[string]string{"subscriber": "1"}, Subscriber},
+		{"vip founder", map[string]string{"badges": "vip/1,founder/0"}, VIP},
+		{"moderator", map[string]string{"badges": "founder/0", "mod": "1"}, Moderator},
+		{"broadcaster", map[string]string{"badges": "broadcaster/1"}, Broadcaster},
+	}
+
+	for _, tt := range tests {
+		u := FromMessage(twitch.MakeMessageEventData(twitch.SourceTwitch, "bob", "hi", "", tt.tags, nil))
+		if got := u.Level(); got != tt.want {
+			t.Errorf("%s: Level() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFounderMonths(t *testing.T) {
+	founder := FromMessage(twitch.MakeMessageEventData(twitch.SourceTwitch, "bob", "hi", "",
+		map[string]string{"badges": "founder/0", "badge-info": "founder/8"}, nil))
+
+	c := NewChecker(nil)
+	if err := c.Check(founder, Rule{Level: Subscriber, MinMonths: 6}); err != nil {
+		t.Errorf("founder for 8 months denied by a 6 months rule: %s", err)
+	}
+	if err := c.Check(founder, Rule{Level: Subscriber, MinMonths: 12}); err == nil {
+		t.Errorf("founder for 8 months allowed by a 12 months rule")
+	}
+}
@@ -0,0 +1,47 @@
+package twitch
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// backoff generates jittered exponential delays for reconnection attempts
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	factor  float64
+	attempt int
+}
+
+func makeBackoff(min, max time.Duration) *backoff {
+	return &backoff{
+		min:    min,
+		max:    max,
+		factor: 2,
+	}
+}
+
+// Next returns the delay before the next attempt.
+// The delay is randomly picked between half and the full exponential value
+// so multiple clients do not reconnect at the same time
+func (b *backoff) Next() time.Duration {
+	d := float64(b.min) * math.Pow(b.factor, float64(b.attempt))
+	if d > float64(b.max) {
+		d = float64(b.max)
+	}
+
+	b.attempt++
+
+	half := d / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+// Attempt returns how many delays were generated since the last reset
+func (b *backoff) Attempt() int {
+	return b.attempt
+}
+
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"gopkg.in/irc.v3"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	channelBufferSize = 16
 	ChatTLS           = "irc.chat.twitch.tv:6697"
+
+	chatPingFrequency = time.Minute
+	chatPingTimeout   = time.Second * 10
+	chatMinBackoff    = time.Second
+	chatMaxBackoff    = time.Minute * 2
 )
 
 var caps = []string{
@@ -21,37 +28,42 @@ var caps = []string{
 }
 
 type Chat struct {
+	sync.Mutex
 	id          string
+	botName     string
 	channelName string
 	conn        *tls.Conn
 	ircClient   *irc.Client
 
+	running      bool
+	done         chan struct{}
+	backoff      *backoff
+	reconnecting bool
+	authFailed   bool
+	lostAt       time.Time
+
+	queue *sendQueue
+
 	Events chan ChatEvent
 }
 
 func MakeChat(botName, channelName, chatToken string) (*Chat, error) {
 	c := &Chat{
 		id:          uuid.New().String(),
+		botName:     botName,
 		channelName: fmt.Sprintf("#%s", channelName),
+		done:        make(chan struct{}),
+		backoff:     makeBackoff(chatMinBackoff, chatMaxBackoff),
+		queue:       makeSendQueue(),
 		Events:      make(chan ChatEvent, channelBufferSize),
 	}
 
-	log.Info("Connecting to %s", ChatTLS)
-	conn, err := tls.Dial("tcp", ChatTLS, &tls.Config{})
+	err := c.dial(chatToken)
 	if err != nil {
 		return nil, err
 	}
 
-	c.conn = conn
-
-	log.Info("Connected to chat. Starting IRC")
-	c.ircClient = irc.NewClient(conn, irc.ClientConfig{
-		Nick:    botName,
-		User:    botName,
-		Name:    botName,
-		Pass:    "oauth:" + chatToken,
-		Handler: irc.HandlerFunc(c.ircHandler),
-	})
+	conn := c.conn
 
 	go c.runIRC()
 
@@ -84,35 +96,300 @@ func MakeChat(botName, channelName, chatToken string) (*Chat, error) {
 		return nil, err
 	}
 
+	c.Lock()
+	c.running = true
+	c.Unlock()
+
+	go c.sendLoop()
+
 	return c, nil
 }
 
-func (c *Chat) runIRC() {
-	log.Debug("Running IRC Client")
-	err := c.ircClient.Run()
+// dial connects to the chat server and creates a new IRC client for the connection
+func (c *Chat) dial(chatToken string) error {
+	e := GetEndpoints()
+	tlsConfig := e.ChatTLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	log.Info("Connecting to %s", e.Chat)
+	conn, err := tls.Dial("tcp", e.Chat, tlsConfig)
 	if err != nil {
-		log.Error("Error in IRC Client: ", err)
-		c.Events <- MakeErrorEvent(err)
+		return err
+	}
+
+	log.Info("Connected to chat. Starting IRC")
+	client := irc.NewClient(conn, irc.ClientConfig{
+		Nick:          c.botName,
+		User:          c.botName,
+		Name:          c.botName,
+		Pass:          "oauth:" + chatToken,
+		PingFrequency: chatPingFrequency,
+		PingTimeout:   chatPingTimeout,
+		Handler:       irc.HandlerFunc(c.ircHandler),
+	})
+
+	c.Lock()
+	c.conn = conn
+	c.ircClient = client
+	c.Unlock()
+
+	return nil
+}
+
+func (c *Chat) runIRC() {
+	for {
+		c.Lock()
+		client := c.ircClient
+		c.Unlock()
+
+		log.Debug("Running IRC Client")
+		err := client.Run()
+
+		c.Lock()
+		running := c.running
+		c.Unlock()
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		if err == nil {
+			err = fmt.Errorf("connection closed")
+		}
+
+		log.Error("Error in IRC Client: %s", err)
+
+		if !running {
+			// Still logging in at MakeChat
+			c.Events <- MakeErrorEvent(err)
+			return
+		}
+
+		if !c.reconnect(err) {
+			return
+		}
+	}
+}
+
+// reconnect dials the chat again with a fresh token.
+// The login, caps and JOIN are done by ircHandler once the server welcomes us.
+// Returns false if the chat was closed while waiting
+func (c *Chat) reconnect(reason error) bool {
+	c.Lock()
+	if !c.reconnecting {
+		c.reconnecting = true
+		c.lostAt = time.Now()
+		c.Unlock()
+		c.Events <- MakeDisconnectedEvent(ConnectionSourceChat, reason)
+	} else {
+		c.Unlock()
+	}
+
+	for {
+		c.Lock()
+		delay := c.backoff.Next()
+		attempt := c.backoff.Attempt()
+		authFailed := c.authFailed
+		c.authFailed = false
+		c.Unlock()
+
+		log.Info("Reconnecting to chat in %s (attempt %d)", delay, attempt)
+		c.Events <- MakeReconnectingEvent(ConnectionSourceChat, attempt, delay, reason)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-c.done:
+			t.Stop()
+			return false
+		case <-t.C:
+		}
+
+		if authFailed {
+			log.Info("Chat authentication failed. Refreshing token")
+			if err := RefreshToken(); err != nil {
+				reason = err
+				continue
+			}
+		}
+
+		token, err := UserToken()
+		if err != nil {
+			log.Error("Cannot get a chat token: %s", err)
+			reason = err
+			continue
+		}
+
+		err = c.dial(token.AccessToken)
+		if err != nil {
+			log.Error("Error connecting to chat: %s", err)
+			reason = err
+			continue
+		}
+
+		return true
+	}
+}
+
+// Close stops the chat and does not reconnect anymore
+func (c *Chat) Close() {
+	c.Lock()
+	if !c.running {
+		c.Unlock()
+		return
+	}
+	c.running = false
+	close(c.done)
+	conn := c.conn
+	c.Unlock()
+
+	if conn != nil {
+		_ = conn.Close()
 	}
 }
 
+func (c *Chat) client() *irc.Client {
+	c.Lock()
+	defer c.Unlock()
+	return c.ircClient
+}
+
+// SendMessage queues a message to the channel with normal priority
 func (c *Chat) SendMessage(msg string) error {
-	return c.ircClient.WriteMessage(&irc.Message{
+	return c.SendMessageWithPriority(msg, PriorityNormal)
+}
+
+// SendMessageWithPriority queues a message to the channel.
+// Messages are sent respecting Twitch rate limits, higher priorities first.
+// Returns an error if the queue is full of messages with higher priority
+func (c *Chat) SendMessageWithPriority(msg string, priority MessagePriority) error {
+	qm := &queuedMessage{
+		text:     msg,
+		priority: priority,
+		queuedAt: time.Now(),
+	}
+
+	dropped := c.queue.add(qm)
+
+	if dropped != nil {
+		log.Warn("Send queue full. Dropping %s priority message: %s", dropped.priority, dropped.text)
+		c.report(MakeMessageDroppedEvent(dropped.text, dropped.priority, time.Since(dropped.queuedAt), "queue full"))
+		if dropped == qm {
+			return fmt.Errorf("send queue full")
+		}
+	}
+
+	return nil
+}
+
+// QueueLength returns how many messages are waiting to be sent
+func (c *Chat) QueueLength() int {
+	return c.queue.Len()
+}
+
+func (c *Chat) writeMessage(msg string) error {
+	return c.client().WriteMessage(&irc.Message{
 		Params:  []string{c.channelName, msg},
 		Command: "PRIVMSG",
 	})
 }
 
+// report sends a send queue event without blocking, since SendMessage is usually
+// called by the same goroutine that reads the Events channel
+func (c *Chat) report(e ChatEvent) {
+	select {
+	case c.Events <- e:
+	default:
+		log.Warn("Events channel full. Discarding %s event", e.GetType())
+	}
+}
+
+// sendLoop writes queued messages to the chat respecting the rate limit
+func (c *Chat) sendLoop() {
+	for {
+		for _, v := range c.queue.expire(time.Now()) {
+			log.Warn("Dropping %s priority message after %s in queue: %s", v.priority, time.Since(v.queuedAt), v.text)
+			c.report(MakeMessageDroppedEvent(v.text, v.priority, time.Since(v.queuedAt), "expired"))
+		}
+
+		msg, wait := c.queue.next(time.Now())
+
+		if msg == nil {
+			var t *time.Timer
+			var timer <-chan time.Time
+			if wait > 0 {
+				t = time.NewTimer(wait)
+				timer = t.C
+			}
+
+			select {
+			case <-c.done:
+				return
+			case <-c.queue.notify:
+			case <-timer:
+			}
+
+			if t != nil {
+				t.Stop()
+			}
+			continue
+		}
+
+		c.Lock()
+		reconnecting := c.reconnecting
+		c.Unlock()
+
+		var err error
+		if !reconnecting {
+			err = c.writeMessage(msg.text)
+		}
+
+		if reconnecting || err != nil {
+			if err != nil {
+				log.Error("Error sending message: %s", err)
+			}
+			c.queue.requeue(msg)
+			select {
+			case <-c.done:
+				return
+			case <-time.After(sendRetryInterval):
+			}
+			continue
+		}
+
+		delay := time.Since(msg.queuedAt)
+		if delay > sendDelayedThreshold {
+			c.report(MakeMessageDelayedEvent(msg.text, msg.priority, delay))
+		}
+	}
+}
+
 func (c *Chat) SendRawMessage(msg string) error {
-	return c.ircClient.Write(msg)
+	return c.client().Write(msg)
 }
 
 func (c *Chat) ircHandler(ircClient *irc.Client, m *irc.Message) {
 	switch m.Command {
 	case "001": // Welcome
 		log.Debug("Joining channel %s", c.channelName)
-		c.SendRawMessage(fmt.Sprintf("JOIN %s", c.channelName))
-		c.Events <- MakeLoginEvent(true, m.Params[1])
+		_ = ircClient.Write(fmt.Sprintf("JOIN %s", c.channelName))
+
+		c.Lock()
+		reconnecting := c.reconnecting
+		attempt := c.backoff.Attempt()
+		lostAt := c.lostAt
+		c.reconnecting = false
+		c.backoff.Reset()
+		c.Unlock()
+
+		if reconnecting {
+			c.Events <- MakeReconnectedEvent(ConnectionSourceChat, attempt, time.Since(lostAt))
+		} else {
+			c.Events <- MakeLoginEvent(true, m.Params[1])
+		}
 	case "002":
 	case "003":
 	case "004":
@@ -136,7 +413,7 @@ func (c *Chat) ircHandler(ircClient *irc.Client, m *irc.Message) {
 	case "376": // MOTD End
 		log.Info("Requesting custom caps")
 		for _, v := range caps {
-			c.SendRawMessage(v)
+			_ = ircClient.Write(v)
 		}
 	case "CAP":
 		if m.Params[1] == "ACK" {
@@ -146,6 +423,11 @@ func (c *Chat) ircHandler(ircClient *irc.Client, m *irc.Message) {
 		}
 	case "USERSTATE":
 		//log.Info("USERSTATE -- %+v", m.Params)
+		us := MakeUserStateEventData(m.Params[0], false, tagsFromMessage(m)).(*UserStateEventData)
+		if us.Channel == c.channelName {
+			c.queue.setModerator(us.IsModerator() || us.IsBroadcaster())
+		}
+		c.Events <- us
 	case "PRIVMSG":
 		if ircClient.FromChannel(m) && len(m.Params) >= 2 {
 			// channel := m.Params[0]
@@ -156,24 +438,73 @@ func (c *Chat) ircHandler(ircClient *irc.Client, m *irc.Message) {
 			if err == nil {
 				picture = pic
 			}
-			tags := map[string]string{}
 
-			for k, v := range m.Tags {
-				tags[k] = v.Encode()
+			c.Events <- MakeMessageEventData(SourceTwitch, from, message, picture, tagsFromMessage(m), m)
+		}
+	case "USERNOTICE":
+		message := ""
+		if len(m.Params) >= 2 {
+			message = m.Params[1]
+		}
+		c.Events <- MakeUserNoticeEventData(m.Params[0], message, tagsFromMessage(m))
+	case "CLEARCHAT":
+		target := ""
+		if len(m.Params) >= 2 {
+			target = m.Params[1]
+		}
+		c.Events <- MakeClearChatEventData(m.Params[0], target, tagsFromMessage(m))
+	case "CLEARMSG":
+		message := ""
+		if len(m.Params) >= 2 {
+			message = m.Params[1]
+		}
+		c.Events <- MakeClearMsgEventData(m.Params[0], message, tagsFromMessage(m))
+	case "ROOMSTATE":
+		c.Events <- MakeRoomStateEventData(m.Params[0], tagsFromMessage(m))
+	case "GLOBALUSERSTATE":
+		c.Events <- MakeUserStateEventData("", true, tagsFromMessage(m))
+	case "HOSTTARGET":
+		// :tmi.twitch.tv HOSTTARGET #channel :target viewers
+		if len(m.Params) >= 2 {
+			v := strings.SplitN(m.Params[1], " ", 2)
+			target := v[0]
+			viewers := 0
+			if target == "-" {
+				target = ""
 			}
-
-			c.Events <- MakeMessageEventData(SourceTwitch, from, message, picture, tags, m)
+			if len(v) == 2 {
+				viewers, _ = strconv.Atoi(v[1])
+			}
+			c.Events <- MakeHostTargetEventData(m.Params[0], target, viewers)
+		}
+	case "WHISPER":
+		if len(m.Params) >= 2 {
+			c.Events <- MakeWhisperEventData(m.User, m.Params[1], tagsFromMessage(m))
 		}
 	case "NOTICE":
 		log.Debug("[%s] %s {{%+v}}", m.Command, m.String(), m.Params)
 		if strings.Contains(m.Params[1], "Login authentication failed") {
 			// Login failed
+			c.Lock()
+			c.authFailed = true
+			c.Unlock()
 			c.Events <- MakeLoginEvent(false, m.Params[1])
+		} else {
+			c.Events <- MakeNoticeEventData(m.Params[0], m.Params[1], tagsFromMessage(m))
 		}
+	case "RECONNECT":
+		// Twitch is restarting the server. Drop the connection and let runIRC reconnect
+		log.Info("Chat server requested reconnect")
+		c.Lock()
+		conn := c.conn
+		c.Unlock()
+		_ = conn.Close()
 	case "JOIN":
 		log.Debug("JOIN: %s joins %s", m.User, m.Params[0])
+		c.Events <- MakeMembershipEventData(true, m.Params[0], m.User)
 	case "PART":
 		log.Debug("PART: %s parts %s", m.User, m.Params[0])
+		c.Events <- MakeMembershipEventData(false, m.Params[0], m.User)
 	case "PING":
 		// Handled by IRC Library
 		//log.Info("Received PING")
@@ -189,3 +520,8 @@ func (c *Chat) EventChannel() chan ChatEvent {
 func (c *Chat) Id() string {
 	return c.id
 }
+
+// Channel returns the name of the channel, without the "#"
+func (c *Chat) Channel() string {
+	return strings.TrimPrefix(c.channelName, "#")
+}
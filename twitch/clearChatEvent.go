@@ -0,0 +1,70 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ClearChatEventData is a CLEARCHAT message. It either clears the whole chat
+// or removes the messages of a banned / timed out user
+type ClearChatEventData struct {
+	Channel    string
+	TargetUser string
+	Tags       map[string]string
+	timestamp  time.Time
+}
+
+func (e *ClearChatEventData) GetType() EventType {
+	return EventClearChat
+}
+
+func (e *ClearChatEventData) GetData() interface{} {
+	return e
+}
+
+// IsChatClear returns true if all messages were removed instead of a single user ones
+func (e *ClearChatEventData) IsChatClear() bool {
+	return e.TargetUser == ""
+}
+
+func (e *ClearChatEventData) TargetUserId() string {
+	return e.Tags["target-user-id"]
+}
+
+// BanDuration returns the timeout duration. Zero means permanent ban
+func (e *ClearChatEventData) BanDuration() time.Duration {
+	return time.Duration(tagInt(e.Tags, "ban-duration")) * time.Second
+}
+
+func (e *ClearChatEventData) IsPermanentBan() bool {
+	_, ok := e.Tags["ban-duration"]
+	return !e.IsChatClear() && !ok
+}
+
+func (e *ClearChatEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        e.GetType(),
+		"channel":     e.Channel,
+		"target_user": e.TargetUser,
+		"tags":        e.Tags,
+		"timestamp":   e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *ClearChatEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *ClearChatEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeClearChatEventData(channel, targetUser string, tags map[string]string) ChatEvent {
+	return &ClearChatEventData{
+		Channel:    channel,
+		TargetUser: targetUser,
+		Tags:       tags,
+		timestamp:  time.Now(),
+	}
+}
@@ -0,0 +1,60 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ClearMsgEventData is a CLEARMSG message, sent when a single message is deleted
+type ClearMsgEventData struct {
+	Channel   string
+	Message   string
+	Tags      map[string]string
+	timestamp time.Time
+}
+
+func (e *ClearMsgEventData) GetType() EventType {
+	return EventClearMsg
+}
+
+func (e *ClearMsgEventData) GetData() interface{} {
+	return e
+}
+
+// Login returns the login of the user that sent the deleted message
+func (e *ClearMsgEventData) Login() string {
+	return e.Tags["login"]
+}
+
+// TargetMessageId returns the id of the deleted message
+func (e *ClearMsgEventData) TargetMessageId() string {
+	return e.Tags["target-msg-id"]
+}
+
+func (e *ClearMsgEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"message":   e.Message,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *ClearMsgEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *ClearMsgEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeClearMsgEventData(channel, message string, tags map[string]string) ChatEvent {
+	return &ClearMsgEventData{
+		Channel:   channel,
+		Message:   message,
+		Tags:      tags,
+		timestamp: time.Now(),
+	}
+}
@@ -5,15 +5,32 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/gob"
+	"sync"
+
 	"github.com/racerxdl/twitchled/config"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
-	"golang.org/x/oauth2/twitch"
 )
 
-var appToken *oauth2.Token
+var (
+	appTokenLock sync.Mutex
+	appToken     *oauth2.Token
+)
+
+// SetAppToken replaces the app token without saving it to config
+func SetAppToken(t *oauth2.Token) {
+	appTokenLock.Lock()
+	defer appTokenLock.Unlock()
+	appToken = t
+}
 
 func LoadClientToken() {
+	appTokenLock.Lock()
+	defer appTokenLock.Unlock()
+	loadClientToken()
+}
+
+func loadClientToken() {
 	b64data := config.GetConfig().TwitchAppTokenData
 	data, err := base64.StdEncoding.DecodeString(b64data)
 	if err != nil {
@@ -22,17 +39,21 @@ func LoadClientToken() {
 	}
 	d := gob.NewDecoder(bytes.NewBuffer(data))
 
-	err = d.Decode(&appToken)
+	var t *oauth2.Token
+	err = d.Decode(&t)
 	if err != nil {
 		log.Error("No app token data on disk or invalid: %s", err)
 		return
 	}
+	appToken = t
 }
 
 func GetAppToken() string {
-	var err error
+	appTokenLock.Lock()
+	defer appTokenLock.Unlock()
+
 	if appToken == nil {
-		LoadClientToken()
+		loadClientToken()
 	}
 
 	if appToken.Valid() {
@@ -43,15 +64,17 @@ func GetAppToken() string {
 	cfg := &clientcredentials.Config{
 		ClientID:     c.TwitchOAuthClient,
 		ClientSecret: c.TwitchOAuthSecret,
-		TokenURL:     twitch.Endpoint.TokenURL,
+		TokenURL:     GetEndpoints().OAuthToken,
 	}
 
-	appToken, err = cfg.Token(context.Background())
+	t, err := cfg.Token(context.Background())
 	if err != nil {
 		log.Error("Error getting token: %s", err)
 		return ""
 	}
 
+	appToken = t
+
 	buff := bytes.NewBuffer(nil)
 	e := gob.NewEncoder(buff)
 	e.Encode(&appToken)
@@ -16,6 +16,21 @@ const (
 	EventRewardRedemption EventType = "REWARD_REDEMPTION"
 	EventFollow           EventType = "FOLLOW"
 	EventChannelUpdate    EventType = "CHANNEL_UPDATE"
+	EventDisconnected     EventType = "DISCONNECTED"
+	EventReconnecting     EventType = "RECONNECTING"
+	EventReconnected      EventType = "RECONNECTED"
+	EventMessageDropped   EventType = "MESSAGE_DROPPED"
+	EventMessageDelayed   EventType = "MESSAGE_DELAYED"
+	EventUserNotice       EventType = "USER_NOTICE"
+	EventClearChat        EventType = "CLEAR_CHAT"
+	EventClearMsg         EventType = "CLEAR_MSG"
+	EventRoomState        EventType = "ROOM_STATE"
+	EventUserState        EventType = "USER_STATE"
+	EventNotice           EventType = "NOTICE"
+	EventHostTarget       EventType = "HOST_TARGET"
+	EventWhisper          EventType = "WHISPER"
+	EventJoin             EventType = "JOIN"
+	EventPart             EventType = "PART"
 )
 
 func (st EventType) String() string {
@@ -0,0 +1,93 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ConnectionSourceEventSub = "EventSub"
+	ConnectionSourceChat     = "Chat"
+)
+
+// ConnectionEventData reports connection state changes of a twitch connection
+type ConnectionEventData struct {
+	eventType EventType
+	Source    string
+	Attempt   int
+	Delay     time.Duration
+	Downtime  time.Duration
+	err       error
+	timestamp time.Time
+}
+
+func (e *ConnectionEventData) GetType() EventType {
+	return e.eventType
+}
+
+func (e *ConnectionEventData) GetData() interface{} {
+	return e
+}
+
+// Error returns the reason of the disconnection or an empty string if none
+func (e *ConnectionEventData) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+func (e *ConnectionEventData) RawError() error {
+	return e.err
+}
+
+func (e *ConnectionEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"source":    e.Source,
+		"attempt":   e.Attempt,
+		"delay":     e.Delay.String(),
+		"downtime":  e.Downtime.String(),
+		"err":       e.Error(),
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *ConnectionEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *ConnectionEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeDisconnectedEvent(source string, err error) ChatEvent {
+	return &ConnectionEventData{
+		eventType: EventDisconnected,
+		Source:    source,
+		err:       err,
+		timestamp: time.Now(),
+	}
+}
+
+func MakeReconnectingEvent(source string, attempt int, delay time.Duration, err error) ChatEvent {
+	return &ConnectionEventData{
+		eventType: EventReconnecting,
+		Source:    source,
+		Attempt:   attempt,
+		Delay:     delay,
+		err:       err,
+		timestamp: time.Now(),
+	}
+}
+
+func MakeReconnectedEvent(source string, attempt int, downtime time.Duration) ChatEvent {
+	return &ConnectionEventData{
+		eventType: EventReconnected,
+		Source:    source,
+		Attempt:   attempt,
+		Downtime:  downtime,
+		timestamp: time.Now(),
+	}
+}
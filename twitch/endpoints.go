@@ -0,0 +1,49 @@
+package twitch
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// Endpoints are the addresses used to talk with twitch.
+// They can be replaced to point to a fake server, like the one in twitchtest
+type Endpoints struct {
+	// Chat is the IRC over TLS address in host:port format
+	Chat string
+	// ChatTLSConfig is used to dial Chat. If nil the system roots are used
+	ChatTLSConfig *tls.Config
+	// EventSubWebsocket is the EventSub websocket transport url
+	EventSubWebsocket string
+	// Helix is the base url of the Helix API
+	Helix string
+	// OAuthToken is the url used to refresh user tokens and create app tokens
+	OAuthToken string
+}
+
+// DefaultEndpoints returns the real twitch endpoints
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Chat:              ChatTLS,
+		EventSubWebsocket: twitchWssUrl,
+		Helix:             HelixAPI,
+		OAuthToken:        oauthTokenUrl,
+	}
+}
+
+var (
+	endpoints     = DefaultEndpoints()
+	endpointsLock sync.RWMutex
+)
+
+// SetEndpoints replaces the endpoints used by MakeChat, MakeMonitor, the Helix functions and websub
+func SetEndpoints(e Endpoints) {
+	endpointsLock.Lock()
+	defer endpointsLock.Unlock()
+	endpoints = e
+}
+
+func GetEndpoints() Endpoints {
+	endpointsLock.RLock()
+	defer endpointsLock.RUnlock()
+	return endpoints
+}
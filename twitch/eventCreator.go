@@ -2,63 +2,159 @@ package twitch
 
 import (
 	"encoding/json"
-	"fmt"
+	"time"
+
 	"github.com/racerxdl/twitchled/twitch/twitchdata"
 )
 
-func makeSubscribeEvent(channelId, data string) (ChatEvent, error) {
-	bitsData := twitchdata.ChannelSubscribeMessageData{}
+// The functions below map EventSub notifications to the same event data that PubSub used to send
 
-	err := json.Unmarshal([]byte(data), &bitsData)
+func makeRewardRedeemed(channelId string, data json.RawMessage) (ChatEvent, error) {
+	e := eventSubRedemption{}
+	err := json.Unmarshal(data, &e)
 	if err != nil {
 		return nil, err
 	}
 
-	return MakeSubscribeEventData(channelId, bitsData), nil
+	redemptionData := twitchdata.RedemptionData{
+		Id: e.Id,
+		User: twitchdata.User{
+			Id:          e.UserId,
+			Name:        e.UserLogin,
+			DisplayName: e.UserName,
+		},
+		ChannelId:  e.BroadcasterUserId,
+		RedeemedAt: e.RedeemedAt,
+		Reward: twitchdata.RewardData{
+			Id:        e.Reward.Id,
+			ChannelId: e.BroadcasterUserId,
+			Title:     e.Reward.Title,
+			Prompt:    e.Reward.Prompt,
+			Cost:      e.Reward.Cost,
+		},
+		UserInput: e.UserInput,
+		Status:    e.Status,
+	}
+
+	return MakeRewardRedemptionEventData(channelId, redemptionData), nil
 }
-func makeRewardRedeemed(channelId, data string) (ChatEvent, error) {
-	redemptionData := twitchdata.RedemptionData{}
-	err := json.Unmarshal([]byte(data), &redemptionData)
+
+func makeSubscribeEvent(channelId string, data json.RawMessage) (ChatEvent, error) {
+	e := eventSubSubscribe{}
+	err := json.Unmarshal(data, &e)
 	if err != nil {
 		return nil, err
 	}
 
-	return MakeRewardRedemptionEventData(channelId, redemptionData), nil
-}
+	if e.IsGift {
+		// The gifter is notified by channel.subscription.gift
+		return nil, nil
+	}
 
-func makePointsEvent(channelId, data string) (ChatEvent, error) {
-	tmp := make(map[string]interface{})
+	subData := twitchdata.ChannelSubscribeMessageData{
+		UserName:    e.UserLogin,
+		DisplayName: e.UserName,
+		UserId:      e.UserId,
+		ChannelName: e.BroadcasterUserLogin,
+		ChannelId:   e.BroadcasterUserId,
+		SubPlan:     e.Tier,
+		Context:     "sub",
+		Time:        time.Now(),
+	}
 
-	err := json.Unmarshal([]byte(data), &tmp)
+	return MakeSubscribeEventData(channelId, subData), nil
+}
 
+func makeSubscriptionMessageEvent(channelId string, data json.RawMessage) (ChatEvent, error) {
+	e := eventSubSubscriptionMessage{}
+	err := json.Unmarshal(data, &e)
 	if err != nil {
 		return nil, err
 	}
 
-	content, err := json.Marshal(tmp["data"].(map[string]interface{})["redemption"])
+	emotes := make([]twitchdata.Emote, 0, len(e.Message.Emotes))
+	for _, v := range e.Message.Emotes {
+		emotes = append(emotes, twitchdata.Emote{
+			Start: v.Begin,
+			End:   v.End,
+			Id:    v.Id,
+		})
+	}
 
+	streak := 0
+	if e.StreakMonths != nil {
+		streak = *e.StreakMonths
+	}
+
+	subData := twitchdata.ChannelSubscribeMessageData{
+		UserName:         e.UserLogin,
+		DisplayName:      e.UserName,
+		UserId:           e.UserId,
+		ChannelName:      e.BroadcasterUserLogin,
+		ChannelId:        e.BroadcasterUserId,
+		SubPlan:          e.Tier,
+		CumulativeMonths: e.CumulativeMonths,
+		StreakMonths:     streak,
+		Context:          "resub",
+		Time:             time.Now(),
+		SubMessage: twitchdata.ChannelSubscriberMessage{
+			Message: e.Message.Text,
+			Emotes:  emotes,
+		},
+	}
+
+	return MakeSubscribeEventData(channelId, subData), nil
+}
+
+func makeSubscriptionGiftEvent(channelId string, data json.RawMessage) (ChatEvent, error) {
+	e := eventSubSubscriptionGift{}
+	err := json.Unmarshal(data, &e)
 	if err != nil {
 		return nil, err
 	}
 
-	pointType := tmp["type"].(string)
+	subData := twitchdata.ChannelSubscribeMessageData{
+		UserName:    e.UserLogin,
+		DisplayName: e.UserName,
+		UserId:      e.UserId,
+		ChannelName: e.BroadcasterUserLogin,
+		ChannelId:   e.BroadcasterUserId,
+		SubPlan:     e.Tier,
+		IsGift:      true,
+		Context:     "subgift",
+		Time:        time.Now(),
+	}
 
-	switch pointType {
-	case twitchdata.ChannelPointsRewardRedeemed:
-		return makeRewardRedeemed(channelId, string(content))
+	if e.IsAnonymous {
+		subData.DisplayName = "Anonymous"
+		subData.Context = "anonsubgift"
 	}
 
-	// if not know, return error
-	return nil, fmt.Errorf("unknown point reward type: %s", pointType)
+	return MakeSubscribeEventData(channelId, subData), nil
 }
 
-func makeBitsEvent(channelId, data string) (ChatEvent, error) {
-	bitsData := twitchdata.BitEventsV2{}
-
-	err := json.Unmarshal([]byte(data), &bitsData)
+func makeBitsEvent(channelId, messageId string, data json.RawMessage) (ChatEvent, error) {
+	e := eventSubCheer{}
+	err := json.Unmarshal(data, &e)
 	if err != nil {
 		return nil, err
 	}
 
+	bitsData := twitchdata.BitEventsV2{
+		Data: twitchdata.BitEventsData{
+			UserName:    e.UserLogin,
+			UserId:      e.UserId,
+			ChannelId:   e.BroadcasterUserId,
+			Time:        time.Now(),
+			ChatMessage: e.Message,
+			BitsUsed:    e.Bits,
+			Context:     "cheer",
+		},
+		Version:     "1.0",
+		MessageType: "bits_event",
+		MessageId:   messageId,
+		IsAnonymous: e.IsAnonymous,
+	}
+
 	return MakeBitsV2EventData(channelId, bitsData), nil
 }
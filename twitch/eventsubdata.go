@@ -0,0 +1,135 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EventSub subscription types handled by Monitor
+const (
+	eventSubChannelPointsRedemption = "channel.channel_points_custom_reward_redemption.add"
+	eventSubChannelSubscribe        = "channel.subscribe"
+	eventSubChannelSubMessage       = "channel.subscription.message"
+	eventSubChannelSubGift          = "channel.subscription.gift"
+	eventSubChannelCheer            = "channel.cheer"
+)
+
+// eventSubVersions has the version used for each subscription type
+var eventSubVersions = map[string]string{
+	eventSubChannelPointsRedemption: "1",
+	eventSubChannelSubscribe:        "1",
+	eventSubChannelSubMessage:       "1",
+	eventSubChannelSubGift:          "1",
+	eventSubChannelCheer:            "1",
+}
+
+// EventSub websocket message types
+const (
+	eventSubSessionWelcome   = "session_welcome"
+	eventSubSessionKeepalive = "session_keepalive"
+	eventSubSessionReconnect = "session_reconnect"
+	eventSubNotification     = "notification"
+	eventSubRevocation       = "revocation"
+)
+
+type eventSubMetadata struct {
+	MessageId           string    `json:"message_id"`
+	MessageType         string    `json:"message_type"`
+	MessageTimestamp    time.Time `json:"message_timestamp"`
+	SubscriptionType    string    `json:"subscription_type"`
+	SubscriptionVersion string    `json:"subscription_version"`
+}
+
+type eventSubSession struct {
+	Id                      string `json:"id"`
+	Status                  string `json:"status"`
+	KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+	ReconnectUrl            string `json:"reconnect_url"`
+}
+
+type eventSubSubscription struct {
+	Id        string                 `json:"id"`
+	Status    string                 `json:"status"`
+	Type      string                 `json:"type"`
+	Version   string                 `json:"version"`
+	Condition map[string]interface{} `json:"condition"`
+}
+
+type eventSubPayload struct {
+	Session      *eventSubSession      `json:"session"`
+	Subscription *eventSubSubscription `json:"subscription"`
+	Event        json.RawMessage       `json:"event"`
+}
+
+type eventSubMessage struct {
+	Metadata eventSubMetadata `json:"metadata"`
+	Payload  eventSubPayload  `json:"payload"`
+}
+
+type eventSubUser struct {
+	UserId    string `json:"user_id"`
+	UserLogin string `json:"user_login"`
+	UserName  string `json:"user_name"`
+}
+
+type eventSubBroadcaster struct {
+	BroadcasterUserId    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+type eventSubRedemption struct {
+	eventSubUser
+	eventSubBroadcaster
+	Id         string    `json:"id"`
+	UserInput  string    `json:"user_input"`
+	Status     string    `json:"status"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+	Reward     struct {
+		Id     string `json:"id"`
+		Title  string `json:"title"`
+		Cost   int    `json:"cost"`
+		Prompt string `json:"prompt"`
+	} `json:"reward"`
+}
+
+type eventSubSubscribe struct {
+	eventSubUser
+	eventSubBroadcaster
+	Tier   string `json:"tier"`
+	IsGift bool   `json:"is_gift"`
+}
+
+type eventSubSubscriptionMessage struct {
+	eventSubUser
+	eventSubBroadcaster
+	Tier    string `json:"tier"`
+	Message struct {
+		Text   string `json:"text"`
+		Emotes []struct {
+			Begin int    `json:"begin"`
+			End   int    `json:"end"`
+			Id    string `json:"id"`
+		} `json:"emotes"`
+	} `json:"message"`
+	CumulativeMonths int  `json:"cumulative_months"`
+	StreakMonths     *int `json:"streak_months"`
+	DurationMonths   int  `json:"duration_months"`
+}
+
+type eventSubSubscriptionGift struct {
+	eventSubUser
+	eventSubBroadcaster
+	Total           int    `json:"total"`
+	Tier            string `json:"tier"`
+	CumulativeTotal *int   `json:"cumulative_total"`
+	IsAnonymous     bool   `json:"is_anonymous"`
+}
+
+type eventSubCheer struct {
+	eventSubUser
+	eventSubBroadcaster
+	IsAnonymous bool   `json:"is_anonymous"`
+	Message     string `json:"message"`
+	Bits        int    `json:"bits"`
+}
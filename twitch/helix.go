@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"net"
@@ -16,6 +17,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/sessions"
@@ -31,7 +33,8 @@ const (
 	oauthSessionName = "oauth-session"
 	oauthTokenKey    = "oauth-token"
 
-	HelixAPI = "https://api.twitch.tv/helix"
+	HelixAPI      = "https://api.twitch.tv/helix"
+	oauthTokenUrl = "https://id.twitch.tv/oauth2/token"
 )
 
 var (
@@ -39,7 +42,10 @@ var (
 	oauth2Config *oauth2.Config
 	cookieSecret = []byte("ABCDE")
 	cookieStore  = sessions.NewCookieStore(cookieSecret)
-	token        *oauth2.Token
+
+	tokenLock   sync.Mutex
+	refreshLock sync.Mutex
+	token       *oauth2.Token
 )
 
 func init() {
@@ -121,16 +127,17 @@ func HandleOAuth2Callback(w http.ResponseWriter, r *http.Request) (err error) {
 		)
 	}
 
-	token, err = oauth2Config.Exchange(context.Background(), r.FormValue("code"))
+	t, err := oauth2Config.Exchange(context.Background(), r.FormValue("code"))
 	if err != nil {
 		return
 	}
 
 	// add the oauth token to session
-	session.Values[oauthTokenKey] = token
+	session.Values[oauthTokenKey] = t
 
-	if token.Valid() {
-		SaveToken()
+	if t.Valid() {
+		SetToken(t)
+		saveToken(t)
 	}
 
 	http.Redirect(w, r, "/done", http.StatusTemporaryRedirect)
@@ -194,9 +201,13 @@ func AnnotateError(err error, annotation string, code int) error {
 type Handler func(http.ResponseWriter, *http.Request) error
 
 func SaveToken() {
+	saveToken(currentToken())
+}
+
+func saveToken(t *oauth2.Token) {
 	buff := bytes.NewBuffer(nil)
 	e := gob.NewEncoder(buff)
-	e.Encode(&token)
+	e.Encode(&t)
 
 	config.SetTwitchToken(buff.Bytes())
 }
@@ -210,82 +221,175 @@ func LoadToken() {
 	}
 	d := gob.NewDecoder(bytes.NewBuffer(data))
 
-	err = d.Decode(&token)
+	var t *oauth2.Token
+	err = d.Decode(&t)
 	if err != nil {
 		log.Error("No token data on disk or invalid: %s", err)
 		return
 	}
+	SetToken(t)
+}
+
+// refreshRejected is returned when Twitch does not accept the refresh token. A new login is needed
+type refreshRejected struct {
+	status int
+	body   string
+}
+
+func (e *refreshRejected) Error() string {
+	return fmt.Sprintf("refresh token rejected (%d): %s", e.status, e.body)
 }
 
-func RefreshToken() {
-	if token != nil && token.RefreshToken != "" {
-		_, err := GetChannelId()
-		if err == nil {
-			token.Expiry = time.Now().Add(time.Hour) // Token is valid, force to check again in a hour
-			return
+// RefreshToken renews the user token if Twitch does not accept it anymore. Errors are returned,
+// so the callers can retry later, like the chat reconnect backoff
+func RefreshToken() error {
+	t := currentToken()
+	if t == nil || t.RefreshToken == "" {
+		return nil
+	}
+
+	if _, err := GetChannelId(); err == nil {
+		// Token is valid, force to check again in a hour
+		tokenLock.Lock()
+		if token == t {
+			nt := *t
+			nt.Expiry = time.Now().Add(time.Hour)
+			token = &nt
 		}
+		tokenLock.Unlock()
+		return nil
+	}
+
+	return refreshToken(t)
+}
+
+// refreshToken gets a new user token with the refresh token of t. Only one refresh runs at a time,
+// and it is skipped if t was already replaced by another goroutine
+func refreshToken(t *oauth2.Token) error {
+	refreshLock.Lock()
+	defer refreshLock.Unlock()
+
+	if currentToken() != t {
+		return nil
+	}
+
+	err := func() error {
 		data := url.Values{}
 		data.Add("client_id", config.GetConfig().TwitchOAuthClient)
 		data.Add("client_secret", config.GetConfig().TwitchOAuthSecret)
 		data.Add("grant_type", "refresh_token")
-		data.Add("refresh_token", token.RefreshToken)
+		data.Add("refresh_token", t.RefreshToken)
 
-		client := &http.Client{}
-		r, err := http.NewRequest("POST", "https://id.twitch.tv/oauth2/token", strings.NewReader(data.Encode())) // URL-encoded payload
+		r, err := http.NewRequest("POST", GetEndpoints().OAuthToken, strings.NewReader(data.Encode())) // URL-encoded payload
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 		r.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
 
-		res, err := client.Do(r)
+		res, err := http.DefaultClient.Do(r)
 		if err != nil {
-			discord.Log("ERROR", "", fmt.Sprintf("cannot renew token: %q", err))
-			log.Fatal(err)
+			return err
 		}
 
 		defer res.Body.Close()
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			discord.Log("ERROR", "", fmt.Sprintf("cannot renew token: %q", err))
-			log.Fatal(err)
+			return err
 		}
+		if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+			return &refreshRejected{status: res.StatusCode, body: string(body)}
+		}
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("http error (%d) %s", res.StatusCode, res.Status)
+		}
+
 		var d map[string]interface{}
-		err = json.Unmarshal(body, &d)
-		if err != nil {
-			discord.Log("ERROR", "", fmt.Sprintf("cannot renew token: %q", err))
-			log.Fatal(err)
+		if err := json.Unmarshal(body, &d); err != nil {
+			return err
+		}
+		accessToken, _ := d["access_token"].(string)
+		refreshToken, _ := d["refresh_token"].(string)
+		if accessToken == "" || refreshToken == "" {
+			return fmt.Errorf("no token in the refresh response")
 		}
-		accessToken := d["access_token"].(string)
-		refreshToken := d["refresh_token"].(string)
 
-		token.AccessToken = accessToken
-		token.RefreshToken = refreshToken
-		token.Expiry = time.Now().Add(time.Second * 3600)
-		SaveToken()
+		nt := &oauth2.Token{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    t.TokenType,
+			Expiry:       time.Now().Add(time.Second * 3600),
+		}
+		SetToken(nt)
+		saveToken(nt)
+
+		return nil
+	}()
+
+	if err != nil {
+		log.Error("Cannot renew token: %s", err)
+		discord.Log("ERROR", "", fmt.Sprintf("cannot renew token: %q", err))
 	}
+
+	return err
 }
 
-func GetAccessToken() (*oauth2.Token, error) {
-	if token == nil {
+// SetToken replaces the user token without saving it to config
+func SetToken(t *oauth2.Token) {
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+	token = t
+}
+
+// currentToken returns the user token as it is, without checking or refreshing it
+func currentToken() *oauth2.Token {
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+	return token
+}
+
+// UserToken returns a valid user token, refreshing it if needed. Unlike GetAccessToken it never
+// starts the browser login, so the background goroutines can call it and retry on errors
+func UserToken() (*oauth2.Token, error) {
+	t := currentToken()
+	if t == nil {
 		LoadToken()
+		t = currentToken()
 	}
 
-	if token.Valid() {
-		return token, nil
+	if t.Valid() {
+		return t, nil
 	}
 
-	if !token.Valid() {
-		log.Info("Token not valid. Trying to refresh token...")
-		RefreshToken()
-		if token.Valid() {
-			return token, nil
-		}
+	if t == nil || t.RefreshToken == "" {
+		return nil, fmt.Errorf("no user token")
+	}
+
+	log.Info("Token not valid. Trying to refresh token...")
+	err := refreshToken(t)
+	if t := currentToken(); t.Valid() {
+		return t, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("token not valid after refresh")
+	}
+
+	return nil, err
+}
+
+// GetAccessToken returns a valid user token. If there is none and it cannot be refreshed,
+// it asks the user to login on the browser
+func GetAccessToken() (*oauth2.Token, error) {
+	t, err := UserToken()
+	if err == nil {
+		return t, nil
+	}
+	if _, rejected := err.(*refreshRejected); !rejected && currentToken() != nil {
+		return nil, err
 	}
 
 	reset()
 
-	var err error
 	gob.Register(&oauth2.Token{})
 
 	oauth2Config = &oauth2.Config{
@@ -302,6 +406,7 @@ func GetAccessToken() (*oauth2.Token, error) {
 			"channel_check_subscription",
 			"channel:read:subscriptions",
 			"channel:read:redemptions",
+			"channel:manage:redemptions",
 
 			"channel_commercial",
 			"channel_feed_read",
@@ -319,7 +424,10 @@ func GetAccessToken() (*oauth2.Token, error) {
 			"chat:read",
 			"chat:edit",
 		},
-		Endpoint:    twitch.Endpoint,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  twitch.Endpoint.AuthURL,
+			TokenURL: GetEndpoints().OAuthToken,
+		},
 		RedirectURL: "http://localhost:7001/redirect",
 	}
 
@@ -376,12 +484,13 @@ func GetAccessToken() (*oauth2.Token, error) {
 
 	_ = http.Serve(l, nil)
 
-	if token == nil {
+	t = currentToken()
+	if t == nil {
 		log.Error("Cannot get token")
 		return nil, fmt.Errorf("cannot get token")
 	}
 
-	return token, nil
+	return t, nil
 }
 
 func GetChannel(name string) (channelId, channelName string, err error) {
@@ -448,11 +557,12 @@ func GetChannel(name string) (channelId, channelName string, err error) {
 }
 
 func GetChannelId() (string, error) {
+	token := currentToken()
 	if token == nil {
 		return "", fmt.Errorf("invalid token")
 	}
 
-	fullUrl := "https://api.twitch.tv/helix/users"
+	fullUrl := GetEndpoints().Helix + "/users"
 
 	u, err := url.Parse(fullUrl)
 
@@ -504,7 +614,7 @@ func GetChannelName() (string, error) {
 		return "", err
 	}
 
-	fullUrl := "https://api.twitch.tv/helix/users"
+	fullUrl := GetEndpoints().Helix + "/users"
 
 	u, err := url.Parse(fullUrl)
 
@@ -553,7 +663,25 @@ func GetChannelName() (string, error) {
 }
 
 func Get(path string) (map[string]interface{}, error) {
-	fullUrl := fmt.Sprintf("%s%s", HelixAPI, path)
+	return request("GET", path, nil)
+}
+
+func Post(path string, payload interface{}) (map[string]interface{}, error) {
+	return request("POST", path, payload)
+}
+
+func Patch(path string, payload interface{}) (map[string]interface{}, error) {
+	return request("PATCH", path, payload)
+}
+
+// request calls the Helix API with the user token. The payload, if not nil, is sent as JSON
+func request(method, path string, payload interface{}) (map[string]interface{}, error) {
+	token := currentToken()
+	if token == nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	fullUrl := fmt.Sprintf("%s%s", GetEndpoints().Helix, path)
 
 	u, err := url.Parse(fullUrl)
 
@@ -561,21 +689,29 @@ func Get(path string) (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	req, _ := http.NewRequest("GET", u.String(), nil)
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, _ := http.NewRequest(method, u.String(), body)
 
 	req.Header.Add("Client-ID", config.GetConfig().TwitchOAuthClient)
 	req.Header.Add("Accept", "application/vnd.twitchtv.v5+json")
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http error (%d) %s", res.StatusCode, res.Status)
-	}
-
 	defer res.Body.Close()
 
 	rawData, err := ioutil.ReadAll(res.Body)
@@ -583,8 +719,16 @@ func Get(path string) (map[string]interface{}, error) {
 		return nil, err
 	}
 
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("http error (%d) %s: %s", res.StatusCode, res.Status, string(rawData))
+	}
+
 	obj := map[string]interface{}{}
 
+	if len(rawData) == 0 {
+		return obj, nil
+	}
+
 	err = json.Unmarshal(rawData, &obj)
 
 	return obj, err
@@ -622,6 +766,52 @@ func GetClips(channelId string, since time.Time) ([]string, error) {
 	return urls, nil
 }
 
+// StreamInfo is the live state, title and game of a channel
+type StreamInfo struct {
+	Online    bool
+	StartedAt time.Time
+	Title     string
+	Game      string
+}
+
+// GetStreamInfo returns the stream of the channel if it is live, or the channel title and game if not
+func GetStreamInfo(channelId string) (StreamInfo, error) {
+	var info StreamInfo
+
+	data, err := Get(fmt.Sprintf("/streams?user_id=%s", url.QueryEscape(channelId)))
+	if err != nil {
+		return info, err
+	}
+	streams, ok := data["data"].([]interface{})
+	if !ok {
+		return info, fmt.Errorf("expected data field")
+	}
+	if len(streams) > 0 {
+		stream, _ := streams[0].(map[string]interface{})
+		info.Online = true
+		info.Title, _ = stream["title"].(string)
+		info.Game, _ = stream["game_name"].(string)
+		if startedAt, ok := stream["started_at"].(string); ok {
+			info.StartedAt, _ = time.Parse(time.RFC3339, startedAt)
+		}
+		return info, nil
+	}
+
+	data, err = Get(fmt.Sprintf("/channels?broadcaster_id=%s", url.QueryEscape(channelId)))
+	if err != nil {
+		return info, err
+	}
+	channels, ok := data["data"].([]interface{})
+	if !ok || len(channels) == 0 {
+		return info, fmt.Errorf("channel %s not found", channelId)
+	}
+	channel, _ := channels[0].(map[string]interface{})
+	info.Title, _ = channel["title"].(string)
+	info.Game, _ = channel["game_name"].(string)
+
+	return info, nil
+}
+
 func GetProfilePic(channelId string) (string, error) {
 	data, err := Get(fmt.Sprintf("/users?login=%s", url.PathEscape(channelId)))
 
@@ -649,6 +839,22 @@ func GetProfilePic(channelId string) (string, error) {
 	return logo, nil
 }
 
+// IsFollower returns true if the user id follows the channel.
+// Needs the moderator:read:followers scope
+func IsFollower(channelId, userId string) (bool, error) {
+	data, err := Get(fmt.Sprintf("/channels/followers?broadcaster_id=%s&user_id=%s", url.QueryEscape(channelId), url.QueryEscape(userId)))
+	if err != nil {
+		return false, err
+	}
+
+	followers, ok := data["data"].([]interface{})
+	if !ok {
+		return false, fmt.Errorf("expected data field")
+	}
+
+	return len(followers) > 0, nil
+}
+
 //func GetFollowers(channelId string) ([]Follower, error) {
 //	data, err := Get(fmt.Sprintf("/channels/%s/follows", channelId))
 //
@@ -0,0 +1,50 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HostTargetEventData is a HOSTTARGET message. Target is empty when hosting ends
+type HostTargetEventData struct {
+	Channel   string
+	Target    string
+	Viewers   int
+	timestamp time.Time
+}
+
+func (e *HostTargetEventData) GetType() EventType {
+	return EventHostTarget
+}
+
+func (e *HostTargetEventData) GetData() interface{} {
+	return e
+}
+
+func (e *HostTargetEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"target":    e.Target,
+		"viewers":   e.Viewers,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *HostTargetEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *HostTargetEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeHostTargetEventData(channel, target string, viewers int) ChatEvent {
+	return &HostTargetEventData{
+		Channel:   channel,
+		Target:    target,
+		Viewers:   viewers,
+		timestamp: time.Now(),
+	}
+}
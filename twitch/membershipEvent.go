@@ -0,0 +1,53 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MembershipEventData is a JOIN or PART message of a user in the channel
+type MembershipEventData struct {
+	eventType EventType
+	Channel   string
+	Username  string
+	timestamp time.Time
+}
+
+func (e *MembershipEventData) GetType() EventType {
+	return e.eventType
+}
+
+func (e *MembershipEventData) GetData() interface{} {
+	return e
+}
+
+func (e *MembershipEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"username":  e.Username,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *MembershipEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *MembershipEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeMembershipEventData(joined bool, channel, username string) ChatEvent {
+	eventType := EventJoin
+	if !joined {
+		eventType = EventPart
+	}
+	return &MembershipEventData{
+		eventType: eventType,
+		Channel:   channel,
+		Username:  username,
+		timestamp: time.Now(),
+	}
+}
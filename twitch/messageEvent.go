@@ -3,8 +3,9 @@ package twitch
 import (
 	"encoding/json"
 	"strconv"
-	"strings"
 	"time"
+
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
 )
 
 type SourceType string
@@ -29,19 +30,61 @@ type MessageEventData struct {
 }
 
 func (l *MessageEventData) build() {
-	l.Badges = make(map[string]string)
-	if badges, ok := l.Tags["badges"]; ok {
-		// badges:broadcaster/1,subscriber/0,premium/1
-		b := strings.Split(badges, ",")
-		for _, v := range b {
-			if strings.Contains(v, "/") {
-				v2 := strings.Split(v, "/")
-				l.Badges[v2[0]] = v2[1]
-			} else {
-				l.Badges[v] = ""
-			}
-		}
+	// badges:broadcaster/1,subscriber/0,premium/1
+	l.Badges = parseBadges(l.Tags["badges"])
+}
+
+// UserId returns the twitch id of the user that sent the message
+func (l *MessageEventData) UserId() string {
+	return l.Tags["user-id"]
+}
+
+// MessageId returns the id of the message, used by CLEARMSG and replies
+func (l *MessageEventData) MessageId() string {
+	return l.Tags["id"]
+}
+
+// DisplayName returns the display name of the user or the username if not set
+func (l *MessageEventData) DisplayName() string {
+	if n := l.Tags["display-name"]; n != "" {
+		return n
 	}
+	return l.Username
+}
+
+// Color returns the user chat color in #RRGGBB format. Empty if the user never set one
+func (l *MessageEventData) Color() string {
+	return l.Tags["color"]
+}
+
+// Emotes returns the emotes contained in the message
+func (l *MessageEventData) Emotes() []twitchdata.Emote {
+	return parseEmotes(l.Tags["emotes"])
+}
+
+// Bits returns the amount of bits cheered in the message
+func (l *MessageEventData) Bits() int {
+	return tagInt(l.Tags, "bits")
+}
+
+func (l *MessageEventData) IsVIP() bool {
+	_, ok := l.Badges["vip"]
+	return ok || tagBool(l.Tags, "vip")
+}
+
+func (l *MessageEventData) IsBroadcaster() bool {
+	_, ok := l.Badges["broadcaster"]
+	return ok
+}
+
+// IsFirstMessage returns true if this is the first message of the user in the channel
+func (l *MessageEventData) IsFirstMessage() bool {
+	return tagBool(l.Tags, "first-msg")
+}
+
+// SentAt returns when twitch received the message
+func (l *MessageEventData) SentAt() time.Time {
+	return tagTime(l.Tags, "tmi-sent-ts")
 }
 
 func (l *MessageEventData) IsModerator() bool {
@@ -49,13 +92,26 @@ func (l *MessageEventData) IsModerator() bool {
 	return ok && t == "1"
 }
 
+// IsSubscriber returns true if the user has the subscriber or founder badge, or the subscriber tag.
+// Founders show the founder badge instead of the subscriber one
 func (l *MessageEventData) IsSubscriber() bool {
-	_, ok := l.Badges["subscriber"]
+	_, sub := l.Badges["subscriber"]
+	_, founder := l.Badges["founder"]
 
-	return ok
+	return sub || founder || tagBool(l.Tags, "subscriber")
 }
 
+// SubscriberMonths returns for how many months the user is subscribed, from the badge-info tag.
+// Falls back to the badge version, that is rounded to the badge steps
 func (l *MessageEventData) SubscriberMonths() int {
+	info := parseBadges(l.Tags["badge-info"])
+	for _, badge := range []string{"subscriber", "founder"} {
+		if nv, ok := info[badge]; ok {
+			i, _ := strconv.ParseInt(nv, 10, 32)
+			return int(i)
+		}
+	}
+
 	nv, ok := l.Badges["subscriber"]
 
 	if !ok {
@@ -64,7 +120,28 @@ func (l *MessageEventData) SubscriberMonths() int {
 
 	i, _ := strconv.ParseInt(nv, 10, 32)
 
-	return int(i)
+	// Tier 2 and 3 badges are 2000 and 3000 plus the months
+	return int(i) % 1000
+}
+
+// SubscriberTier returns the sub tier of the user, 1 to 3, or 0 if not subscribed
+func (l *MessageEventData) SubscriberTier() int {
+	nv, ok := l.Badges["subscriber"]
+
+	if !ok {
+		// The founder badge has no tier
+		if l.IsSubscriber() {
+			return 1
+		}
+		return 0
+	}
+
+	i, _ := strconv.ParseInt(nv, 10, 32)
+	if tier := int(i) / 1000; tier >= 2 {
+		return tier
+	}
+
+	return 1
 }
 
 func (l *MessageEventData) GetType() EventType {
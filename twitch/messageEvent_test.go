@@ -0,0 +1,34 @@
+package twitch
+
+import "testing"
+
+func TestMessageSubscriber(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   map[string]string
+		sub    bool
+		tier   int
+		months int
+	}{
+		{"viewer", map[string]string{"badges": "glitchcon2020/1"}, false, 0, 0},
+		{"subscriber", map[string]string{"badges": "subscriber/12", "badge-info": "subscriber/14"}, true, 1, 14},
+		{"tier 3", map[string]string{"badges": "subscriber/3006", "badge-info": "subscriber/7"}, true, 3, 7},
+		{"badge without info", map[string]string{"badges": "subscriber/2024"}, true, 2, 24},
+		{"founder", map[string]string{"badges": "founder/0", "badge-info": "founder/20"}, true, 1, 20},
+		{"subscriber tag", map[string]string{"badges": "moderator/1", "subscriber": "1"}, true, 1, 0},
+		{"not subscriber tag", map[string]string{"subscriber": "0"}, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		m := MakeMessageEventData(SourceTwitch, "bob", "hi", "", tt.tags, nil)
+		if got := m.IsSubscriber(); got != tt.sub {
+			t.Errorf("%s: IsSubscriber() = %v, want %v", tt.name, got, tt.sub)
+		}
+		if got := m.SubscriberTier(); got != tt.tier {
+			t.Errorf("%s: SubscriberTier() = %d, want %d", tt.name, got, tt.tier)
+		}
+		if got := m.SubscriberMonths(); got != tt.months {
+			t.Errorf("%s: SubscriberMonths() = %d, want %d", tt.name, got, tt.months)
+		}
+	}
+}
@@ -6,115 +6,163 @@ import (
 	"github.com/asaskevich/EventBus"
 	"github.com/gorilla/websocket"
 	"github.com/quan-to/slog"
-	"net/url"
-	"strings"
+	"sync"
 	"time"
 )
 
-const twitchWssUrl = "wss://pubsub-edge.twitch.tv"
+const twitchWssUrl = "wss://eventsub.wss.twitch.tv/ws"
+
+const (
+	monitorWelcomeTimeout  = time.Second * 10
+	monitorKeepaliveMargin = time.Second * 5
+	monitorCheckInterval   = time.Second
+	monitorMinBackoff      = time.Second
+	monitorMaxBackoff      = time.Minute * 2
+	monitorSeenMessagesTTL = time.Minute * 10
+)
 
 const (
-	eventBusMessageByNonceTopic    = "twitchws:onMessageByNonce:%s"
-	eventBusResponseMessageTopic   = "twitchws:onResponseMessage"
 	eventBusWebsocketEvents        = "twitchws:onMessage"
 	eventBusWebsocketChannelEvents = "twitchws:onChannelMessage:%s"
 )
 
 var log = slog.Scope("TwitchMonitor")
 
+// Monitor receives channel points, subs and bits from the EventSub websocket transport
 type Monitor struct {
-	running     bool
-	channelname string
-	conn        *websocket.Conn
-	pingTimer   *time.Ticker
-	done        chan struct{}
-	events      chan ChatEvent
-	ev          EventBus.Bus
+	sync.Mutex
+	running       bool
+	channelname   string
+	conn          *websocket.Conn
+	sessionId     string
+	keepalive     time.Duration
+	lastMessage   time.Time
+	checkTimer    *time.Ticker
+	done          chan struct{}
+	reconnect     chan error
+	migrate       chan string
+	events        chan ChatEvent
+	ev            EventBus.Bus
+	subscriptions []string
+	seenMessages  map[string]time.Time
 }
 
 func MakeMonitor(channelName string) *Monitor {
 	return &Monitor{
-		events:      make(chan ChatEvent, 16),
-		channelname: channelName,
-		ev:          EventBus.New(),
+		events:       make(chan ChatEvent, 16),
+		reconnect:    make(chan error, 1),
+		migrate:      make(chan string, 1),
+		channelname:  channelName,
+		ev:           EventBus.New(),
+		seenMessages: map[string]time.Time{},
 	}
 }
 
 func (m *Monitor) Stop() {
-	if m.done != nil {
-		close(m.done)
-		m.done = nil
-	}
-	if m.conn != nil {
-		_ = m.conn.Close()
+	m.Lock()
+	if !m.running {
+		m.Unlock()
+		return
 	}
-	if m.pingTimer != nil {
-		m.pingTimer.Stop()
+	m.running = false
+	close(m.done)
+	m.Unlock()
+
+	m.closeConn()
+	if m.checkTimer != nil {
+		m.checkTimer.Stop()
 	}
 	_ = m.ev.Unsubscribe(eventBusWebsocketEvents, m.onEvent)
 }
 
-func (m *Monitor) parseMessage(data []byte) {
-	var ok bool
+func (m *Monitor) parseMessage(conn *websocket.Conn, welcome chan string, data []byte) {
 	//log.Debug("Received Message: %s", string(data))
-	dataMsg := map[string]interface{}{}
+	msg := eventSubMessage{}
 
-	err := json.Unmarshal(data, &dataMsg)
+	err := json.Unmarshal(data, &msg)
 	if err != nil {
 		log.Error("Error parsing message: %s\nData: %s", err, string(data))
 		return
 	}
 
-	twitchType := dataMsg["type"].(string)
+	m.Lock()
+	if m.conn == conn {
+		m.lastMessage = time.Now()
+	}
+	m.Unlock()
 
-	switch twitchType {
-	case "PONG":
-		//log.Debug("RECEIVED PONG")
-	case "RECONNECT":
-		log.Debug("Received Reconnect")
-		// TODO
-	case "RESPONSE":
-		nonce := ""
-		if nonceI, ok := dataMsg["nonce"]; ok {
-			nonce = nonceI.(string)
-		}
+	if m.isDuplicated(msg.Metadata.MessageId) {
+		log.Debug("Ignoring duplicated message %s", msg.Metadata.MessageId)
+		return
+	}
 
-		if nonce != "" {
-			// If we have a nonce, publish in the messageByNonceTopic
-			m.ev.Publish(fmt.Sprintf(eventBusMessageByNonceTopic, nonce), dataMsg)
+	switch msg.Metadata.MessageType {
+	case eventSubSessionWelcome:
+		if msg.Payload.Session == nil {
+			log.Error("Expected session on welcome message")
+			return
 		}
-
-		m.ev.Publish(eventBusResponseMessageTopic, dataMsg)
-	case "MESSAGE":
-		dataMsg, ok = dataMsg["data"].(map[string]interface{})
-		if !ok {
-			log.Error("Expected data field to be json")
+		log.Debug("Received welcome for session %s", msg.Payload.Session.Id)
+		m.Lock()
+		m.keepalive = time.Duration(msg.Payload.Session.KeepaliveTimeoutSeconds) * time.Second
+		m.Unlock()
+		welcome <- msg.Payload.Session.Id
+	case eventSubSessionKeepalive:
+		//log.Debug("RECEIVED KEEPALIVE")
+	case eventSubSessionReconnect:
+		if msg.Payload.Session == nil {
+			log.Error("Expected session on reconnect message")
 			return
 		}
-
-		m.parseTwitchMessage(dataMsg)
+		log.Info("Received session reconnect to %s", msg.Payload.Session.ReconnectUrl)
+		select {
+		case m.migrate <- msg.Payload.Session.ReconnectUrl:
+		default:
+		}
+	case eventSubRevocation:
+		if msg.Payload.Subscription != nil {
+			log.Warn("Subscription %s revoked: %s", msg.Payload.Subscription.Type, msg.Payload.Subscription.Status)
+			m.removeSubscription(msg.Payload.Subscription.Type)
+		}
+	case eventSubNotification:
+		m.parseNotification(msg)
+	default:
+		log.Warn("Unknown message type: %s", msg.Metadata.MessageType)
 	}
 }
 
-func (m *Monitor) parseTwitchMessage(data map[string]interface{}) {
-	if _, ok := data["topic"]; !ok {
-		log.Error("Expected topic on twitch message")
-		return
+// isDuplicated returns true if the message was already received. Twitch might resend notifications
+func (m *Monitor) isDuplicated(messageId string) bool {
+	if messageId == "" {
+		return false
 	}
 
-	if _, ok := data["message"]; !ok {
-		log.Error("Expected message on twitch message")
-		return
+	m.Lock()
+	defer m.Unlock()
+
+	for k, v := range m.seenMessages {
+		if time.Since(v) > monitorSeenMessagesTTL {
+			delete(m.seenMessages, k)
+		}
 	}
 
-	topic := data["topic"].(string)
-	msg := data["message"].(string)
+	if _, ok := m.seenMessages[messageId]; ok {
+		return true
+	}
 
-	channelId, event := channelIdAndEventFromTopic(topic)
+	m.seenMessages[messageId] = time.Now()
 
-	if event == "" {
-		log.Warn("Unknown event: %s", topic)
-		return
+	return false
+}
+
+func (m *Monitor) parseNotification(msg eventSubMessage) {
+	event := msg.Metadata.SubscriptionType
+	channelId := m.channelname
+
+	if msg.Payload.Subscription != nil {
+		if id, ok := msg.Payload.Subscription.Condition["broadcaster_user_id"].(string); ok {
+			channelId = id
+		}
 	}
 
 	log.Debug("Received event %s for channel %s", event, channelId)
@@ -123,12 +171,16 @@ func (m *Monitor) parseTwitchMessage(data map[string]interface{}) {
 	var err error
 
 	switch event {
-	case topicChannelBitsEventv2:
-		twitchEvent, err = makeBitsEvent(channelId, msg)
-	case topicChannelSubscribe:
-		twitchEvent, err = makeSubscribeEvent(channelId, msg)
-	case topicChannelPoints:
-		twitchEvent, err = makePointsEvent(channelId, msg)
+	case eventSubChannelCheer:
+		twitchEvent, err = makeBitsEvent(channelId, msg.Metadata.MessageId, msg.Payload.Event)
+	case eventSubChannelSubscribe:
+		twitchEvent, err = makeSubscribeEvent(channelId, msg.Payload.Event)
+	case eventSubChannelSubMessage:
+		twitchEvent, err = makeSubscriptionMessageEvent(channelId, msg.Payload.Event)
+	case eventSubChannelSubGift:
+		twitchEvent, err = makeSubscriptionGiftEvent(channelId, msg.Payload.Event)
+	case eventSubChannelPointsRedemption:
+		twitchEvent, err = makeRewardRedeemed(channelId, msg.Payload.Event)
 	default:
 		err = fmt.Errorf("unknown event: %s", event)
 	}
@@ -138,6 +190,11 @@ func (m *Monitor) parseTwitchMessage(data map[string]interface{}) {
 		return
 	}
 
+	if twitchEvent == nil {
+		// Event intentionally ignored
+		return
+	}
+
 	// Publish to main event bus
 	m.ev.Publish(eventBusWebsocketEvents, twitchEvent)
 
@@ -145,155 +202,344 @@ func (m *Monitor) parseTwitchMessage(data map[string]interface{}) {
 	m.ev.Publish(fmt.Sprintf(eventBusWebsocketChannelEvents, channelId), twitchEvent)
 }
 
-func (m *Monitor) sendPing() {
-	msg := map[string]string{
-		"type": "PING",
-	}
+// checkKeepalive requests a reconnection if nothing was received within the keepalive timeout
+func (m *Monitor) checkKeepalive() {
+	m.Lock()
+	keepalive := m.keepalive
+	since := time.Since(m.lastMessage)
+	connected := m.conn != nil
+	m.Unlock()
 
-	msgBytes, _ := json.Marshal(msg)
-	err := m.conn.WriteMessage(websocket.TextMessage, msgBytes)
-	if err != nil {
-		log.Error("Error sending PING: %s", err)
+	if connected && keepalive > 0 && since > keepalive+monitorKeepaliveMargin {
+		m.requestReconnect(fmt.Errorf("no message received in %s", since))
 	}
 }
 
 func (m *Monitor) register() {
-	token, err := GetAccessToken()
-	if err != nil {
-		log.Error("Error getting token: %s", err)
+	topicList := []string{
+		eventSubChannelPointsRedemption,
+		eventSubChannelSubscribe,
+		eventSubChannelSubMessage,
+		eventSubChannelSubGift,
+		eventSubChannelCheer,
 	}
 
-	topicList := make([]string, 0)
-
-	topicList = append(topicList, topicChannelPoints+"."+m.channelname)
-	topicList = append(topicList, topicChannelSubscribe+"."+m.channelname)
-	topicList = append(topicList, topicChannelBitsEventv2+"."+m.channelname)
-
-	err = m.Register(topicList, token.AccessToken)
+	err := m.Register(topicList)
 
 	if err != nil {
 		log.Error("Error registering to topics: %s", err)
 	}
 }
 
-func (m *Monitor) loop() {
+func (m *Monitor) loop(done chan struct{}) {
 	log.Debug("Starting Loop")
 	run := true
 	for run {
 		select {
-		case <-m.done:
+		case <-done:
 			log.Info("Received done. Closing connections")
 			run = false
-		case <-m.pingTimer.C:
-			//log.Debug("Sending PING")
-			m.sendPing()
+		case <-m.checkTimer.C:
+			m.checkKeepalive()
+		case reconnectUrl := <-m.migrate:
+			m.doMigrate(reconnectUrl)
+		case err := <-m.reconnect:
+			m.doReconnect(done, err)
 		}
 
 	}
 	log.Debug("Closing loop")
 }
 
-func (m *Monitor) messageLoop() {
+func (m *Monitor) messageLoop(conn *websocket.Conn, welcome chan string) {
 	log.Debug("Starting message loop")
 	for {
-		_, msg, err := m.conn.ReadMessage()
-		if err != nil && !strings.Contains(err.Error(), "timeout") {
-			log.Error("Error receiving message: %s", err)
-			if m.done != nil {
-				close(m.done)
-				m.done = nil
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			m.Lock()
+			current := m.conn == conn && m.running
+			m.Unlock()
+
+			// Errors from replaced or closed connections are expected
+			if current {
+				log.Error("Error receiving message: %s", err)
+				m.requestReconnect(err)
 			}
 			break
 		}
 
-		m.parseMessage(msg)
+		m.parseMessage(conn, welcome, msg)
 	}
 	log.Debug("Closing message loop")
 }
 
-func (m *Monitor) onEvent(data ChatEvent) {
-	// Forward received websocket event to event channel
-	m.events <- data
+// requestReconnect asks the main loop to reconnect. Only one request is kept pending
+func (m *Monitor) requestReconnect(reason error) {
+	select {
+	case m.reconnect <- reason:
+	default:
+	}
 }
 
-func (m *Monitor) Start() error {
-	u, err := url.Parse(twitchWssUrl)
+// doMigrate handles a session_reconnect. The subscriptions are kept by twitch,
+// so the old connection is only closed after the new one is welcomed
+func (m *Monitor) doMigrate(reconnectUrl string) {
+	m.Lock()
+	old := m.conn
+	m.Unlock()
+
+	conn, sessionId, err := m.dial(reconnectUrl)
 	if err != nil {
-		return err
+		log.Error("Error migrating session: %s", err)
+		m.requestReconnect(err)
+		return
+	}
+
+	m.Lock()
+	m.conn = conn
+	m.sessionId = sessionId
+	m.lastMessage = time.Now()
+	m.Unlock()
+
+	if old != nil {
+		_ = old.Close()
+	}
+
+	log.Info("Migrated to session %s", sessionId)
+}
+
+// doReconnect drops the current connection and tries to connect again
+// with exponential backoff until it succeeds or the monitor is stopped.
+// A new session has no subscriptions, so they're created again
+func (m *Monitor) doReconnect(done chan struct{}, reason error) {
+	log.Warn("Connection lost: %s", reason)
+	m.closeConn()
+	if !m.emit(done, MakeDisconnectedEvent(ConnectionSourceEventSub, reason)) {
+		return
+	}
+
+	lostAt := time.Now()
+	b := makeBackoff(monitorMinBackoff, monitorMaxBackoff)
+
+	for {
+		delay := b.Next()
+		log.Info("Reconnecting in %s (attempt %d)", delay, b.Attempt())
+		if !m.emit(done, MakeReconnectingEvent(ConnectionSourceEventSub, b.Attempt(), delay, reason)) {
+			return
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-done:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		err := m.connect()
+		if err != nil {
+			reason = err
+			continue
+		}
+
+		err = m.resubscribe()
+		if err != nil {
+			log.Error("Error registering to topics: %s", err)
+			reason = err
+			m.closeConn()
+			continue
+		}
+
+		// Discard requests made by connections that failed during the reconnection
+		select {
+		case <-m.reconnect:
+		default:
+		}
+
+		log.Info("Reconnected after %s", time.Since(lostAt))
+		m.emit(done, MakeReconnectedEvent(ConnectionSourceEventSub, b.Attempt(), time.Since(lostAt)))
+		return
 	}
+}
 
-	log.Info("Connecting to %s", u.String())
+// emit sends a connection event, unless the monitor is stopped while nobody reads them
+func (m *Monitor) emit(done chan struct{}, ev ChatEvent) bool {
+	select {
+	case m.events <- ev:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// dial connects to the websocket, starts reading from it and waits for the session welcome
+func (m *Monitor) dial(wsUrl string) (*websocket.Conn, string, error) {
+	log.Info("Connecting to %s", wsUrl)
 
-	m.conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
 	if err != nil {
 		log.Error("Error connecting: %s", err)
-		return err
+		return nil, "", err
 	}
 
-	m.pingTimer = time.NewTicker(time.Second * 5)
+	welcome := make(chan string, 1)
+	go m.messageLoop(conn, welcome)
 
-	m.done = make(chan struct{})
+	t := time.NewTimer(monitorWelcomeTimeout)
+	defer t.Stop()
 
-	m.ev.SubscribeAsync(eventBusWebsocketEvents, m.onEvent, false)
+	select {
+	case sessionId := <-welcome:
+		return conn, sessionId, nil
+	case <-t.C:
+		_ = conn.Close()
+		return nil, "", fmt.Errorf("timeout waiting session welcome")
+	}
+}
 
-	go m.loop()
-	go m.messageLoop()
+// connect creates a new session
+func (m *Monitor) connect() error {
+	conn, sessionId, err := m.dial(GetEndpoints().EventSubWebsocket)
+	if err != nil {
+		return err
+	}
 
-	m.register()
+	m.Lock()
+	m.conn = conn
+	m.sessionId = sessionId
+	m.lastMessage = time.Now()
+	m.Unlock()
 
 	return nil
 }
 
-func (m *Monitor) Register(events []string, token string) error {
-	msgId, msg := makeMessage("LISTEN", map[string]interface{}{
-		"topics":     events,
-		"auth_token": token,
-	})
+func (m *Monitor) closeConn() {
+	m.Lock()
+	conn := m.conn
+	m.conn = nil
+	m.sessionId = ""
+	m.Unlock()
 
-	data, _ := json.Marshal(msg)
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
 
-	err := m.conn.WriteMessage(websocket.TextMessage, data)
+func (m *Monitor) onEvent(data ChatEvent) {
+	// Forward received websocket event to event channel
+	m.events <- data
+}
+
+func (m *Monitor) Start() error {
+	m.Lock()
+	m.running = true
+	m.done = make(chan struct{})
+	m.Unlock()
+
+	err := m.connect()
 	if err != nil {
+		m.Lock()
+		m.running = false
+		m.Unlock()
 		return err
 	}
 
-	result, err := m.waitForResult(msgId, time.Second*5)
+	m.checkTimer = time.NewTicker(monitorCheckInterval)
 
-	if err != nil { // Timeout
-		return err
-	}
+	m.ev.SubscribeAsync(eventBusWebsocketEvents, m.onEvent, false)
 
-	if twerror, ok := result["error"]; ok && twerror != "" {
-		return fmt.Errorf("twitch error: %s", twerror)
-	}
+	go m.loop(m.done)
 
-	// If no error provided by twitch, assume LISTEN OK
+	m.register()
 
 	return nil
 }
 
-func (m *Monitor) EventChannel() chan ChatEvent {
-	return m.events
+// Register subscribes the current session to the specified EventSub types.
+// The types are remembered and subscribed again after a reconnection
+func (m *Monitor) Register(subscriptionTypes []string) error {
+	m.Lock()
+	for _, e := range subscriptionTypes {
+		found := false
+		for _, t := range m.subscriptions {
+			if t == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.subscriptions = append(m.subscriptions, e)
+		}
+	}
+	m.Unlock()
+
+	return m.subscribe(subscriptionTypes)
 }
 
-func (m *Monitor) waitForResult(msgId string, timeout time.Duration) (data map[string]interface{}, err error) {
-	// Create a buffered channel to receive the result
-	res := make(chan map[string]interface{}, 1)
+// resubscribe subscribes a new session to all registered types
+func (m *Monitor) resubscribe() error {
+	m.Lock()
+	subscriptions := append([]string{}, m.subscriptions...)
+	m.Unlock()
 
-	// Create the callback async and once
-	m.ev.SubscribeOnceAsync(fmt.Sprintf(eventBusMessageByNonceTopic, msgId), func(data map[string]interface{}) {
-		res <- data
-	})
+	return m.subscribe(subscriptions)
+}
 
-	timeoutTimer := time.NewTimer(timeout)
-	defer timeoutTimer.Stop()
+func (m *Monitor) removeSubscription(subscriptionType string) {
+	m.Lock()
+	defer m.Unlock()
 
-	select {
-	case <-timeoutTimer.C:
-		// Timeout
-		return nil, fmt.Errorf("timeout")
-	case data = <-res:
-		// Got result
-		return data, nil
+	for i, v := range m.subscriptions {
+		if v == subscriptionType {
+			m.subscriptions = append(m.subscriptions[:i], m.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *Monitor) subscribe(subscriptionTypes []string) error {
+	m.Lock()
+	sessionId := m.sessionId
+	m.Unlock()
+
+	if sessionId == "" {
+		return fmt.Errorf("not connected")
 	}
+
+	// Makes sure the token is valid before creating the subscriptions.
+	// UserToken never asks for a browser login, so a failure is retried by the reconnect loop
+	_, err := UserToken()
+	if err != nil {
+		return err
+	}
+
+	for _, subType := range subscriptionTypes {
+		version, ok := eventSubVersions[subType]
+		if !ok {
+			return fmt.Errorf("unknown subscription type %s", subType)
+		}
+
+		log.Debug("Subscribing to %s for %s", subType, m.channelname)
+		_, err := Post("/eventsub/subscriptions", map[string]interface{}{
+			"type":    subType,
+			"version": version,
+			"condition": map[string]interface{}{
+				"broadcaster_user_id": m.channelname,
+			},
+			"transport": map[string]interface{}{
+				"method":     "websocket",
+				"session_id": sessionId,
+			},
+		})
+
+		if err != nil {
+			return fmt.Errorf("error subscribing to %s: %s", subType, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Monitor) EventChannel() chan ChatEvent {
+	return m.events
 }
@@ -0,0 +1,55 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NoticeEventData is a NOTICE message from the server, like slow mode or ban warnings
+type NoticeEventData struct {
+	Channel   string
+	Message   string
+	Tags      map[string]string
+	timestamp time.Time
+}
+
+func (e *NoticeEventData) GetType() EventType {
+	return EventNotice
+}
+
+func (e *NoticeEventData) GetData() interface{} {
+	return e
+}
+
+// MsgId returns the notice identifier, like msg_banned or slow_on
+func (e *NoticeEventData) MsgId() string {
+	return e.Tags["msg-id"]
+}
+
+func (e *NoticeEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"message":   e.Message,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *NoticeEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *NoticeEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeNoticeEventData(channel, message string, tags map[string]string) ChatEvent {
+	return &NoticeEventData{
+		Channel:   channel,
+		Message:   message,
+		Tags:      tags,
+		timestamp: time.Now(),
+	}
+}
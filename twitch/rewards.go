@@ -0,0 +1,125 @@
+package twitch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Redemption status of the Helix custom reward redemptions
+const (
+	RedemptionUnfulfilled = "UNFULFILLED"
+	RedemptionFulfilled   = "FULFILLED"
+	RedemptionCanceled    = "CANCELED"
+)
+
+// UpdateRedemptionStatus sets the status of an unfulfilled redemption. Canceling refunds the points.
+// Twitch only accepts it for rewards created by the same client id
+func UpdateRedemptionStatus(broadcasterId, rewardId, redemptionId, status string) error {
+	path := fmt.Sprintf("/channel_points/custom_rewards/redemptions?broadcaster_id=%s&reward_id=%s&id=%s",
+		url.QueryEscape(broadcasterId), url.QueryEscape(rewardId), url.QueryEscape(redemptionId))
+
+	_, err := Patch(path, map[string]string{"status": status})
+	return err
+}
+
+// FulfillRedemption marks the redemption as done
+func FulfillRedemption(broadcasterId, rewardId, redemptionId string) error {
+	return UpdateRedemptionStatus(broadcasterId, rewardId, redemptionId, RedemptionFulfilled)
+}
+
+// CancelRedemption refunds the points of the redemption
+func CancelRedemption(broadcasterId, rewardId, redemptionId string) error {
+	return UpdateRedemptionStatus(broadcasterId, rewardId, redemptionId, RedemptionCanceled)
+}
+
+// CustomReward is a channel point reward of the Helix Custom Rewards API
+type CustomReward struct {
+	Id                    string `json:"id"`
+	Title                 string `json:"title"`
+	Prompt                string `json:"prompt"`
+	Cost                  int    `json:"cost"`
+	BackgroundColor       string `json:"background_color"`
+	IsEnabled             bool   `json:"is_enabled"`
+	IsPaused              bool   `json:"is_paused"`
+	IsUserInputRequired   bool   `json:"is_user_input_required"`
+	GlobalCooldownSetting struct {
+		IsEnabled             bool `json:"is_enabled"`
+		GlobalCooldownSeconds int  `json:"global_cooldown_seconds"`
+	} `json:"global_cooldown_setting"`
+}
+
+// CustomRewardSettings are the fields sent when creating or updating a reward
+type CustomRewardSettings struct {
+	Title                   string `json:"title"`
+	Cost                    int    `json:"cost"`
+	Prompt                  string `json:"prompt"`
+	IsEnabled               bool   `json:"is_enabled"`
+	BackgroundColor         string `json:"background_color,omitempty"`
+	IsUserInputRequired     bool   `json:"is_user_input_required"`
+	IsGlobalCooldownEnabled bool   `json:"is_global_cooldown_enabled"`
+	GlobalCooldownSeconds   int    `json:"global_cooldown_seconds,omitempty"`
+}
+
+// decodeRewards reads the rewards of the data field of a Helix response
+func decodeRewards(obj map[string]interface{}) ([]CustomReward, error) {
+	raw, err := json.Marshal(obj["data"])
+	if err != nil {
+		return nil, err
+	}
+
+	var rewards []CustomReward
+	if err := json.Unmarshal(raw, &rewards); err != nil {
+		return nil, fmt.Errorf("unexpected rewards data: %s", err)
+	}
+
+	return rewards, nil
+}
+
+// GetCustomRewards returns the custom rewards of the channel. onlyManageable returns only the ones
+// created by this client id, the only ones it can update
+func GetCustomRewards(broadcasterId string, onlyManageable bool) ([]CustomReward, error) {
+	obj, err := Get(fmt.Sprintf("/channel_points/custom_rewards?broadcaster_id=%s&only_manageable_rewards=%t",
+		url.QueryEscape(broadcasterId), onlyManageable))
+	if err != nil {
+		return nil, err
+	}
+	return decodeRewards(obj)
+}
+
+// CreateCustomReward creates a reward on the channel
+func CreateCustomReward(broadcasterId string, settings CustomRewardSettings) (CustomReward, error) {
+	obj, err := Post(fmt.Sprintf("/channel_points/custom_rewards?broadcaster_id=%s", url.QueryEscape(broadcasterId)), settings)
+	if err != nil {
+		return CustomReward{}, err
+	}
+	return firstReward(obj)
+}
+
+// UpdateCustomReward replaces the settings of a reward created by this client id
+func UpdateCustomReward(broadcasterId, rewardId string, settings CustomRewardSettings) (CustomReward, error) {
+	obj, err := Patch(fmt.Sprintf("/channel_points/custom_rewards?broadcaster_id=%s&id=%s",
+		url.QueryEscape(broadcasterId), url.QueryEscape(rewardId)), settings)
+	if err != nil {
+		return CustomReward{}, err
+	}
+	return firstReward(obj)
+}
+
+// SetCustomRewardPaused pauses or unpauses the redemptions of a reward created by this client id
+func SetCustomRewardPaused(broadcasterId, rewardId string, paused bool) error {
+	_, err := Patch(fmt.Sprintf("/channel_points/custom_rewards?broadcaster_id=%s&id=%s",
+		url.QueryEscape(broadcasterId), url.QueryEscape(rewardId)), map[string]bool{"is_paused": paused})
+	return err
+}
+
+func firstReward(obj map[string]interface{}) (CustomReward, error) {
+	rewards, err := decodeRewards(obj)
+	if err != nil {
+		return CustomReward{}, err
+	}
+	if len(rewards) == 0 {
+		return CustomReward{}, fmt.Errorf("no reward in the response")
+	}
+	return rewards[0], nil
+}
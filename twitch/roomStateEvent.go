@@ -0,0 +1,81 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RoomStateEventData is a ROOMSTATE message. When sent after joining it contains
+// every setting, later updates only contain the settings that changed
+type RoomStateEventData struct {
+	Channel   string
+	Tags      map[string]string
+	timestamp time.Time
+}
+
+func (e *RoomStateEventData) GetType() EventType {
+	return EventRoomState
+}
+
+func (e *RoomStateEventData) GetData() interface{} {
+	return e
+}
+
+func (e *RoomStateEventData) RoomId() string {
+	return e.Tags["room-id"]
+}
+
+func (e *RoomStateEventData) boolSetting(key string) (value, ok bool) {
+	_, ok = e.Tags[key]
+	return tagBool(e.Tags, key), ok
+}
+
+func (e *RoomStateEventData) EmoteOnly() (value, ok bool) {
+	return e.boolSetting("emote-only")
+}
+
+func (e *RoomStateEventData) SubsOnly() (value, ok bool) {
+	return e.boolSetting("subs-only")
+}
+
+func (e *RoomStateEventData) R9K() (value, ok bool) {
+	return e.boolSetting("r9k")
+}
+
+// FollowersOnly returns how long a user must follow before chatting. -1 means disabled
+func (e *RoomStateEventData) FollowersOnly() (minutes int, ok bool) {
+	_, ok = e.Tags["followers-only"]
+	return tagInt(e.Tags, "followers-only"), ok
+}
+
+// Slow returns how long users must wait between messages
+func (e *RoomStateEventData) Slow() (value time.Duration, ok bool) {
+	_, ok = e.Tags["slow"]
+	return time.Duration(tagInt(e.Tags, "slow")) * time.Second, ok
+}
+
+func (e *RoomStateEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *RoomStateEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *RoomStateEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeRoomStateEventData(channel string, tags map[string]string) ChatEvent {
+	return &RoomStateEventData{
+		Channel:   channel,
+		Tags:      tags,
+		timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,65 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SendQueueEventData reports a chat message that was dropped or delayed by the send queue
+type SendQueueEventData struct {
+	eventType EventType
+	Message   string
+	Priority  MessagePriority
+	Delay     time.Duration
+	Reason    string
+	timestamp time.Time
+}
+
+func (e *SendQueueEventData) GetType() EventType {
+	return e.eventType
+}
+
+func (e *SendQueueEventData) GetData() interface{} {
+	return e
+}
+
+func (e *SendQueueEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"message":   e.Message,
+		"priority":  e.Priority.String(),
+		"delay":     e.Delay.String(),
+		"reason":    e.Reason,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *SendQueueEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *SendQueueEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeMessageDroppedEvent(message string, priority MessagePriority, delay time.Duration, reason string) ChatEvent {
+	return &SendQueueEventData{
+		eventType: EventMessageDropped,
+		Message:   message,
+		Priority:  priority,
+		Delay:     delay,
+		Reason:    reason,
+		timestamp: time.Now(),
+	}
+}
+
+func MakeMessageDelayedEvent(message string, priority MessagePriority, delay time.Duration) ChatEvent {
+	return &SendQueueEventData{
+		eventType: EventMessageDelayed,
+		Message:   message,
+		Priority:  priority,
+		Delay:     delay,
+		timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,236 @@
+package twitch
+
+import (
+	"sync"
+	"time"
+)
+
+// MessagePriority defines which queued chat messages are sent first
+type MessagePriority int
+
+const (
+	// PriorityLow is for messages that can be dropped, like AI chatter
+	PriorityLow MessagePriority = iota
+	// PriorityNormal is for command replies
+	PriorityNormal
+	// PriorityHigh is for event thanks (follows, subs, bits)
+	PriorityHigh
+)
+
+var priorityNames = map[MessagePriority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+func (p MessagePriority) String() string {
+	return priorityNames[p]
+}
+
+const (
+	// Twitch allows 20 messages per 30 seconds for regular accounts
+	// and 100 messages per 30 seconds in channels where the bot is moderator
+	chatRateWindow     = time.Second * 30
+	chatRegularLimit   = 20
+	chatModeratorLimit = 100
+
+	sendQueueSize        = 64
+	sendDelayedThreshold = time.Second * 5
+	sendRetryInterval    = time.Second
+)
+
+// maxQueueTime is how long a message can wait in queue before being dropped
+var maxQueueTime = map[MessagePriority]time.Duration{
+	PriorityLow:    time.Second * 30,
+	PriorityNormal: time.Minute,
+	PriorityHigh:   time.Minute * 5,
+}
+
+// rateWindow allows limit messages in any rolling window, keeping the time of each send.
+// A token bucket that starts full would allow twice the limit in the first window
+type rateWindow struct {
+	limit  int
+	window time.Duration
+	sent   []time.Time
+}
+
+func makeRateWindow(limit int, window time.Duration) *rateWindow {
+	return &rateWindow{
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (w *rateWindow) setLimit(limit int) {
+	w.limit = limit
+}
+
+// expire forgets the sends that left the window
+func (w *rateWindow) expire(now time.Time) {
+	i := 0
+	for i < len(w.sent) && now.Sub(w.sent[i]) >= w.window {
+		i++
+	}
+	w.sent = w.sent[i:]
+}
+
+// take counts one send. If the window is full returns how long to wait until the oldest send leaves it
+func (w *rateWindow) take(now time.Time) (ok bool, wait time.Duration) {
+	w.expire(now)
+	if len(w.sent) < w.limit {
+		w.sent = append(w.sent, now)
+		return true, 0
+	}
+
+	// The limit may have been lowered, so wait for enough sends to leave the window
+	return false, w.sent[len(w.sent)-w.limit].Add(w.window).Sub(now)
+}
+
+// refund forgets the last send, used when the message could not be sent
+func (w *rateWindow) refund() {
+	if len(w.sent) > 0 {
+		w.sent = w.sent[:len(w.sent)-1]
+	}
+}
+
+type queuedMessage struct {
+	text     string
+	priority MessagePriority
+	queuedAt time.Time
+}
+
+// sendQueue holds outgoing chat messages ordered by priority and then by arrival
+type sendQueue struct {
+	sync.Mutex
+	limiter   *rateWindow
+	items     []*queuedMessage
+	moderator bool
+	notify    chan struct{}
+}
+
+func makeSendQueue() *sendQueue {
+	return &sendQueue{
+		limiter: makeRateWindow(chatRegularLimit, chatRateWindow),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func (q *sendQueue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// setModerator switches between moderator and regular rate limits
+func (q *sendQueue) setModerator(moderator bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.moderator == moderator {
+		return
+	}
+
+	q.moderator = moderator
+	if moderator {
+		q.limiter.setLimit(chatModeratorLimit)
+	} else {
+		q.limiter.setLimit(chatRegularLimit)
+	}
+	log.Info("Chat rate limit set to %d messages per %s", q.limiter.limit, chatRateWindow)
+}
+
+// add queues a message. If the queue is full the oldest message with the lowest priority is dropped
+// and returned, which might be the message being added
+func (q *sendQueue) add(msg *queuedMessage) (dropped *queuedMessage) {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.items) >= sendQueueSize {
+		lowest := -1
+		for i, v := range q.items {
+			if lowest == -1 || v.priority < q.items[lowest].priority {
+				lowest = i
+			}
+		}
+
+		if q.items[lowest].priority > msg.priority {
+			return msg
+		}
+
+		dropped = q.items[lowest]
+		q.items = append(q.items[:lowest], q.items[lowest+1:]...)
+	}
+
+	// Insert after every message with same or higher priority
+	pos := len(q.items)
+	for i, v := range q.items {
+		if v.priority < msg.priority {
+			pos = i
+			break
+		}
+	}
+
+	q.items = append(q.items, nil)
+	copy(q.items[pos+1:], q.items[pos:])
+	q.items[pos] = msg
+
+	q.wake()
+
+	return dropped
+}
+
+// expire removes and returns every message that waited more than its priority allows
+func (q *sendQueue) expire(now time.Time) (expired []*queuedMessage) {
+	q.Lock()
+	defer q.Unlock()
+
+	items := q.items[:0]
+	for _, v := range q.items {
+		if now.Sub(v.queuedAt) > maxQueueTime[v.priority] {
+			expired = append(expired, v)
+		} else {
+			items = append(items, v)
+		}
+	}
+	q.items = items
+
+	return expired
+}
+
+// next returns the next message to be sent if the rate limit allows it.
+// Otherwise returns how long to wait before trying again
+func (q *sendQueue) next(now time.Time) (msg *queuedMessage, wait time.Duration) {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, 0
+	}
+
+	ok, wait := q.limiter.take(now)
+	if !ok {
+		return nil, wait
+	}
+
+	msg = q.items[0]
+	q.items = q.items[1:]
+
+	return msg, 0
+}
+
+// requeue puts back a message that could not be sent and refunds its send
+func (q *sendQueue) requeue(msg *queuedMessage) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.items = append([]*queuedMessage{msg}, q.items...)
+	q.limiter.refund()
+}
+
+func (q *sendQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+
+	return len(q.items)
+}
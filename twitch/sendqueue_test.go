@@ -0,0 +1,92 @@
+package twitch
+
+import (
+	"testing"
+	"time"
+)
+
+// sendAll drains the queue as fast as the limiter allows and returns the send times
+func sendAll(q *sendQueue, start time.Time, count int) []time.Time {
+	for i := 0; i < count; i++ {
+		q.add(&queuedMessage{text: "hi", priority: PriorityNormal, queuedAt: start})
+	}
+
+	var sent []time.Time
+	now := start
+	for q.Len() > 0 {
+		msg, wait := q.next(now)
+		if msg == nil {
+			now = now.Add(wait)
+			continue
+		}
+		sent = append(sent, now)
+	}
+	return sent
+}
+
+func TestSendQueueRollingWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := makeSendQueue()
+	sent := sendAll(q, start, 60)
+
+	if len(sent) != 60 {
+		t.Fatalf("sent %d messages, want 60", len(sent))
+	}
+	for i := range sent {
+		n := 0
+		for _, s := range sent[i:] {
+			if s.Sub(sent[i]) < chatRateWindow {
+				n++
+			}
+		}
+		if n > chatRegularLimit {
+			t.Fatalf("%d messages in the 30s window starting at %s, want at most %d", n, sent[i].Sub(start), chatRegularLimit)
+		}
+	}
+
+	// The first burst goes out at once, the next one when it leaves the window
+	if !sent[chatRegularLimit-1].Equal(start) {
+		t.Errorf("message %d sent at %s, want at once", chatRegularLimit, sent[chatRegularLimit-1].Sub(start))
+	}
+	if got := sent[chatRegularLimit].Sub(start); got != chatRateWindow {
+		t.Errorf("message %d sent at %s, want %s", chatRegularLimit+1, got, chatRateWindow)
+	}
+}
+
+func TestSendQueueRequeueRefunds(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := makeSendQueue()
+	for i := 0; i < chatRegularLimit+1; i++ {
+		q.add(&queuedMessage{text: "hi", priority: PriorityNormal, queuedAt: start})
+	}
+
+	msg, _ := q.next(start)
+	q.requeue(msg)
+	for i := 0; i < chatRegularLimit; i++ {
+		if msg, _ := q.next(start); msg == nil {
+			t.Fatalf("message %d was limited after a requeue", i+1)
+		}
+	}
+	if msg, wait := q.next(start); msg != nil || wait != chatRateWindow {
+		t.Errorf("got %v and wait %s after the limit, want nil and %s", msg, wait, chatRateWindow)
+	}
+}
+
+func TestSendQueueModeratorLimit(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := makeSendQueue()
+	q.setModerator(true)
+	for i := 0; i < chatModeratorLimit; i++ {
+		q.add(&queuedMessage{text: "hi", priority: PriorityNormal, queuedAt: start})
+		if msg, _ := q.next(start); msg == nil {
+			t.Fatalf("message %d was limited as moderator", i+1)
+		}
+	}
+
+	// Going back to regular waits until the window has less than the regular limit
+	q.setModerator(false)
+	q.add(&queuedMessage{text: "hi", priority: PriorityNormal, queuedAt: start})
+	if _, wait := q.next(start); wait != chatRateWindow {
+		t.Errorf("wait %s after losing moderator, want %s", wait, chatRateWindow)
+	}
+}
@@ -0,0 +1,98 @@
+package twitch
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+	"gopkg.in/irc.v3"
+)
+
+// tagsFromMessage returns the unescaped IRCv3 tags of the message
+func tagsFromMessage(m *irc.Message) map[string]string {
+	tags := map[string]string{}
+
+	for k, v := range m.Tags {
+		tags[k] = string(v)
+	}
+
+	return tags
+}
+
+// parseBadges parses a badges tag like broadcaster/1,subscriber/0,premium/1
+func parseBadges(badges string) map[string]string {
+	result := make(map[string]string)
+	if badges == "" {
+		return result
+	}
+
+	b := strings.Split(badges, ",")
+	for _, v := range b {
+		if strings.Contains(v, "/") {
+			v2 := strings.SplitN(v, "/", 2)
+			result[v2[0]] = v2[1]
+		} else {
+			result[v] = ""
+		}
+	}
+
+	return result
+}
+
+// parseEmotes parses an emotes tag like 25:0-4,12-16/1902:6-10
+// Start and End are rune positions in the message, End inclusive
+func parseEmotes(emotes string) []twitchdata.Emote {
+	var result []twitchdata.Emote
+	if emotes == "" {
+		return result
+	}
+
+	for _, emote := range strings.Split(emotes, "/") {
+		v := strings.SplitN(emote, ":", 2)
+		if len(v) != 2 {
+			continue
+		}
+
+		for _, pos := range strings.Split(v[1], ",") {
+			p := strings.SplitN(pos, "-", 2)
+			if len(p) != 2 {
+				continue
+			}
+			start, err := strconv.Atoi(p[0])
+			if err != nil {
+				continue
+			}
+			end, err := strconv.Atoi(p[1])
+			if err != nil {
+				continue
+			}
+			result = append(result, twitchdata.Emote{
+				Start: start,
+				End:   end,
+				Id:    v[0],
+			})
+		}
+	}
+
+	return result
+}
+
+func tagInt(tags map[string]string, key string) int {
+	i, _ := strconv.Atoi(tags[key])
+	return i
+}
+
+func tagBool(tags map[string]string, key string) bool {
+	return tags[key] == "1" || tags[key] == "true"
+}
+
+// tagTime parses a millisecond unix timestamp tag like tmi-sent-ts
+func tagTime(tags map[string]string, key string) time.Time {
+	ms, err := strconv.ParseInt(tags[key], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
@@ -0,0 +1,321 @@
+package twitchtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Subscription is an EventSub subscription created through the Helix stub
+type Subscription struct {
+	Id        string                 `json:"id"`
+	Status    string                 `json:"status"`
+	Type      string                 `json:"type"`
+	Version   string                 `json:"version"`
+	Condition map[string]interface{} `json:"condition"`
+	Transport map[string]interface{} `json:"transport"`
+	CreatedAt time.Time              `json:"created_at"`
+	Cost      int                    `json:"cost"`
+
+	sessionId string
+	callback  string
+	secret    string
+}
+
+// EventSubServer is a fake EventSub websocket transport
+type EventSubServer struct {
+	sync.Mutex
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	sessions map[string]*eventSubSession
+
+	// KeepaliveTimeout is sent on the welcome message of new sessions
+	KeepaliveTimeout time.Duration
+}
+
+type eventSubSession struct {
+	sync.Mutex
+	id            string
+	conn          *websocket.Conn
+	subscriptions map[string]*Subscription
+	done          chan struct{}
+}
+
+func (s *eventSubSession) send(v interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.conn.WriteJSON(v)
+}
+
+func newEventSubServer() *EventSubServer {
+	s := &EventSubServer{
+		sessions:         map[string]*eventSubSession{},
+		KeepaliveTimeout: time.Second * 10,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL is the websocket url of the server
+func (s *EventSubServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/ws"
+}
+
+func (s *EventSubServer) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+// Disconnect drops all sessions. Their subscriptions are lost, like on twitch
+func (s *EventSubServer) Disconnect() {
+	s.Lock()
+	sessions := s.sessions
+	s.sessions = map[string]*eventSubSession{}
+	s.Unlock()
+
+	for _, session := range sessions {
+		_ = session.conn.Close()
+	}
+}
+
+// Reconnect sends session_reconnect to all sessions. Subscriptions move to the new connection
+func (s *EventSubServer) Reconnect() {
+	s.Lock()
+	sessions := make([]*eventSubSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.Unlock()
+
+	for _, session := range sessions {
+		_ = session.send(s.message("session_reconnect", "", map[string]interface{}{
+			"session": map[string]interface{}{
+				"id":                        session.id,
+				"status":                    "reconnecting",
+				"keepalive_timeout_seconds": nil,
+				"reconnect_url":             s.URL() + "?reconnect=" + session.id,
+			},
+		}))
+	}
+}
+
+// Sessions returns the number of connected sessions
+func (s *EventSubServer) Sessions() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.sessions)
+}
+
+// subscribe adds a subscription to a session
+func (s *EventSubServer) subscribe(sub *Subscription) error {
+	s.Lock()
+	session, ok := s.sessions[sub.sessionId]
+	s.Unlock()
+
+	if !ok {
+		return fmt.Errorf("session %s does not exist", sub.sessionId)
+	}
+
+	session.Lock()
+	session.subscriptions[sub.Id] = sub
+	session.Unlock()
+
+	return nil
+}
+
+func (s *EventSubServer) subscriptions() []*Subscription {
+	s.Lock()
+	defer s.Unlock()
+
+	var subs []*Subscription
+	for _, session := range s.sessions {
+		session.Lock()
+		for _, sub := range session.subscriptions {
+			subs = append(subs, sub)
+		}
+		session.Unlock()
+	}
+
+	return subs
+}
+
+func (s *EventSubServer) unsubscribe(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, session := range s.sessions {
+		session.Lock()
+		_, ok := session.subscriptions[id]
+		delete(session.subscriptions, id)
+		session.Unlock()
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Notify sends a notification to every session subscribed to subType.
+// It returns an error if no session is subscribed
+func (s *EventSubServer) Notify(subType string, event interface{}) error {
+	s.Lock()
+	sessions := make([]*eventSubSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.Unlock()
+
+	delivered := 0
+
+	for _, session := range sessions {
+		session.Lock()
+		var subs []*Subscription
+		for _, sub := range session.subscriptions {
+			if sub.Type == subType {
+				subs = append(subs, sub)
+			}
+		}
+		session.Unlock()
+
+		for _, sub := range subs {
+			msg := s.message("notification", subType, map[string]interface{}{
+				"subscription": sub,
+				"event":        event,
+			})
+			msg["metadata"].(map[string]interface{})["subscription_version"] = sub.Version
+			if session.send(msg) == nil {
+				delivered++
+			}
+		}
+	}
+
+	if delivered == 0 {
+		return fmt.Errorf("no session subscribed to %s", subType)
+	}
+
+	return nil
+}
+
+func (s *EventSubServer) message(messageType, subType string, payload map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"message_id":        uuid.New().String(),
+		"message_type":      messageType,
+		"message_timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if subType != "" {
+		metadata["subscription_type"] = subType
+	}
+
+	return map[string]interface{}{
+		"metadata": metadata,
+		"payload":  payload,
+	}
+}
+
+func (s *EventSubServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	session := &eventSubSession{
+		id:            uuid.New().String(),
+		conn:          conn,
+		subscriptions: map[string]*Subscription{},
+		done:          make(chan struct{}),
+	}
+
+	// Sessions created from a session_reconnect keep the old subscriptions
+	var old *eventSubSession
+	if oldId := r.URL.Query().Get("reconnect"); oldId != "" {
+		s.Lock()
+		old = s.sessions[oldId]
+		s.Unlock()
+
+		if old != nil {
+			old.Lock()
+			for id, sub := range old.subscriptions {
+				sub.sessionId = session.id
+				session.subscriptions[id] = sub
+			}
+			old.Unlock()
+		}
+	}
+
+	s.Lock()
+	s.sessions[session.id] = session
+	s.Unlock()
+
+	err = session.send(s.message("session_welcome", "", map[string]interface{}{
+		"session": map[string]interface{}{
+			"id":                        session.id,
+			"status":                    "connected",
+			"keepalive_timeout_seconds": int(s.KeepaliveTimeout / time.Second),
+			"connected_at":              time.Now().UTC().Format(time.RFC3339Nano),
+		},
+	}))
+
+	if old != nil {
+		s.Lock()
+		delete(s.sessions, old.id)
+		s.Unlock()
+		_ = old.conn.Close()
+	}
+
+	if err != nil {
+		s.closeSession(session)
+		return
+	}
+
+	go s.keepalive(session)
+
+	// Clients are not supposed to send anything. Reading detects the disconnection
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	s.closeSession(session)
+}
+
+func (s *EventSubServer) closeSession(session *eventSubSession) {
+	s.Lock()
+	if s.sessions[session.id] == session {
+		delete(s.sessions, session.id)
+	}
+	s.Unlock()
+
+	select {
+	case <-session.done:
+	default:
+		close(session.done)
+	}
+	_ = session.conn.Close()
+}
+
+func (s *EventSubServer) keepalive(session *eventSubSession) {
+	interval := s.KeepaliveTimeout / 2
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-t.C:
+			_ = session.send(s.message("session_keepalive", "", map[string]interface{}{}))
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package twitchtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clip is a clip returned by the fake /helix/clips
+type Clip struct {
+	Id        string    `json:"id"`
+	Url       string    `json:"url"`
+	Title     string    `json:"title"`
+	CreatorId string    `json:"creator_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HelixServer is a fake Helix API and OAuth token endpoint
+type HelixServer struct {
+	sync.Mutex
+	server   *httptest.Server
+	tokens   *tokenStore
+	eventSub *EventSubServer
+	users    *userList
+	webhooks map[string]*Subscription
+	clips    []Clip
+	requests []string
+	rewards  []*CustomReward
+	updates  []RedemptionUpdate
+	follows  map[string]time.Time
+	stream   streamState
+}
+
+// streamState is the channel returned by /helix/streams and /helix/channels
+type streamState struct {
+	online    bool
+	startedAt time.Time
+	title     string
+	gameId    string
+	game      string
+}
+
+func newHelixServer(tokens *tokenStore, eventSub *EventSubServer, users *userList) *HelixServer {
+	s := &HelixServer{
+		tokens:   tokens,
+		eventSub: eventSub,
+		users:    users,
+		webhooks: map[string]*Subscription{},
+		follows:  map[string]time.Time{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", s.handleToken)
+	mux.HandleFunc("/helix/users", s.authenticated(s.handleUsers))
+	mux.HandleFunc("/helix/clips", s.authenticated(s.handleClips))
+	mux.HandleFunc("/helix/channels/followers", s.authenticated(s.handleFollowers))
+	mux.HandleFunc("/helix/channels", s.authenticated(s.handleChannels))
+	mux.HandleFunc("/helix/streams", s.authenticated(s.handleStreams))
+	mux.HandleFunc("/helix/eventsub/subscriptions", s.authenticated(s.handleSubscriptions))
+	mux.HandleFunc("/helix/channel_points/custom_rewards", s.authenticated(s.handleCustomRewards))
+	mux.HandleFunc("/helix/channel_points/custom_rewards/redemptions", s.authenticated(s.handleRedemptions))
+	mux.HandleFunc("/helix/", s.authenticated(s.handleDefault))
+
+	s.server = httptest.NewServer(mux)
+
+	return s
+}
+
+// URL is the Helix base url
+func (s *HelixServer) URL() string {
+	return s.server.URL + "/helix"
+}
+
+// TokenURL is the OAuth token url
+func (s *HelixServer) TokenURL() string {
+	return s.server.URL + "/oauth2/token"
+}
+
+func (s *HelixServer) Close() {
+	s.server.Close()
+}
+
+// AddClip adds a clip to the clip list
+func (s *HelixServer) AddClip(c Clip) {
+	if c.Id == "" {
+		c.Id = uuid.New().String()
+	}
+
+	s.Lock()
+	s.clips = append(s.clips, c)
+	s.Unlock()
+}
+
+// AddFollower makes the user follow the channel, without sending a notification
+func (s *HelixServer) AddFollower(u User) {
+	s.Lock()
+	s.follows[u.Id] = time.Now().UTC()
+	s.Unlock()
+}
+
+// RemoveFollower makes the user unfollow the channel
+func (s *HelixServer) RemoveFollower(u User) {
+	s.Lock()
+	delete(s.follows, u.Id)
+	s.Unlock()
+}
+
+// SetLive sets the stream returned by /helix/streams, without sending a notification.
+// A zero startedAt is offline
+func (s *HelixServer) SetLive(startedAt time.Time) {
+	s.Lock()
+	s.stream.online = !startedAt.IsZero()
+	s.stream.startedAt = startedAt
+	s.Unlock()
+}
+
+// SetChannel sets the title and category returned by /helix/channels and /helix/streams
+func (s *HelixServer) SetChannel(title, categoryId, categoryName string) {
+	s.Lock()
+	s.stream.title = title
+	s.stream.gameId = categoryId
+	s.stream.game = categoryName
+	s.Unlock()
+}
+
+// Requests returns "METHOD /path" of every Helix request received
+func (s *HelixServer) Requests() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// Webhooks returns the enabled webhook subscriptions
+func (s *HelixServer) Webhooks() []*Subscription {
+	s.Lock()
+	defer s.Unlock()
+
+	var subs []*Subscription
+	for _, sub := range s.webhooks {
+		if sub.Status == "enabled" {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs
+}
+
+// NotifyWebhook posts a signed notification to every enabled webhook of subType.
+// It returns an error if no webhook is subscribed or a callback fails
+func (s *HelixServer) NotifyWebhook(subType string, event interface{}) error {
+	delivered := 0
+
+	for _, sub := range s.Webhooks() {
+		if sub.Type != subType {
+			continue
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"subscription": sub,
+			"event":        event,
+		})
+
+		res, err := s.postCallback(sub, "notification", body)
+		if err != nil {
+			return err
+		}
+		_ = res.Body.Close()
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("callback %s returned %d", sub.callback, res.StatusCode)
+		}
+
+		delivered++
+	}
+
+	if delivered == 0 {
+		return fmt.Errorf("no webhook subscribed to %s", subType)
+	}
+
+	return nil
+}
+
+func (s *HelixServer) postCallback(sub *Subscription, messageType string, body []byte) (*http.Response, error) {
+	id := uuid.New().String()
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	mac := hmac.New(sha256.New, []byte(sub.secret))
+	mac.Write([]byte(id + timestamp))
+	mac.Write(body)
+
+	req, err := http.NewRequest("POST", sub.callback, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Twitch-Eventsub-Message-Id", id)
+	req.Header.Add("Twitch-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Add("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Add("Twitch-Eventsub-Message-Type", messageType)
+	req.Header.Add("Twitch-Eventsub-Subscription-Type", sub.Type)
+	req.Header.Add("Twitch-Eventsub-Subscription-Version", sub.Version)
+
+	return http.DefaultClient.Do(req)
+}
+
+// verifyWebhook sends the callback verification challenge and enables the subscription if it is answered
+func (s *HelixServer) verifyWebhook(sub *Subscription) {
+	challenge := uuid.New().String()
+	body, _ := json.Marshal(map[string]interface{}{
+		"challenge":    challenge,
+		"subscription": sub,
+	})
+
+	status := "webhook_callback_verification_failed"
+
+	res, err := s.postCallback(sub, "webhook_callback_verification", body)
+	if err == nil {
+		data, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if res.StatusCode == http.StatusOK && string(data) == challenge {
+			status = "enabled"
+		}
+	}
+
+	s.Lock()
+	sub.Status = status
+	s.Unlock()
+}
+
+func (s *HelixServer) authenticated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.Lock()
+		s.requests = append(s.requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/helix"))
+		s.Unlock()
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !s.tokens.isValid(token) {
+			writeError(w, http.StatusUnauthorized, "Invalid OAuth token")
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func (s *HelixServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := map[string]interface{}{
+		"access_token": s.tokens.issue(),
+		"expires_in":   3600,
+		"token_type":   "bearer",
+	}
+
+	switch r.Form.Get("grant_type") {
+	case "client_credentials":
+	case "refresh_token":
+		res["refresh_token"] = uuid.New().String()
+	default:
+		writeError(w, http.StatusBadRequest, "invalid grant type")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *HelixServer) handleUsers(w http.ResponseWriter, r *http.Request) {
+	var users []User
+
+	logins := r.URL.Query()["login"]
+	ids := r.URL.Query()["id"]
+
+	if len(logins) == 0 && len(ids) == 0 {
+		// Without parameters twitch returns the token owner
+		users = append(users, s.users.channel)
+	}
+	for _, login := range logins {
+		users = append(users, s.users.get(login))
+	}
+	for _, id := range ids {
+		if u, ok := s.users.byId(id); ok {
+			users = append(users, u)
+		}
+	}
+
+	data := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		data = append(data, u.helix())
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (s *HelixServer) handleClips(w http.ResponseWriter, r *http.Request) {
+	since := time.Time{}
+	if v := r.URL.Query().Get("started_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		since = t
+	}
+
+	s.Lock()
+	data := make([]interface{}, 0, len(s.clips))
+	for _, c := range s.clips {
+		if !c.CreatedAt.Before(since) {
+			data = append(data, c)
+		}
+	}
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (s *HelixServer) handleFollowers(w http.ResponseWriter, r *http.Request) {
+	userId := r.URL.Query().Get("user_id")
+
+	s.Lock()
+	data := make([]interface{}, 0, len(s.follows))
+	for id, at := range s.follows {
+		if userId != "" && id != userId {
+			continue
+		}
+		u, _ := s.users.byId(id)
+		data = append(data, map[string]interface{}{
+			"user_id":     u.Id,
+			"user_login":  u.Login,
+			"user_name":   u.DisplayName,
+			"followed_at": at.Format(time.RFC3339),
+		})
+	}
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "total": len(data)})
+}
+
+func (s *HelixServer) handleStreams(w http.ResponseWriter, r *http.Request) {
+	channel := s.users.channel
+
+	s.Lock()
+	st := s.stream
+	s.Unlock()
+
+	data := []interface{}{}
+	if st.online && r.URL.Query().Get("user_id") == channel.Id {
+		data = append(data, map[string]interface{}{
+			"id":         strconv.FormatInt(st.startedAt.Unix(), 10),
+			"user_id":    channel.Id,
+			"user_login": channel.Login,
+			"user_name":  channel.DisplayName,
+			"game_id":    st.gameId,
+			"game_name":  st.game,
+			"type":       "live",
+			"title":      st.title,
+			"started_at": st.startedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (s *HelixServer) handleChannels(w http.ResponseWriter, r *http.Request) {
+	channel := s.users.channel
+	if id := r.URL.Query().Get("broadcaster_id"); id != channel.Id {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{}})
+		return
+	}
+
+	s.Lock()
+	st := s.stream
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{
+		map[string]interface{}{
+			"broadcaster_id":    channel.Id,
+			"broadcaster_login": channel.Login,
+			"broadcaster_name":  channel.DisplayName,
+			"game_id":           st.gameId,
+			"game_name":         st.game,
+			"title":             st.title,
+		},
+	}})
+}
+
+func (s *HelixServer) handleSubscriptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s.Lock()
+		data := make([]interface{}, 0, len(s.webhooks))
+		for _, sub := range s.webhooks {
+			data = append(data, sub)
+		}
+		s.Unlock()
+		for _, sub := range s.eventSub.subscriptions() {
+			data = append(data, sub)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": data, "total": len(data)})
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		s.Lock()
+		_, ok := s.webhooks[id]
+		delete(s.webhooks, id)
+		s.Unlock()
+		if !ok && !s.eventSub.unsubscribe(id) {
+			writeError(w, http.StatusNotFound, "subscription not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "POST":
+		s.createSubscription(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *HelixServer) createSubscription(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Type      string                 `json:"type"`
+		Version   string                 `json:"version"`
+		Condition map[string]interface{} `json:"condition"`
+		Transport struct {
+			Method    string `json:"method"`
+			SessionId string `json:"session_id"`
+			Callback  string `json:"callback"`
+			Secret    string `json:"secret"`
+		} `json:"transport"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Type == "" || req.Version == "" {
+		writeError(w, http.StatusBadRequest, "missing type or version")
+		return
+	}
+
+	sub := &Subscription{
+		Id:        uuid.New().String(),
+		Type:      req.Type,
+		Version:   req.Version,
+		Condition: req.Condition,
+		CreatedAt: time.Now().UTC(),
+		sessionId: req.Transport.SessionId,
+		callback:  req.Transport.Callback,
+		secret:    req.Transport.Secret,
+	}
+
+	switch req.Transport.Method {
+	case "websocket":
+		sub.Status = "enabled"
+		sub.Transport = map[string]interface{}{"method": "websocket", "session_id": sub.sessionId}
+		if err := s.eventSub.subscribe(sub); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case "webhook":
+		sub.Status = "webhook_callback_verification_pending"
+		sub.Transport = map[string]interface{}{"method": "webhook", "callback": sub.callback}
+		s.Lock()
+		s.webhooks[sub.Id] = sub
+		s.Unlock()
+		go s.verifyWebhook(sub)
+	default:
+		writeError(w, http.StatusBadRequest, "invalid transport method")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"data":  []interface{}{sub},
+		"total": 1,
+	})
+}
+
+// handleDefault accepts any other Helix call, so the code paths that only check for errors keep working
+func (s *HelixServer) handleDefault(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":   http.StatusText(status),
+		"status":  status,
+		"message": message,
+	})
+}
@@ -0,0 +1,359 @@
+package twitchtest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/irc.v3"
+)
+
+const ircHost = "tmi.twitch.tv"
+
+// ChatMessage is a PRIVMSG sent by the bot
+type ChatMessage struct {
+	Channel string
+	Text    string
+	SentAt  time.Time
+}
+
+// IRCServer is a fake twitch chat server over TLS
+type IRCServer struct {
+	sync.Mutex
+	listener  net.Listener
+	tlsConfig *tls.Config
+	tokens    *tokenStore
+	channel   User
+	conns     map[*ircConn]bool
+	sent      []ChatMessage
+	messages  chan ChatMessage
+}
+
+type ircConn struct {
+	sync.Mutex
+	conn   net.Conn
+	irc    *irc.Conn
+	nick   string
+	pass   string
+	joined bool
+}
+
+func (c *ircConn) write(m *irc.Message) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.irc.WriteMessage(m)
+}
+
+func newIRCServer(tokens *tokenStore, channel User) (*IRCServer, error) {
+	cert, clientConfig, err := makeCertificate()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &IRCServer{
+		listener:  l,
+		tlsConfig: clientConfig,
+		tokens:    tokens,
+		channel:   channel,
+		conns:     map[*ircConn]bool{},
+		messages:  make(chan ChatMessage, 64),
+	}
+
+	go s.accept()
+
+	return s, nil
+}
+
+// Addr is the host:port of the server
+func (s *IRCServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// TLSConfig is a client config that trusts the server certificate
+func (s *IRCServer) TLSConfig() *tls.Config {
+	return s.tlsConfig
+}
+
+func (s *IRCServer) Close() {
+	_ = s.listener.Close()
+	s.Disconnect()
+}
+
+// Disconnect drops all client connections
+func (s *IRCServer) Disconnect() {
+	s.Lock()
+	conns := s.conns
+	s.conns = map[*ircConn]bool{}
+	s.Unlock()
+
+	for c := range conns {
+		_ = c.conn.Close()
+	}
+}
+
+// Reconnect sends a RECONNECT to all clients, like twitch does before a server restart
+func (s *IRCServer) Reconnect() {
+	s.broadcast(&irc.Message{
+		Prefix:  &irc.Prefix{Name: ircHost},
+		Command: "RECONNECT",
+	}, false)
+}
+
+// Connected returns the number of logged in clients that joined the channel
+func (s *IRCServer) Connected() int {
+	s.Lock()
+	defer s.Unlock()
+
+	n := 0
+	for c := range s.conns {
+		c.Lock()
+		if c.joined {
+			n++
+		}
+		c.Unlock()
+	}
+
+	return n
+}
+
+// Sent returns every message sent by the bot so far
+func (s *IRCServer) Sent() []ChatMessage {
+	s.Lock()
+	defer s.Unlock()
+	return append([]ChatMessage{}, s.sent...)
+}
+
+// WaitMessage waits for the next message sent by the bot
+func (s *IRCServer) WaitMessage(timeout time.Duration) (ChatMessage, error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case m := <-s.messages:
+		return m, nil
+	case <-t.C:
+		return ChatMessage{}, fmt.Errorf("timeout waiting chat message")
+	}
+}
+
+// Message sends a chat message from user to the channel
+func (s *IRCServer) Message(user User, text string) {
+	tags := s.userTags(user)
+	tags["id"] = irc.TagValue(uuid.New().String())
+	tags["tmi-sent-ts"] = irc.TagValue(strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+
+	s.broadcast(&irc.Message{
+		Tags:    tags,
+		Prefix:  user.prefix(),
+		Command: "PRIVMSG",
+		Params:  []string{"#" + s.channel.Login, text},
+	}, true)
+}
+
+// UserNotice sends a USERNOTICE with the msg-id and extra msg-param tags
+func (s *IRCServer) UserNotice(user User, msgId, systemMessage, text string, params map[string]string) {
+	tags := s.userTags(user)
+	tags["id"] = irc.TagValue(uuid.New().String())
+	tags["login"] = irc.TagValue(user.Login)
+	tags["msg-id"] = irc.TagValue(msgId)
+	tags["system-msg"] = irc.TagValue(systemMessage)
+	tags["tmi-sent-ts"] = irc.TagValue(strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+	for k, v := range params {
+		tags["msg-param-"+k] = irc.TagValue(v)
+	}
+
+	m := &irc.Message{
+		Tags:    tags,
+		Prefix:  &irc.Prefix{Name: ircHost},
+		Command: "USERNOTICE",
+		Params:  []string{"#" + s.channel.Login},
+	}
+	if text != "" {
+		m.Params = append(m.Params, text)
+	}
+
+	s.broadcast(m, true)
+}
+
+// Raid sends a raid USERNOTICE
+func (s *IRCServer) Raid(user User, viewers int) {
+	s.UserNotice(user, "raid", fmt.Sprintf("%d raiders from %s have joined!", viewers, user.DisplayName), "", map[string]string{
+		"displayName": user.DisplayName,
+		"login":       user.Login,
+		"viewerCount": strconv.Itoa(viewers),
+	})
+}
+
+// Raw sends a raw IRC line to all clients
+func (s *IRCServer) Raw(line string) error {
+	m, err := irc.ParseMessage(line)
+	if err != nil {
+		return err
+	}
+	s.broadcast(m, false)
+	return nil
+}
+
+func (s *IRCServer) userTags(user User) irc.Tags {
+	return irc.Tags{
+		"badges":       irc.TagValue(user.Badges),
+		"color":        "#FF0000",
+		"display-name": irc.TagValue(user.DisplayName),
+		"emotes":       "",
+		"mod":          boolTag(strings.Contains(user.Badges, "moderator/")),
+		"room-id":      irc.TagValue(s.channel.Id),
+		"subscriber":   boolTag(strings.Contains(user.Badges, "subscriber/")),
+		"user-id":      irc.TagValue(user.Id),
+	}
+}
+
+func boolTag(v bool) irc.TagValue {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// broadcast sends m to all clients. If joinedOnly, only to clients in the channel
+func (s *IRCServer) broadcast(m *irc.Message, joinedOnly bool) {
+	s.Lock()
+	conns := make([]*ircConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.Unlock()
+
+	for _, c := range conns {
+		c.Lock()
+		joined := c.joined
+		c.Unlock()
+		if joinedOnly && !joined {
+			continue
+		}
+		_ = c.write(m)
+	}
+}
+
+func (s *IRCServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &ircConn{
+			conn: conn,
+			irc:  irc.NewConn(conn),
+		}
+
+		s.Lock()
+		s.conns[c] = true
+		s.Unlock()
+
+		go s.handle(c)
+	}
+}
+
+func (s *IRCServer) handle(c *ircConn) {
+	defer func() {
+		s.Lock()
+		delete(s.conns, c)
+		s.Unlock()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		m, err := c.irc.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		switch m.Command {
+		case "PASS":
+			c.pass = strings.TrimPrefix(m.Trailing(), "oauth:")
+		case "NICK":
+			c.nick = m.Trailing()
+			if !s.tokens.isValid(c.pass) {
+				_ = c.write(&irc.Message{
+					Prefix:  &irc.Prefix{Name: ircHost},
+					Command: "NOTICE",
+					Params:  []string{"*", "Login authentication failed"},
+				})
+				return
+			}
+			_ = c.write(&irc.Message{Prefix: &irc.Prefix{Name: ircHost}, Command: "001", Params: []string{c.nick, "Welcome, GLHF!"}})
+			_ = c.write(&irc.Message{Prefix: &irc.Prefix{Name: ircHost}, Command: "376", Params: []string{c.nick, ">"}})
+		case "CAP":
+			if len(m.Params) > 0 && m.Params[0] == "REQ" {
+				_ = c.write(&irc.Message{Prefix: &irc.Prefix{Name: ircHost}, Command: "CAP", Params: []string{"*", "ACK", m.Trailing()}})
+			}
+		case "PING":
+			_ = c.write(&irc.Message{Prefix: &irc.Prefix{Name: ircHost}, Command: "PONG", Params: []string{ircHost, m.Trailing()}})
+		case "JOIN":
+			s.join(c, m.Trailing())
+		case "PART":
+			c.Lock()
+			c.joined = false
+			c.Unlock()
+		case "PRIVMSG":
+			if len(m.Params) < 2 {
+				continue
+			}
+			msg := ChatMessage{
+				Channel: strings.TrimPrefix(m.Params[0], "#"),
+				Text:    m.Trailing(),
+				SentAt:  time.Now(),
+			}
+			s.Lock()
+			s.sent = append(s.sent, msg)
+			s.Unlock()
+			select {
+			case s.messages <- msg:
+			default:
+			}
+		}
+	}
+}
+
+func (s *IRCServer) join(c *ircConn, channel string) {
+	prefix := &irc.Prefix{Name: c.nick, User: c.nick, Host: c.nick + "." + ircHost}
+
+	c.Lock()
+	c.joined = true
+	c.Unlock()
+
+	_ = c.write(&irc.Message{Prefix: prefix, Command: "JOIN", Params: []string{channel}})
+	_ = c.write(&irc.Message{
+		Tags: irc.Tags{
+			"badges":       "",
+			"display-name": irc.TagValue(c.nick),
+			"mod":          "0",
+		},
+		Prefix:  &irc.Prefix{Name: ircHost},
+		Command: "USERSTATE",
+		Params:  []string{channel},
+	})
+	_ = c.write(&irc.Message{
+		Tags: irc.Tags{
+			"emote-only":     "0",
+			"followers-only": "-1",
+			"r9k":            "0",
+			"room-id":        irc.TagValue(s.channel.Id),
+			"slow":           "0",
+			"subs-only":      "0",
+		},
+		Prefix:  &irc.Prefix{Name: ircHost},
+		Command: "ROOMSTATE",
+		Params:  []string{channel},
+	})
+}
@@ -0,0 +1,244 @@
+package twitchtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// CustomReward is a channel point reward of the fake /helix/channel_points/custom_rewards
+type CustomReward struct {
+	Id                    string
+	Title                 string
+	Prompt                string
+	Cost                  int
+	BackgroundColor       string
+	IsEnabled             bool
+	IsPaused              bool
+	IsUserInputRequired   bool
+	GlobalCooldownSeconds int
+	// Manageable is true for rewards created through the API. Dashboard rewards cannot be updated
+	Manageable bool
+}
+
+func (r *CustomReward) helix() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                     r.Id,
+		"title":                  r.Title,
+		"prompt":                 r.Prompt,
+		"cost":                   r.Cost,
+		"background_color":       r.BackgroundColor,
+		"is_enabled":             r.IsEnabled,
+		"is_paused":              r.IsPaused,
+		"is_user_input_required": r.IsUserInputRequired,
+		"global_cooldown_setting": map[string]interface{}{
+			"is_enabled":              r.GlobalCooldownSeconds > 0,
+			"global_cooldown_seconds": r.GlobalCooldownSeconds,
+		},
+	}
+}
+
+// RedemptionUpdate is a redemption status change received by the fake Helix
+type RedemptionUpdate struct {
+	RewardId     string
+	RedemptionId string
+	Status       string
+}
+
+// AddReward adds a reward to the channel, like one made on the dashboard when not Manageable
+func (s *HelixServer) AddReward(r CustomReward) CustomReward {
+	if r.Id == "" {
+		r.Id = uuid.New().String()
+	}
+
+	s.Lock()
+	s.rewards = append(s.rewards, &r)
+	s.Unlock()
+
+	return r
+}
+
+// Rewards returns the rewards of the channel
+func (s *HelixServer) Rewards() []CustomReward {
+	s.Lock()
+	defer s.Unlock()
+
+	rewards := make([]CustomReward, 0, len(s.rewards))
+	for _, r := range s.rewards {
+		rewards = append(rewards, *r)
+	}
+
+	return rewards
+}
+
+// RedemptionUpdates returns the redemption status changes received
+func (s *HelixServer) RedemptionUpdates() []RedemptionUpdate {
+	s.Lock()
+	defer s.Unlock()
+	return append([]RedemptionUpdate{}, s.updates...)
+}
+
+func (s *HelixServer) rewardByTitle(title string) (CustomReward, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, r := range s.rewards {
+		if strings.EqualFold(r.Title, title) {
+			return *r, true
+		}
+	}
+
+	return CustomReward{}, false
+}
+
+// findReward returns the reward with id. Called with the lock held
+func (s *HelixServer) findReward(id string) *CustomReward {
+	for _, r := range s.rewards {
+		if r.Id == id {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *HelixServer) handleCustomRewards(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		onlyManageable := r.URL.Query().Get("only_manageable_rewards") == "true"
+		s.Lock()
+		data := make([]interface{}, 0, len(s.rewards))
+		for _, reward := range s.rewards {
+			if !onlyManageable || reward.Manageable {
+				data = append(data, reward.helix())
+			}
+		}
+		s.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+	case "POST":
+		reward := &CustomReward{Id: uuid.New().String(), IsEnabled: true, Manageable: true}
+		if !s.applyReward(w, r, reward) {
+			return
+		}
+		s.Lock()
+		for _, existing := range s.rewards {
+			if strings.EqualFold(existing.Title, reward.Title) {
+				s.Unlock()
+				writeError(w, http.StatusBadRequest, "CREATE_CUSTOM_REWARD_DUPLICATE_REWARD")
+				return
+			}
+		}
+		s.rewards = append(s.rewards, reward)
+		s.Unlock()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{reward.helix()}})
+	case "PATCH":
+		s.Lock()
+		reward := s.findReward(r.URL.Query().Get("id"))
+		s.Unlock()
+		if reward == nil {
+			writeError(w, http.StatusNotFound, "reward not found")
+			return
+		}
+		if !reward.Manageable {
+			writeError(w, http.StatusForbidden, "The ID in header Client-Id must match the client ID used to create the custom reward")
+			return
+		}
+		if !s.applyReward(w, r, reward) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{reward.helix()}})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// applyReward sets the fields present on the request body
+func (s *HelixServer) applyReward(w http.ResponseWriter, r *http.Request, reward *CustomReward) bool {
+	req := struct {
+		Title                   *string `json:"title"`
+		Prompt                  *string `json:"prompt"`
+		Cost                    *int    `json:"cost"`
+		BackgroundColor         *string `json:"background_color"`
+		IsEnabled               *bool   `json:"is_enabled"`
+		IsPaused                *bool   `json:"is_paused"`
+		IsUserInputRequired     *bool   `json:"is_user_input_required"`
+		IsGlobalCooldownEnabled *bool   `json:"is_global_cooldown_enabled"`
+		GlobalCooldownSeconds   *int    `json:"global_cooldown_seconds"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if req.Title != nil {
+		reward.Title = *req.Title
+	}
+	if req.Prompt != nil {
+		reward.Prompt = *req.Prompt
+	}
+	if req.Cost != nil {
+		reward.Cost = *req.Cost
+	}
+	if req.BackgroundColor != nil {
+		reward.BackgroundColor = *req.BackgroundColor
+	}
+	if req.IsEnabled != nil {
+		reward.IsEnabled = *req.IsEnabled
+	}
+	if req.IsPaused != nil {
+		reward.IsPaused = *req.IsPaused
+	}
+	if req.IsUserInputRequired != nil {
+		reward.IsUserInputRequired = *req.IsUserInputRequired
+	}
+	if req.IsGlobalCooldownEnabled != nil && !*req.IsGlobalCooldownEnabled {
+		reward.GlobalCooldownSeconds = 0
+	}
+	if req.GlobalCooldownSeconds != nil {
+		reward.GlobalCooldownSeconds = *req.GlobalCooldownSeconds
+	}
+
+	return true
+}
+
+func (s *HelixServer) handleRedemptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PATCH" {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	req := struct {
+		Status string `json:"status"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rewardId := r.URL.Query().Get("reward_id")
+
+	s.Lock()
+	reward := s.findReward(rewardId)
+	if reward == nil || !reward.Manageable {
+		s.Unlock()
+		writeError(w, http.StatusForbidden, "The ID in header Client-Id must match the client ID used to create the custom reward")
+		return
+	}
+	update := RedemptionUpdate{
+		RewardId:     rewardId,
+		RedemptionId: r.URL.Query().Get("id"),
+		Status:       req.Status,
+	}
+	s.updates = append(s.updates, update)
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": []interface{}{map[string]interface{}{
+		"id":     update.RedemptionId,
+		"status": update.Status,
+	}}})
+}
@@ -0,0 +1,121 @@
+package twitchtest
+
+import (
+	"fmt"
+	"time"
+)
+
+// Step is a single action of a Scenario
+type Step struct {
+	Name   string
+	Action func(s *Server) error
+}
+
+// Scenario is a scripted sequence of stream events, like:
+//
+//	NewScenario().Follow("viewer").Wait(time.Second).Subscribe("viewer", "1000").Run(server)
+type Scenario struct {
+	steps []Step
+}
+
+func NewScenario() *Scenario {
+	return &Scenario{}
+}
+
+// Then adds a custom step
+func (sc *Scenario) Then(name string, action func(s *Server) error) *Scenario {
+	sc.steps = append(sc.steps, Step{Name: name, Action: action})
+	return sc
+}
+
+func (sc *Scenario) Wait(d time.Duration) *Scenario {
+	return sc.Then(fmt.Sprintf("wait %s", d), func(s *Server) error {
+		time.Sleep(d)
+		return nil
+	})
+}
+
+func (sc *Scenario) Chat(login, text string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s says %q", login, text), func(s *Server) error {
+		s.Chat(login, text)
+		return nil
+	})
+}
+
+func (sc *Scenario) Follow(login string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s follows", login), func(s *Server) error {
+		return s.Follow(login)
+	})
+}
+
+func (sc *Scenario) Subscribe(login, plan string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s subscribes", login), func(s *Server) error {
+		return s.Subscribe(login, plan)
+	})
+}
+
+func (sc *Scenario) Resub(login, plan string, months int, message string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s resubscribes for %d months", login, months), func(s *Server) error {
+		return s.Resub(login, plan, months, message)
+	})
+}
+
+func (sc *Scenario) GiftSubs(gifter, plan string, total int) *Scenario {
+	return sc.Then(fmt.Sprintf("%s gifts %d subs", gifter, total), func(s *Server) error {
+		return s.GiftSubs(gifter, plan, total)
+	})
+}
+
+func (sc *Scenario) Cheer(login string, bits int, message string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s cheers %d bits", login, bits), func(s *Server) error {
+		return s.Cheer(login, bits, message)
+	})
+}
+
+func (sc *Scenario) Redeem(login, title string, cost int, input string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s redeems %q", login, title), func(s *Server) error {
+		return s.Redeem(login, title, cost, input)
+	})
+}
+
+func (sc *Scenario) Raid(login string, viewers int) *Scenario {
+	return sc.Then(fmt.Sprintf("%s raids with %d viewers", login, viewers), func(s *Server) error {
+		s.Raid(login, viewers)
+		return nil
+	})
+}
+
+func (sc *Scenario) Clip(login, title string) *Scenario {
+	return sc.Then(fmt.Sprintf("%s clips %q", login, title), func(s *Server) error {
+		s.Clip(login, title, time.Now())
+		return nil
+	})
+}
+
+func (sc *Scenario) StreamOnline() *Scenario {
+	return sc.Then("stream goes online", func(s *Server) error {
+		return s.StreamOnline()
+	})
+}
+
+func (sc *Scenario) StreamOffline() *Scenario {
+	return sc.Then("stream goes offline", func(s *Server) error {
+		return s.StreamOffline()
+	})
+}
+
+// Steps returns the steps added so far
+func (sc *Scenario) Steps() []Step {
+	return append([]Step{}, sc.steps...)
+}
+
+// Run executes all steps in order, stopping at the first error
+func (sc *Scenario) Run(s *Server) error {
+	for i, step := range sc.steps {
+		if err := step.Action(s); err != nil {
+			return fmt.Errorf("step %d (%s): %s", i+1, step.Name, err)
+		}
+	}
+
+	return nil
+}
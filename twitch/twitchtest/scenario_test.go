@@ -0,0 +1,165 @@
+package twitchtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asaskevich/EventBus"
+	"github.com/racerxdl/twitchled/twitch"
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+)
+
+const eventTimeout = 5 * time.Second
+
+// eventRecorder publishes the chat and monitor events on a bus, like the bot does, and records them
+type eventRecorder struct {
+	ev     EventBus.Bus
+	events chan twitch.ChatEvent
+	done   chan struct{}
+}
+
+func recordEvents(types []twitch.EventType, sources ...chan twitch.ChatEvent) *eventRecorder {
+	r := &eventRecorder{
+		ev:     EventBus.New(),
+		events: make(chan twitch.ChatEvent, 64),
+		done:   make(chan struct{}),
+	}
+
+	for _, t := range types {
+		_ = r.ev.Subscribe(string(t), func(e twitch.ChatEvent) {
+			r.events <- e
+		})
+	}
+
+	for _, source := range sources {
+		go func(source chan twitch.ChatEvent) {
+			for {
+				select {
+				case e := <-source:
+					r.ev.Publish(string(e.GetType()), e)
+				case <-r.done:
+					return
+				}
+			}
+		}(source)
+	}
+
+	return r
+}
+
+func (r *eventRecorder) Stop() {
+	close(r.done)
+}
+
+// wait returns the next recorded event, that must be of eventType
+func (r *eventRecorder) wait(t *testing.T, eventType twitch.EventType) twitch.ChatEvent {
+	t.Helper()
+	select {
+	case e := <-r.events:
+		if e.GetType() != eventType {
+			t.Fatalf("got %s event %v, want %s", e.GetType(), e.AsMap(), eventType)
+		}
+		return e
+	case <-time.After(eventTimeout):
+		t.Fatalf("timeout waiting %s event", eventType)
+	}
+	return nil
+}
+
+func TestScenario(t *testing.T) {
+	s := startServer(t)
+	defer s.Close()
+
+	chat, err := twitch.MakeChat("racerxdl", "racerxdl", s.UserToken)
+	if err != nil {
+		t.Fatalf("MakeChat: %s", err)
+	}
+	defer chat.Close()
+	if err := s.WaitChat(eventTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	mon := twitch.MakeMonitor(s.Channel().Id)
+	if err := mon.Start(); err != nil {
+		t.Fatalf("Monitor.Start: %s", err)
+	}
+	defer mon.Stop()
+	// channel.subscribe, channel.subscription.message, channel.subscription.gift, channel.cheer and redemptions
+	if err := s.WaitSubscriptions(5, eventTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	r := recordEvents([]twitch.EventType{
+		twitch.EventMessage,
+		twitch.EventSubscribe,
+		twitch.EventBits,
+		twitch.EventRewardRedemption,
+		twitch.EventUserNotice,
+	}, chat.Events, mon.EventChannel())
+	defer r.Stop()
+
+	err = NewScenario().
+		Chat("viewer", "hello chat").
+		Then("check message", func(s *Server) error {
+			data := r.wait(t, twitch.EventMessage).GetData().(*twitch.MessageEventData)
+			if data.Username != "viewer" || data.Message != "hello chat" {
+				t.Errorf("message = %s: %q", data.Username, data.Message)
+			}
+			return nil
+		}).
+		Subscribe("viewer", "2000").
+		Then("check subscribe", func(s *Server) error {
+			data := r.wait(t, twitch.EventSubscribe).GetData().(twitchdata.ChannelSubscribeMessageData)
+			if data.UserName != "viewer" || data.SubPlan != "2000" {
+				t.Errorf("subscribe = %+v", data)
+			}
+			return nil
+		}).
+		Cheer("viewer", 100, "Cheer100 nice").
+		Then("check bits", func(s *Server) error {
+			data := r.wait(t, twitch.EventBits).GetData().(twitchdata.BitEventsV2)
+			if data.Data.UserName != "viewer" || data.Data.BitsUsed != 100 {
+				t.Errorf("bits = %+v", data.Data)
+			}
+			return nil
+		}).
+		Redeem("viewer", "Hydrate", 500, "now").
+		Then("check redemption", func(s *Server) error {
+			data := r.wait(t, twitch.EventRewardRedemption).GetData().(*twitch.RewardRedemptionEventData)
+			if data.Data.User.Name != "viewer" || data.Data.Reward.Title != "Hydrate" || data.Data.UserInput != "now" {
+				t.Errorf("redemption = %+v", data.Data)
+			}
+			return nil
+		}).
+		Raid("raider", 42).
+		Then("check raid", func(s *Server) error {
+			notice := r.wait(t, twitch.EventUserNotice).GetData().(*twitch.UserNoticeEventData)
+			if notice.RaidViewers() != 42 {
+				t.Errorf("raid viewers = %d, want 42", notice.RaidViewers())
+			}
+			return nil
+		}).
+		Run(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetClips(t *testing.T) {
+	s := startServer(t)
+	defer s.Close()
+
+	since := time.Now().Add(-time.Hour)
+	old := s.Clip("viewer", "old clip", time.Now().Add(-2*time.Hour))
+	clip := s.Clip("viewer", "nice play", time.Now().Add(-2*time.Minute))
+	// Clips newer than a minute may still be processing
+	s.Clip("viewer", "processing", time.Now())
+
+	urls, err := twitch.GetClips(s.Channel().Id, since)
+	if err != nil {
+		t.Fatalf("GetClips: %s", err)
+	}
+	if len(urls) != 1 || urls[0] != clip.Url {
+		t.Errorf("GetClips = %v, want [%s] without %s", urls, clip.Url, old.Url)
+	}
+}
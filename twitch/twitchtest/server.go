@@ -0,0 +1,319 @@
+// Package twitchtest runs in-process fakes of the twitch chat, EventSub websocket and Helix API,
+// so the bot can be tested end to end without network access.
+package twitchtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/twitch"
+	"golang.org/x/oauth2"
+)
+
+const (
+	ClientId     = "twitchtest-client"
+	ClientSecret = "twitchtest-secret"
+)
+
+// Server groups the fake IRC, EventSub and Helix servers for a single channel
+type Server struct {
+	IRC      *IRCServer
+	EventSub *EventSubServer
+	Helix    *HelixServer
+
+	// UserToken and AppToken are valid tokens issued on start
+	UserToken string
+	AppToken  string
+
+	tokens *tokenStore
+	users  *userList
+}
+
+// NewServer starts all fake servers for channelLogin
+func NewServer(channelLogin string) (*Server, error) {
+	channel := User{
+		Id:          "1",
+		Login:       strings.ToLower(channelLogin),
+		DisplayName: channelLogin,
+		Badges:      "broadcaster/1",
+	}
+
+	tokens := makeTokenStore()
+	users := makeUserList(channel)
+
+	ircServer, err := newIRCServer(tokens, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	eventSub := newEventSubServer()
+
+	s := &Server{
+		IRC:       ircServer,
+		EventSub:  eventSub,
+		Helix:     newHelixServer(tokens, eventSub, users),
+		UserToken: tokens.issue(),
+		AppToken:  tokens.issue(),
+		tokens:    tokens,
+		users:     users,
+	}
+
+	return s, nil
+}
+
+// Endpoints returns twitch endpoints pointing to the fake servers
+func (s *Server) Endpoints() twitch.Endpoints {
+	return twitch.Endpoints{
+		Chat:              s.IRC.Addr(),
+		ChatTLSConfig:     s.IRC.TLSConfig(),
+		EventSubWebsocket: s.EventSub.URL(),
+		Helix:             s.Helix.URL(),
+		OAuthToken:        s.Helix.TokenURL(),
+	}
+}
+
+// Install points the twitch package to the fake servers and loads valid tokens.
+// callbackBase is the websub callback base, like "http://127.0.0.1:7002"
+func (s *Server) Install(callbackBase string) {
+	twitch.SetEndpoints(s.Endpoints())
+
+	c := config.GetConfig()
+	c.TwitchOAuthClient = ClientId
+	c.TwitchOAuthSecret = ClientSecret
+	c.TwitchCallbackBase = callbackBase
+	if c.TwitchCallSecret == "" {
+		c.TwitchCallSecret = "twitchtest-webhook-secret"
+	}
+	config.SetConfig(c)
+
+	twitch.SetToken(&oauth2.Token{
+		AccessToken:  s.UserToken,
+		RefreshToken: uuid.New().String(),
+		TokenType:    "bearer",
+		Expiry:       time.Now().Add(time.Hour),
+	})
+	twitch.SetAppToken(&oauth2.Token{
+		AccessToken: s.AppToken,
+		TokenType:   "bearer",
+		Expiry:      time.Now().Add(time.Hour),
+	})
+}
+
+// Close stops all servers and restores the default twitch endpoints
+func (s *Server) Close() {
+	s.IRC.Close()
+	s.EventSub.Close()
+	s.Helix.Close()
+	twitch.SetEndpoints(twitch.DefaultEndpoints())
+}
+
+// RevokeTokens invalidates every issued token. Clients need to refresh to keep working
+func (s *Server) RevokeTokens() {
+	s.tokens.revokeAll()
+}
+
+// Channel is the broadcaster user
+func (s *Server) Channel() User {
+	return s.users.channel
+}
+
+// User returns the user with login, creating it if needed
+func (s *Server) User(login string) User {
+	return s.users.get(login)
+}
+
+// SetUser adds or replaces a user, to customize display name or badges
+func (s *Server) SetUser(u User) {
+	s.users.set(u)
+}
+
+func (s *Server) userEvent(u User) map[string]interface{} {
+	channel := s.Channel()
+	return map[string]interface{}{
+		"user_id":                u.Id,
+		"user_login":             u.Login,
+		"user_name":              u.DisplayName,
+		"broadcaster_user_id":    channel.Id,
+		"broadcaster_user_login": channel.Login,
+		"broadcaster_user_name":  channel.DisplayName,
+	}
+}
+
+func tierFromPlan(plan string) string {
+	switch plan {
+	case "", "Prime":
+		return "1000"
+	}
+	return plan
+}
+
+// Chat sends a chat message from login
+func (s *Server) Chat(login, text string) {
+	s.IRC.Message(s.User(login), text)
+}
+
+// Follow sends a channel.follow webhook notification. The user is also listed by /helix/channels/followers
+func (s *Server) Follow(login string) error {
+	u := s.User(login)
+	s.Helix.AddFollower(u)
+	e := s.userEvent(u)
+	e["followed_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	return s.Helix.NotifyWebhook("channel.follow", e)
+}
+
+// Subscribe sends a channel.subscribe notification. plan is the tier, like "1000" or "Prime"
+func (s *Server) Subscribe(login, plan string) error {
+	e := s.userEvent(s.User(login))
+	e["tier"] = tierFromPlan(plan)
+	e["is_gift"] = false
+	return s.EventSub.Notify("channel.subscribe", e)
+}
+
+// Resub sends a channel.subscription.message notification
+func (s *Server) Resub(login, plan string, months int, message string) error {
+	e := s.userEvent(s.User(login))
+	e["tier"] = tierFromPlan(plan)
+	e["cumulative_months"] = months
+	e["streak_months"] = months
+	e["duration_months"] = 1
+	e["message"] = map[string]interface{}{
+		"text":   message,
+		"emotes": []interface{}{},
+	}
+	return s.EventSub.Notify("channel.subscription.message", e)
+}
+
+// GiftSubs sends a channel.subscription.gift notification. An empty gifter is anonymous
+func (s *Server) GiftSubs(gifter, plan string, total int) error {
+	var e map[string]interface{}
+	if gifter == "" {
+		e = s.userEvent(User{})
+		e["is_anonymous"] = true
+	} else {
+		e = s.userEvent(s.User(gifter))
+		e["is_anonymous"] = false
+	}
+	e["tier"] = tierFromPlan(plan)
+	e["total"] = total
+	return s.EventSub.Notify("channel.subscription.gift", e)
+}
+
+// Cheer sends a channel.cheer notification
+func (s *Server) Cheer(login string, bits int, message string) error {
+	e := s.userEvent(s.User(login))
+	e["is_anonymous"] = false
+	e["bits"] = bits
+	e["message"] = message
+	return s.EventSub.Notify("channel.cheer", e)
+}
+
+// Redeem sends a channel points redemption notification for a reward with title.
+// A custom reward with that title, created with AddReward or by the bot, is used if there is one
+func (s *Server) Redeem(login, title string, cost int, input string) error {
+	e := s.userEvent(s.User(login))
+	e["id"] = uuid.New().String()
+	e["user_input"] = input
+	e["status"] = "unfulfilled"
+	e["redeemed_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+	e["reward"] = map[string]interface{}{
+		"id":     "reward-" + strings.ToLower(strings.Replace(title, " ", "-", -1)),
+		"title":  title,
+		"cost":   cost,
+		"prompt": "",
+	}
+	if r, ok := s.Helix.rewardByTitle(title); ok {
+		e["reward"] = map[string]interface{}{
+			"id":     r.Id,
+			"title":  r.Title,
+			"cost":   r.Cost,
+			"prompt": r.Prompt,
+		}
+	}
+	return s.EventSub.Notify("channel.channel_points_custom_reward_redemption.add", e)
+}
+
+// Raid sends a raid USERNOTICE on chat
+func (s *Server) Raid(login string, viewers int) {
+	s.IRC.Raid(s.User(login), viewers)
+}
+
+// Clip adds a clip created at createdAt by login
+func (s *Server) Clip(login, title string, createdAt time.Time) Clip {
+	u := s.User(login)
+	c := Clip{
+		Id:        uuid.New().String(),
+		Title:     title,
+		CreatorId: u.Id,
+		CreatedAt: createdAt.UTC(),
+	}
+	c.Url = "https://clips.twitch.tv/" + c.Id
+	s.Helix.AddClip(c)
+	return c
+}
+
+// StreamOnline sends a stream.online webhook notification
+func (s *Server) StreamOnline() error {
+	channel := s.Channel()
+	e := s.userEvent(channel)
+	e["id"] = strconv.FormatInt(time.Now().Unix(), 10)
+	e["type"] = "live"
+	startedAt := time.Now().UTC()
+	e["started_at"] = startedAt.Format(time.RFC3339Nano)
+	s.Helix.SetLive(startedAt)
+	return s.Helix.NotifyWebhook("stream.online", e)
+}
+
+// StreamOffline sends a stream.offline webhook notification
+func (s *Server) StreamOffline() error {
+	s.Helix.SetLive(time.Time{})
+	return s.Helix.NotifyWebhook("stream.offline", s.userEvent(s.Channel()))
+}
+
+// ChannelUpdate sends a channel.update webhook notification
+func (s *Server) ChannelUpdate(title, categoryId, categoryName string) error {
+	e := s.userEvent(s.Channel())
+	e["title"] = title
+	e["language"] = "en"
+	e["category_id"] = categoryId
+	e["category_name"] = categoryName
+	e["is_mature"] = false
+	s.Helix.SetChannel(title, categoryId, categoryName)
+	return s.Helix.NotifyWebhook("channel.update", e)
+}
+
+// WaitSubscriptions waits until the EventSub sessions have at least n subscriptions
+func (s *Server) WaitSubscriptions(n int, timeout time.Duration) error {
+	return waitFor(timeout, func() bool {
+		return len(s.EventSub.subscriptions()) >= n
+	}, fmt.Sprintf("%d eventsub subscriptions", n))
+}
+
+// WaitWebhooks waits until at least n webhooks were verified
+func (s *Server) WaitWebhooks(n int, timeout time.Duration) error {
+	return waitFor(timeout, func() bool {
+		return len(s.Helix.Webhooks()) >= n
+	}, fmt.Sprintf("%d webhooks", n))
+}
+
+// WaitChat waits until a chat client joined the channel
+func (s *Server) WaitChat(timeout time.Duration) error {
+	return waitFor(timeout, func() bool {
+		return s.IRC.Connected() > 0
+	}, "chat join")
+}
+
+func waitFor(timeout time.Duration, cond func() bool, what string) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return nil
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	return fmt.Errorf("timeout waiting %s", what)
+}
@@ -0,0 +1,14 @@
+package twitchtest
+
+import "testing"
+
+// startServer starts the fakes for the channel "racerxdl" and points the twitch package to them
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer("racerxdl")
+	if err != nil {
+		t.Fatalf("NewServer: %s", err)
+	}
+	s.Install("http://127.0.0.1:7002")
+	return s
+}
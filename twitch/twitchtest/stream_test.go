@@ -0,0 +1,38 @@
+package twitchtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/racerxdl/twitchled/twitch"
+)
+
+func TestGetStreamInfo(t *testing.T) {
+	s := startServer(t)
+	defer s.Close()
+
+	s.Helix.SetChannel("Coding a bot", "1469308723", "Software and Game Development")
+
+	info, err := twitch.GetStreamInfo(s.Channel().Id)
+	if err != nil {
+		t.Fatalf("GetStreamInfo: %s", err)
+	}
+	if info.Online || info.Title != "Coding a bot" || info.Game != "Software and Game Development" {
+		t.Errorf("offline GetStreamInfo = %+v", info)
+	}
+
+	startedAt := time.Now().Add(-90 * time.Minute).Truncate(time.Second)
+	s.Helix.SetLive(startedAt)
+
+	info, err = twitch.GetStreamInfo(s.Channel().Id)
+	if err != nil {
+		t.Fatalf("GetStreamInfo: %s", err)
+	}
+	if !info.Online || !info.StartedAt.Equal(startedAt) || info.Game != "Software and Game Development" {
+		t.Errorf("live GetStreamInfo = %+v, want started at %s", info, startedAt)
+	}
+
+	if _, err := twitch.GetStreamInfo("404"); err == nil {
+		t.Errorf("GetStreamInfo of an unknown channel did not fail")
+	}
+}
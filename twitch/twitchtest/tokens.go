@@ -0,0 +1,43 @@
+package twitchtest
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// tokenStore keeps the access tokens accepted by the fake servers
+type tokenStore struct {
+	sync.Mutex
+	valid map[string]bool
+}
+
+func makeTokenStore() *tokenStore {
+	return &tokenStore{
+		valid: map[string]bool{},
+	}
+}
+
+func (t *tokenStore) issue() string {
+	t.Lock()
+	defer t.Unlock()
+
+	token := uuid.New().String()
+	t.valid[token] = true
+
+	return token
+}
+
+func (t *tokenStore) isValid(token string) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.valid[token]
+}
+
+func (t *tokenStore) revokeAll() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.valid = map[string]bool{}
+}
@@ -0,0 +1,91 @@
+package twitchtest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/irc.v3"
+)
+
+// User is a fake twitch user
+type User struct {
+	Id          string
+	Login       string
+	DisplayName string
+	// Badges is the IRC badges tag, like "moderator/1,subscriber/12"
+	Badges string
+}
+
+func (u User) prefix() *irc.Prefix {
+	return &irc.Prefix{Name: u.Login, User: u.Login, Host: u.Login + "." + ircHost}
+}
+
+func (u User) helix() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                u.Id,
+		"login":             u.Login,
+		"display_name":      u.DisplayName,
+		"type":              "",
+		"broadcaster_type":  "",
+		"description":       "",
+		"profile_image_url": "https://static-cdn.jtvnw.net/jtv_user_pictures/" + u.Login + "-profile_image-300x300.png",
+		"created_at":        "2020-01-01T00:00:00Z",
+	}
+}
+
+// userList creates users on demand with sequential ids
+type userList struct {
+	sync.Mutex
+	channel User
+	users   map[string]User
+	nextId  int
+}
+
+func makeUserList(channel User) *userList {
+	return &userList{
+		channel: channel,
+		users:   map[string]User{channel.Login: channel},
+		nextId:  1000,
+	}
+}
+
+func (l *userList) get(login string) User {
+	login = strings.ToLower(login)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if u, ok := l.users[login]; ok {
+		return u
+	}
+
+	l.nextId++
+	u := User{
+		Id:          strconv.Itoa(l.nextId),
+		Login:       login,
+		DisplayName: login,
+	}
+	l.users[login] = u
+
+	return u
+}
+
+func (l *userList) set(u User) {
+	l.Lock()
+	defer l.Unlock()
+	l.users[u.Login] = u
+}
+
+func (l *userList) byId(id string) (User, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, u := range l.users {
+		if u.Id == id {
+			return u, true
+		}
+	}
+
+	return User{}, false
+}
@@ -0,0 +1,182 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+)
+
+// USERNOTICE msg-id values
+const (
+	UserNoticeSub             = "sub"
+	UserNoticeResub           = "resub"
+	UserNoticeSubGift         = "subgift"
+	UserNoticeAnonSubGift     = "anonsubgift"
+	UserNoticeSubMysteryGift  = "submysterygift"
+	UserNoticeGiftPaidUpgrade = "giftpaidupgrade"
+	UserNoticeRaid            = "raid"
+	UserNoticeUnraid          = "unraid"
+	UserNoticeRitual          = "ritual"
+	UserNoticeBitsBadgeTier   = "bitsbadgetier"
+	UserNoticeAnnouncement    = "announcement"
+)
+
+// UserNoticeEventData is a USERNOTICE message: subs, resubs, gift subs, raids, rituals and announcements
+type UserNoticeEventData struct {
+	Channel   string
+	Message   string
+	Tags      map[string]string
+	Badges    map[string]string
+	timestamp time.Time
+}
+
+func (e *UserNoticeEventData) GetType() EventType {
+	return EventUserNotice
+}
+
+func (e *UserNoticeEventData) GetData() interface{} {
+	return e
+}
+
+// MsgId returns the kind of notice. See UserNotice constants
+func (e *UserNoticeEventData) MsgId() string {
+	return e.Tags["msg-id"]
+}
+
+func (e *UserNoticeEventData) UserId() string {
+	return e.Tags["user-id"]
+}
+
+func (e *UserNoticeEventData) Login() string {
+	return e.Tags["login"]
+}
+
+func (e *UserNoticeEventData) DisplayName() string {
+	if n := e.Tags["display-name"]; n != "" {
+		return n
+	}
+	return e.Login()
+}
+
+// SystemMessage returns the message twitch shows in chat for the notice
+func (e *UserNoticeEventData) SystemMessage() string {
+	return e.Tags["system-msg"]
+}
+
+func (e *UserNoticeEventData) Emotes() []twitchdata.Emote {
+	return parseEmotes(e.Tags["emotes"])
+}
+
+func (e *UserNoticeEventData) CumulativeMonths() int {
+	return tagInt(e.Tags, "msg-param-cumulative-months")
+}
+
+func (e *UserNoticeEventData) StreakMonths() int {
+	return tagInt(e.Tags, "msg-param-streak-months")
+}
+
+// SubPlan returns Prime, 1000, 2000 or 3000
+func (e *UserNoticeEventData) SubPlan() string {
+	return e.Tags["msg-param-sub-plan"]
+}
+
+func (e *UserNoticeEventData) SubPlanName() string {
+	return e.Tags["msg-param-sub-plan-name"]
+}
+
+func (e *UserNoticeEventData) RecipientId() string {
+	return e.Tags["msg-param-recipient-id"]
+}
+
+func (e *UserNoticeEventData) RecipientLogin() string {
+	return e.Tags["msg-param-recipient-user-name"]
+}
+
+func (e *UserNoticeEventData) RecipientDisplayName() string {
+	return e.Tags["msg-param-recipient-display-name"]
+}
+
+// GiftCount returns the number of subs gifted in a submysterygift
+func (e *UserNoticeEventData) GiftCount() int {
+	return tagInt(e.Tags, "msg-param-mass-gift-count")
+}
+
+// RaidViewers returns the number of viewers that came with the raid
+func (e *UserNoticeEventData) RaidViewers() int {
+	return tagInt(e.Tags, "msg-param-viewerCount")
+}
+
+func (e *UserNoticeEventData) IsSub() bool {
+	switch e.MsgId() {
+	case UserNoticeSub, UserNoticeResub, UserNoticeSubGift, UserNoticeAnonSubGift:
+		return true
+	}
+	return false
+}
+
+func (e *UserNoticeEventData) IsRaid() bool {
+	return e.MsgId() == UserNoticeRaid
+}
+
+// AsSubscribeEvent converts a sub, resub or gift sub notice to the same event received from the Monitor
+func (e *UserNoticeEventData) AsSubscribeEvent() *SubscribeEventData {
+	data := twitchdata.ChannelSubscribeMessageData{
+		UserName:         e.Login(),
+		DisplayName:      e.DisplayName(),
+		UserId:           e.UserId(),
+		ChannelName:      e.Channel,
+		ChannelId:        e.Tags["room-id"],
+		SubPlan:          e.SubPlan(),
+		SubPlanName:      e.SubPlanName(),
+		CumulativeMonths: e.CumulativeMonths(),
+		StreakMonths:     e.StreakMonths(),
+		Context:          e.MsgId(),
+		Time:             tagTime(e.Tags, "tmi-sent-ts"),
+		SubMessage: twitchdata.ChannelSubscriberMessage{
+			Message: e.Message,
+			Emotes:  e.Emotes(),
+		},
+	}
+
+	switch e.MsgId() {
+	case UserNoticeSubGift, UserNoticeAnonSubGift:
+		data.IsGift = true
+		data.RecipientId = e.RecipientId()
+		data.RecipientUserName = e.RecipientLogin()
+		data.RecipientDisplayName = e.RecipientDisplayName()
+		data.CumulativeMonths = tagInt(e.Tags, "msg-param-months")
+	}
+
+	return MakeSubscribeEventData(data.ChannelId, data).(*SubscribeEventData)
+}
+
+func (e *UserNoticeEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"msg_id":    e.MsgId(),
+		"message":   e.Message,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *UserNoticeEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *UserNoticeEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeUserNoticeEventData(channel, message string, tags map[string]string) ChatEvent {
+	return &UserNoticeEventData{
+		Channel:   channel,
+		Message:   message,
+		Tags:      tags,
+		Badges:    parseBadges(tags["badges"]),
+		timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,77 @@
+package twitch
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// UserStateEventData is a USERSTATE or GLOBALUSERSTATE message describing the bot user
+type UserStateEventData struct {
+	Channel   string
+	Global    bool
+	Tags      map[string]string
+	Badges    map[string]string
+	timestamp time.Time
+}
+
+func (e *UserStateEventData) GetType() EventType {
+	return EventUserState
+}
+
+func (e *UserStateEventData) GetData() interface{} {
+	return e
+}
+
+func (e *UserStateEventData) DisplayName() string {
+	return e.Tags["display-name"]
+}
+
+func (e *UserStateEventData) Color() string {
+	return e.Tags["color"]
+}
+
+func (e *UserStateEventData) IsModerator() bool {
+	return tagBool(e.Tags, "mod")
+}
+
+func (e *UserStateEventData) IsBroadcaster() bool {
+	_, ok := e.Badges["broadcaster"]
+	return ok
+}
+
+func (e *UserStateEventData) EmoteSets() []string {
+	if e.Tags["emote-sets"] == "" {
+		return nil
+	}
+	return strings.Split(e.Tags["emote-sets"], ",")
+}
+
+func (e *UserStateEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"channel":   e.Channel,
+		"global":    e.Global,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *UserStateEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *UserStateEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeUserStateEventData(channel string, global bool, tags map[string]string) ChatEvent {
+	return &UserStateEventData{
+		Channel:   channel,
+		Global:    global,
+		Tags:      tags,
+		Badges:    parseBadges(tags["badges"]),
+		timestamp: time.Now(),
+	}
+}
@@ -15,10 +15,14 @@ import (
 	"github.com/racerxdl/twitchled/twitch"
 )
 
-const eventSubApi = "https://api.twitch.tv/helix/eventsub/subscriptions"
+const eventSubPath = "/eventsub/subscriptions"
 
 var log = slog.Scope("WebSub")
 
+func eventSubApi() string {
+	return twitch.GetEndpoints().Helix + eventSubPath
+}
+
 type Subber interface {
 	Start(addr string) error
 	GetEvents() chan twitch.ChatEvent
@@ -73,7 +77,7 @@ func (s *subber) RegisterStreamStatus(channelId string) {
 }
 
 func (s *subber) ClearWebhooks() {
-	req, _ := http.NewRequest("GET", eventSubApi, nil)
+	req, _ := http.NewRequest("GET", eventSubApi(), nil)
 
 	req.Header.Add("Client-ID", config.GetConfig().TwitchOAuthClient)
 	req.Header.Add("Content-Type", "application/json")
@@ -102,7 +106,7 @@ func (s *subber) ClearWebhooks() {
 
 func (s *subber) deleteWebhook(webhookId string) {
 	log.Info("Removing webhook %s", webhookId)
-	req, _ := http.NewRequest("DELETE", eventSubApi+"?id="+webhookId, nil)
+	req, _ := http.NewRequest("DELETE", eventSubApi()+"?id="+webhookId, nil)
 
 	req.Header.Add("Client-ID", config.GetConfig().TwitchOAuthClient)
 	req.Header.Add("Content-Type", "application/json")
@@ -150,7 +154,7 @@ func (s *subber) registerWebhook(channelId, eventType, version string) {
 
 	jsonData, _ := json.Marshal(payload)
 
-	req, _ := http.NewRequest("POST", eventSubApi, bytes.NewReader(jsonData))
+	req, _ := http.NewRequest("POST", eventSubApi(), bytes.NewReader(jsonData))
 
 	req.Header.Add("Client-ID", config.GetConfig().TwitchOAuthClient)
 	req.Header.Add("Content-Type", "application/json")
@@ -0,0 +1,54 @@
+package twitch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WhisperEventData is a WHISPER message sent directly to the bot
+type WhisperEventData struct {
+	From      string
+	Message   string
+	Tags      map[string]string
+	timestamp time.Time
+}
+
+func (e *WhisperEventData) GetType() EventType {
+	return EventWhisper
+}
+
+func (e *WhisperEventData) GetData() interface{} {
+	return e
+}
+
+func (e *WhisperEventData) UserId() string {
+	return e.Tags["user-id"]
+}
+
+func (e *WhisperEventData) AsMap() map[string]interface{} {
+	return map[string]interface{}{
+		"type":      e.GetType(),
+		"from":      e.From,
+		"message":   e.Message,
+		"tags":      e.Tags,
+		"timestamp": e.timestamp.Format(time.RFC3339),
+	}
+}
+
+func (e *WhisperEventData) AsJson() string {
+	s, _ := json.Marshal(e.AsMap())
+	return string(s)
+}
+
+func (e *WhisperEventData) Timestamp() time.Time {
+	return e.timestamp
+}
+
+func MakeWhisperEventData(from, message string, tags map[string]string) ChatEvent {
+	return &WhisperEventData{
+		From:      from,
+		Message:   message,
+		Tags:      tags,
+		timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,89 @@
+package wimatrix
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of the effects, so sequences can be played with a fake time
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RealClock is the system clock
+var RealClock Clock = realClock{}
+
+// FakeClock is a Clock that only moves on Advance
+type FakeClock struct {
+	sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	w := fakeWaiter{
+		at: c.now.Add(d),
+		c:  make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		w.c <- c.now
+		return w.c
+	}
+
+	c.waiters = append(c.waiters, w)
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].at.Before(c.waiters[j].at)
+	})
+
+	return w.c
+}
+
+// Advance moves the clock forward by d, firing the waiters that expired
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+
+	for len(c.waiters) > 0 && !c.waiters[0].at.After(c.now) {
+		c.waiters[0].c <- c.now
+		c.waiters = c.waiters[1:]
+	}
+}
+
+// Waiters returns how many After calls are waiting, so a test can wait for a player to sleep
+func (c *FakeClock) Waiters() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.waiters)
+}
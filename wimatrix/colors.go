@@ -0,0 +1,40 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses a color name like "teal" or a hex value like "#FF0000"
+func ParseColor(s string) (color.Color, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return color.Black, fmt.Errorf("invalid color")
+	}
+
+	if s[0] == '#' { // Hex color
+		ci, err := strconv.ParseInt(s[1:], 16, 32)
+		if err != nil || len(s) != 7 {
+			return color.Black, fmt.Errorf("invalid color")
+		}
+
+		c := color.RGBA{
+			R: uint8((ci & 0xFF0000) >> 16),
+			G: uint8((ci & 0x00FF00) >> 8),
+			B: uint8((ci & 0x0000FF) >> 0),
+			A: 255,
+		}
+
+		return c, nil
+	}
+
+	if c, ok := colornames.Map[s]; ok {
+		return c, nil
+	}
+
+	return color.Black, fmt.Errorf("invalid color")
+}
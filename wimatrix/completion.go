@@ -0,0 +1,75 @@
+package wimatrix
+
+import (
+	"fmt"
+	"sync"
+)
+
+// AlertResult is how an alert left the device
+type AlertResult int
+
+const (
+	// AlertShown means the alert was sent to an online panel
+	AlertShown AlertResult = iota
+	// AlertExpired means the alert waited in the queue for too long
+	AlertExpired
+	// AlertOffline means the panel was offline when the alert was due
+	AlertOffline
+	// AlertDropped means the device stopped before showing the alert
+	AlertDropped
+)
+
+func (r AlertResult) String() string {
+	switch r {
+	case AlertShown:
+		return "shown"
+	case AlertExpired:
+		return "expired"
+	case AlertOffline:
+		return "offline"
+	case AlertDropped:
+		return "dropped"
+	}
+	return fmt.Sprintf("AlertResult(%d)", int(r))
+}
+
+// AlertDone is called once per device, when the alert is shown or leaves the device without being shown.
+// It is called with the device lock held and must not block
+type AlertDone func(result AlertResult)
+
+// TrackAlert returns an AlertDone for an alert sent to the given number of devices. done runs once,
+// in background: with true when the first device shows the alert, or false when none of them could
+func TrackAlert(devices int, done func(shown bool)) AlertDone {
+	if devices <= 0 {
+		go done(false)
+		return func(AlertResult) {}
+	}
+
+	var lock sync.Mutex
+	pending := devices
+	called := false
+
+	return func(result AlertResult) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		pending--
+		if called {
+			return
+		}
+		if result == AlertShown || pending == 0 {
+			called = true
+			go done(result == AlertShown)
+		}
+	}
+}
+
+// finish reports the result of the alert once. Called with the device lock held
+func (a *alert) finish(result AlertResult) {
+	if a.done == nil {
+		return
+	}
+	log.Debug("Alert %d %s", a.event.GetType(), result)
+	a.done(result)
+	a.done = nil
+}
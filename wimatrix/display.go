@@ -0,0 +1,123 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Display drivers
+const (
+	DisplayMQTT     = "mqtt"
+	DisplayWLED     = "wled"
+	DisplayTerminal = "terminal"
+	DisplayRecorder = "recorder"
+)
+
+// Display is an output able to show the WiMatrix alerts
+type Display interface {
+	// Message shows a message with the specified text color
+	Message(text string, textColor color.Color) error
+	SetTextColor(c color.Color) error
+	SetBGColor(c color.Color) error
+	SetBrightness(brightness float32) error
+	SetBGBrightness(brightness float32) error
+	SetMode(mode Mode) error
+	SetSpeed(speed int) error
+	Close() error
+}
+
+// StatusReporter is implemented by displays that know if the panel is online
+type StatusReporter interface {
+	Online() bool
+	// OnStatus registers cb to be called when the panel goes online or offline.
+	// A reboot of the panel is reported as online again
+	OnStatus(cb func(online bool))
+}
+
+// FrameDisplay is implemented by displays that can show raw pixel frames on ModePixels
+type FrameDisplay interface {
+	// Size is the panel resolution
+	Size() (width, height int)
+	ShowFrame(img *image.RGBA) error
+}
+
+// DisplayConfig selects and configures a display driver
+type DisplayConfig struct {
+	// Driver is one of DisplayMQTT, DisplayWLED, DisplayTerminal or DisplayRecorder
+	Driver string
+	// Name is the MQTT topic prefix of the device
+	Name string
+
+	// MQTT broker
+	MQTTHost string
+	MQTTUser string
+	MQTTPass string
+
+	// WLEDHost is the address of the WLED controller, like "192.168.0.50"
+	WLEDHost string
+
+	// Panel resolution, DefaultWidth x DefaultHeight if not set
+	Width  int
+	Height int
+}
+
+// MakeDisplay creates the display selected by cfg. If the driver cannot be started
+// the terminal simulator is returned with the error, so alerts keep working without the panel.
+// An MQTT display is returned offline while its broker is down, and connects when it comes up
+func MakeDisplay(cfg DisplayConfig) (Display, error) {
+	var display Display
+	var err error
+
+	switch strings.ToLower(cfg.Driver) {
+	case "", DisplayMQTT:
+		display, err = MakeMQTTDisplay(cfg.Name, cfg.MQTTHost, cfg.MQTTUser, cfg.MQTTPass)
+	case DisplayWLED:
+		display, err = MakeWLEDDisplay(cfg.WLEDHost)
+	case DisplayTerminal:
+		display = MakeTerminalDisplay(nil)
+	case DisplayRecorder:
+		display = MakeRecordingDisplay()
+	default:
+		err = fmt.Errorf("unknown display driver %q", cfg.Driver)
+	}
+
+	if err != nil {
+		display = MakeTerminalDisplay(nil)
+	}
+
+	if sized, ok := display.(interface{ SetSize(w, h int) }); ok && cfg.Width > 0 && cfg.Height > 0 {
+		sized.SetSize(cfg.Width, cfg.Height)
+	}
+
+	return display, err
+}
+
+func clampBrightness(brightness float32) float32 {
+	if brightness < 0 {
+		return 0
+	}
+	if brightness > 1 {
+		return 1
+	}
+	return brightness
+}
+
+// packRGB returns the pixels of img as RGB888, row by row
+func packRGB(img *image.RGBA) []byte {
+	b := img.Bounds()
+	data := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			data = append(data, img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		}
+	}
+	return data
+}
+
+func rgb(c color.Color) (r, g, b uint8) {
+	cr, cg, cb, _ := c.RGBA()
+	return uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8)
+}
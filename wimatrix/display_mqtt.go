@@ -0,0 +1,354 @@
+package wimatrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang"
+)
+
+// PanelReport is the state reported by the panel firmware on the state topic
+type PanelReport struct {
+	Mode       Mode    `json:"mode"`
+	Message    string  `json:"msg"`
+	Brightness float32 `json:"brightness"`
+	// Uptime in seconds. If it goes back the panel rebooted
+	Uptime int64 `json:"uptime"`
+}
+
+// panelStatus is the last status reported by the panel
+type panelStatus int
+
+const (
+	// statusUnknown is the status before the first status or state message. The panel is treated as online
+	statusUnknown panelStatus = iota
+	statusOnline
+	statusOffline
+)
+
+// MQTTDisplay drives the WiMatrix firmware through MQTT topics prefixed by the device name
+type MQTTDisplay struct {
+	sync.Mutex
+	name   string
+	host   string
+	mq     mqtt.Client
+	width  int
+	height int
+	stop   chan struct{}
+
+	status    panelStatus
+	reported  PanelReport
+	hasReport bool
+	callbacks []func(online bool)
+}
+
+const (
+	// mqttConnectTimeout is how long a connection attempt to the broker waits
+	mqttConnectTimeout = time.Second * 5
+	// mqttRetryMin and mqttRetryMax limit the delay between connection attempts at startup
+	mqttRetryMin = time.Second
+	mqttRetryMax = time.Minute
+)
+
+// MakeMQTTDisplay connects to the MQTT broker at host. If the broker is down the
+// display is returned offline and keeps connecting in background
+func MakeMQTTDisplay(name, host, user, pass string) (*MQTTDisplay, error) {
+	if host == "" {
+		return nil, fmt.Errorf("no MQTT host for %s", name)
+	}
+
+	d := &MQTTDisplay{
+		name:   name,
+		host:   host,
+		width:  DefaultWidth,
+		height: DefaultHeight,
+		stop:   make(chan struct{}),
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(fmt.Sprintf("tcp://%s:1883", host))
+	opts.SetUsername(user)
+	opts.SetPassword(pass)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectTimeout(mqttConnectTimeout)
+	// Subscriptions are lost when the broker connection drops
+	opts.SetOnConnectHandler(func(mqtt.Client) {
+		d.subscribe()
+	})
+	// The panel is unreachable until the status is received again after reconnecting
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Warn("Lost connection to MQTT at %s: %s", host, err)
+		d.setOnline(false, false)
+	})
+
+	d.mq = mqtt.NewClient(opts)
+	log.Debug("Connecting to MQTT at %s", host)
+	if err := d.connect(); err != nil {
+		log.Warn("%s. Retrying in background", err)
+		d.setOnline(false, false)
+		go d.retryConnect()
+	}
+
+	return d, nil
+}
+
+func (d *MQTTDisplay) connect() error {
+	tkn := d.mq.Connect()
+
+	// WaitTimeout holds the token lock, so a failed connection is only seen after the timeout
+	done := make(chan struct{})
+	go func() {
+		tkn.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(mqttConnectTimeout):
+		return fmt.Errorf("timeout connecting to MQTT at %s", d.host)
+	}
+	if tkn.Error() != nil {
+		return fmt.Errorf("cannot connect to MQTT at %s: %s", d.host, tkn.Error())
+	}
+	return nil
+}
+
+// retryConnect tries to connect until it works or the display is closed.
+// Once connected, the client reconnects by itself
+func (d *MQTTDisplay) retryConnect() {
+	delay := mqttRetryMin
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(delay):
+		}
+
+		err := d.connect()
+		if err == nil {
+			select {
+			case <-d.stop:
+				// Closed while connecting
+				d.mq.Disconnect(250)
+			default:
+				log.Info("Connected to MQTT at %s", d.host)
+			}
+			return
+		}
+
+		delay *= 2
+		if delay > mqttRetryMax {
+			delay = mqttRetryMax
+		}
+		log.Debug("%s. Retrying in %s", err, delay)
+	}
+}
+
+// MakeMQTTDisplayFromClient uses an already connected MQTT client
+func MakeMQTTDisplayFromClient(name string, mq mqtt.Client) *MQTTDisplay {
+	d := &MQTTDisplay{
+		name:   name,
+		mq:     mq,
+		width:  DefaultWidth,
+		height: DefaultHeight,
+	}
+	d.subscribe()
+	return d
+}
+
+func (d *MQTTDisplay) subscribe() {
+	log.Debug("Subscribing to %s status", d.name)
+	d.mq.Subscribe(d.name+MQTTWiMatrixStatus, 1, d.onStatusMessage)
+	d.mq.Subscribe(d.name+MQTTWiMatrixState, 0, d.onStateMessage)
+}
+
+func (d *MQTTDisplay) onStatusMessage(_ mqtt.Client, msg mqtt.Message) {
+	online := strings.EqualFold(strings.TrimSpace(string(msg.Payload())), "online")
+	d.setOnline(online, false)
+}
+
+func (d *MQTTDisplay) onStateMessage(_ mqtt.Client, msg mqtt.Message) {
+	report := PanelReport{}
+	if err := json.Unmarshal(msg.Payload(), &report); err != nil {
+		log.Error("Invalid state from %s: %s", d.name, err)
+		return
+	}
+
+	d.Lock()
+	rebooted := d.hasReport && report.Uptime < d.reported.Uptime
+	d.reported = report
+	d.hasReport = true
+	d.Unlock()
+
+	// A state report also means the panel is alive
+	d.setOnline(true, rebooted)
+}
+
+// setOnline updates the status and calls the callbacks if it changed or the panel rebooted.
+// The first online status is not a change, since an unknown panel is already treated as online
+func (d *MQTTDisplay) setOnline(online, rebooted bool) {
+	status := statusOffline
+	if online {
+		status = statusOnline
+	}
+
+	d.Lock()
+	first := d.status == statusUnknown
+	wasOnline := d.status != statusOffline
+	changed := wasOnline != online || rebooted
+	d.status = status
+	callbacks := append([]func(bool){}, d.callbacks...)
+	d.Unlock()
+
+	if !changed {
+		if first {
+			log.Info("Panel %s is online", d.name)
+		}
+		return
+	}
+
+	if rebooted {
+		log.Warn("Panel %s rebooted", d.name)
+	} else if online {
+		log.Info("Panel %s is online", d.name)
+	} else {
+		log.Warn("Panel %s is offline", d.name)
+	}
+
+	// Callbacks publish to MQTT, which must not happen inside a paho message handler
+	for _, cb := range callbacks {
+		go cb(online)
+	}
+}
+
+// Online returns false only after the panel reported offline, or its LWT was received
+func (d *MQTTDisplay) Online() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.status != statusOffline
+}
+
+func (d *MQTTDisplay) OnStatus(cb func(online bool)) {
+	d.Lock()
+	defer d.Unlock()
+	d.callbacks = append(d.callbacks, cb)
+}
+
+// Reported returns the last state reported by the panel
+func (d *MQTTDisplay) Reported() (PanelReport, bool) {
+	d.Lock()
+	defer d.Unlock()
+	return d.reported, d.hasReport
+}
+
+func (d *MQTTDisplay) publishMQ(topic string, data []byte) error {
+	log.Debug("Sending to %s: %s", topic, string(data))
+	return d.publish(topic, data)
+}
+
+// publish sends data without logging it, for binary payloads
+func (d *MQTTDisplay) publish(topic string, data []byte) error {
+	tkn := d.mq.Publish(topic, 0, false, data)
+	if !tkn.WaitTimeout(time.Second) {
+		return fmt.Errorf("timeout publishing message to %s", topic)
+	}
+	if tkn.Error() != nil {
+		return fmt.Errorf("error publishing message to %s: %s", topic, tkn.Error())
+	}
+	return nil
+}
+
+func (d *MQTTDisplay) publishColor(topic string, c color.Color) error {
+	r, g, b := rgb(c)
+
+	data := map[string]interface{}{
+		"r": r,
+		"g": g,
+		"b": b,
+	}
+
+	dataBytes, _ := json.Marshal(data)
+	return d.publishMQ(topic, dataBytes)
+}
+
+func (d *MQTTDisplay) Message(text string, textColor color.Color) error {
+	r, g, b := rgb(textColor)
+
+	data := map[string]interface{}{
+		"msg": text,
+		"r":   r,
+		"g":   g,
+		"b":   b,
+	}
+
+	dataBytes, _ := json.Marshal(data)
+
+	return d.publishMQ(d.name+MQTTWimatrixMsg, dataBytes)
+}
+
+func (d *MQTTDisplay) SetTextColor(c color.Color) error {
+	return d.publishColor(d.name+MQTTWiMatrixSetTextColor, c)
+}
+
+func (d *MQTTDisplay) SetBGColor(c color.Color) error {
+	return d.publishColor(d.name+MQTTWiMatrixSetBGColor, c)
+}
+
+func (d *MQTTDisplay) SetBrightness(brightness float32) error {
+	return d.publishMQ(d.name+MQTTWiMatrixSetBrightness, []byte(fmt.Sprintf("%f", clampBrightness(brightness))))
+}
+
+func (d *MQTTDisplay) SetBGBrightness(brightness float32) error {
+	return d.publishMQ(d.name+MQTTWiMatrixSetBGBrightness, []byte(fmt.Sprintf("%f", clampBrightness(brightness))))
+}
+
+func (d *MQTTDisplay) SetMode(mode Mode) error {
+	return d.publishMQ(d.name+MQTTWiMatrixSetMode, []byte(fmt.Sprintf("%d", mode)))
+}
+
+func (d *MQTTDisplay) SetSpeed(speed int) error {
+	return d.publishMQ(d.name+MQTTWiMatrixSetSpeed, []byte(fmt.Sprintf("%d", speed)))
+}
+
+// SetSize sets the panel resolution
+func (d *MQTTDisplay) SetSize(w, h int) {
+	d.Lock()
+	defer d.Unlock()
+	d.width = w
+	d.height = h
+}
+
+func (d *MQTTDisplay) Size() (width, height int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.width, d.height
+}
+
+// ShowFrame sends img as raw RGB. The panel shows it on ModePixels
+func (d *MQTTDisplay) ShowFrame(img *image.RGBA) error {
+	w, h := d.Size()
+	if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+		img = RenderImage(img, w, h)
+	}
+	return d.publish(d.name+MQTTWiMatrixFrame, packRGB(img))
+}
+
+func (d *MQTTDisplay) Close() error {
+	d.Lock()
+	if d.stop != nil {
+		select {
+		case <-d.stop:
+		default:
+			close(d.stop)
+		}
+	}
+	d.Unlock()
+
+	d.mq.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,116 @@
+package wimatrix
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestMQTTStatusUnknown(t *testing.T) {
+	display := &MQTTDisplay{name: "test"}
+	if !display.Online() {
+		t.Fatalf("panel without status is offline")
+	}
+
+	d := MakeWiiMatrix("test", display, nil)
+	d.watchStatus()
+	if !d.State().Online {
+		t.Errorf("device of a panel without status is offline")
+	}
+
+	changes := make(chan bool, 4)
+	display.OnStatus(func(online bool) {
+		changes <- online
+	})
+
+	// The first online is not a change
+	display.setOnline(true, false)
+	display.setOnline(false, false)
+	display.setOnline(false, false)
+	display.setOnline(true, false)
+	display.setOnline(true, true)
+
+	// Callbacks run on their own goroutines, so only the count is checked
+	offline, online := 0, 0
+	for i := 0; i < 3; i++ {
+		select {
+		case got := <-changes:
+			if got {
+				online++
+			} else {
+				offline++
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting status change %d", i+1)
+		}
+	}
+	if offline != 1 || online != 2 {
+		t.Errorf("status changes: %d offline and %d online, want 1 and 2", offline, online)
+	}
+
+	select {
+	case got := <-changes:
+		t.Errorf("unexpected status change to %v", got)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestMQTTStatusOfflineFirst(t *testing.T) {
+	display := &MQTTDisplay{name: "test"}
+	changes := make(chan bool, 1)
+	display.OnStatus(func(online bool) {
+		changes <- online
+	})
+
+	// The retained LWT of a panel that is down
+	display.setOnline(false, false)
+	if display.Online() {
+		t.Errorf("panel is online after the offline status")
+	}
+
+	select {
+	case got := <-changes:
+		if got {
+			t.Errorf("status change = online, want offline")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting the offline status change")
+	}
+}
+
+func TestMakeDisplayBrokerDown(t *testing.T) {
+	display, err := MakeDisplay(DisplayConfig{Driver: DisplayMQTT, Name: "test", MQTTHost: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("MakeDisplay: %s", err)
+	}
+	defer display.Close()
+
+	mq, ok := display.(*MQTTDisplay)
+	if !ok {
+		t.Fatalf("MakeDisplay = %T, want *MQTTDisplay", display)
+	}
+	if mq.mq.IsConnected() {
+		t.Skip("an MQTT broker is running on 127.0.0.1")
+	}
+	if mq.Online() {
+		t.Errorf("panel is online without the broker")
+	}
+	if err := mq.Message("hello", color.White); err == nil {
+		t.Errorf("Message without the broker did not fail")
+	}
+}
+
+func TestMakeDisplayFallback(t *testing.T) {
+	for _, cfg := range []DisplayConfig{
+		{Driver: DisplayMQTT, Name: "test"},
+		{Driver: "hologram", Name: "test"},
+	} {
+		display, err := MakeDisplay(cfg)
+		if err == nil {
+			t.Errorf("MakeDisplay(%+v) did not fail", cfg)
+		}
+		if _, ok := display.(*TerminalDisplay); !ok {
+			t.Errorf("MakeDisplay(%+v) = %T, want the terminal simulator", cfg, display)
+		}
+	}
+}
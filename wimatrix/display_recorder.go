@@ -0,0 +1,129 @@
+package wimatrix
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"time"
+)
+
+// DisplayCall is a call received by RecordingDisplay
+type DisplayCall struct {
+	Method string
+	Args   []interface{}
+	At     time.Time
+}
+
+// RecordingDisplay records every call, to check the alert pipeline on tests
+type RecordingDisplay struct {
+	sync.Mutex
+	calls  []DisplayCall
+	width  int
+	height int
+}
+
+func MakeRecordingDisplay() *RecordingDisplay {
+	return &RecordingDisplay{
+		width:  DefaultWidth,
+		height: DefaultHeight,
+	}
+}
+
+func (d *RecordingDisplay) record(method string, args ...interface{}) error {
+	d.Lock()
+	defer d.Unlock()
+	d.calls = append(d.calls, DisplayCall{
+		Method: method,
+		Args:   args,
+		At:     time.Now(),
+	})
+	return nil
+}
+
+// Calls returns all calls recorded so far
+func (d *RecordingDisplay) Calls() []DisplayCall {
+	d.Lock()
+	defer d.Unlock()
+	return append([]DisplayCall{}, d.calls...)
+}
+
+// Messages returns the text of every Message call
+func (d *RecordingDisplay) Messages() []string {
+	var messages []string
+	for _, c := range d.Calls() {
+		if c.Method == "Message" {
+			messages = append(messages, c.Args[0].(string))
+		}
+	}
+	return messages
+}
+
+func (d *RecordingDisplay) Reset() {
+	d.Lock()
+	defer d.Unlock()
+	d.calls = nil
+}
+
+func (d *RecordingDisplay) Message(text string, textColor color.Color) error {
+	return d.record("Message", text, textColor)
+}
+
+func (d *RecordingDisplay) SetTextColor(c color.Color) error {
+	return d.record("SetTextColor", c)
+}
+
+func (d *RecordingDisplay) SetBGColor(c color.Color) error {
+	return d.record("SetBGColor", c)
+}
+
+func (d *RecordingDisplay) SetBrightness(brightness float32) error {
+	return d.record("SetBrightness", brightness)
+}
+
+func (d *RecordingDisplay) SetBGBrightness(brightness float32) error {
+	return d.record("SetBGBrightness", brightness)
+}
+
+func (d *RecordingDisplay) SetMode(mode Mode) error {
+	return d.record("SetMode", mode)
+}
+
+func (d *RecordingDisplay) SetSpeed(speed int) error {
+	return d.record("SetSpeed", speed)
+}
+
+func (d *RecordingDisplay) SetSize(w, h int) {
+	d.Lock()
+	defer d.Unlock()
+	d.width = w
+	d.height = h
+}
+
+func (d *RecordingDisplay) Size() (width, height int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.width, d.height
+}
+
+func (d *RecordingDisplay) ShowFrame(img *image.RGBA) error {
+	return d.record("ShowFrame", img)
+}
+
+// Frames returns the image of every ShowFrame call
+func (d *RecordingDisplay) Frames() []*image.RGBA {
+	var frames []*image.RGBA
+	for _, c := range d.Calls() {
+		if c.Method == "ShowFrame" {
+			frames = append(frames, c.Args[0].(*image.RGBA))
+		}
+	}
+	return frames
+}
+
+func (d *RecordingDisplay) ToggleLight() error {
+	return d.record("ToggleLight")
+}
+
+func (d *RecordingDisplay) Close() error {
+	return d.record("Close")
+}
@@ -0,0 +1,173 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/image/colornames"
+)
+
+// TerminalDisplay simulates the panel on a terminal with 24 bit ANSI colors
+type TerminalDisplay struct {
+	sync.Mutex
+	out io.Writer
+
+	mode         Mode
+	text         string
+	textColor    color.Color
+	bgColor      color.Color
+	brightness   float32
+	bgBrightness float32
+	speed        int
+	width        int
+	height       int
+}
+
+// MakeTerminalDisplay renders to out. If out is nil, stdout is used
+func MakeTerminalDisplay(out io.Writer) *TerminalDisplay {
+	if out == nil {
+		out = os.Stdout
+	}
+
+	return &TerminalDisplay{
+		out:          out,
+		mode:         ModeClock,
+		textColor:    colornames.White,
+		bgColor:      colornames.Black,
+		brightness:   1,
+		bgBrightness: 1,
+		width:        DefaultWidth,
+		height:       DefaultHeight,
+	}
+}
+
+func ansiColor(c color.Color, brightness float32, background bool) string {
+	r, g, b := rgb(c)
+	code := 38
+	if background {
+		code = 48
+	}
+	return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", code, uint8(float32(r)*brightness), uint8(float32(g)*brightness), uint8(float32(b)*brightness))
+}
+
+// render prints the current panel content. Must be called with the lock held
+func (d *TerminalDisplay) render() {
+	text := d.text
+	switch d.mode {
+	case ModeBackgroundOnly:
+		text = ""
+	case ModeClock, ModeBackgroundClock:
+		text = time.Now().Format("15:04")
+	}
+
+	var bg color.Color = colornames.Black
+	switch d.mode {
+	case ModeBackgroundOnly, ModeBackgroundStringDisplay, ModeBackgroundClock:
+		bg = d.bgColor
+	}
+
+	_, _ = fmt.Fprintf(d.out, "[WiMatrix %-30s] %s%s %-40s \x1b[0m\n", d.mode, ansiColor(bg, d.bgBrightness, true), ansiColor(d.textColor, d.brightness, false), text)
+}
+
+func (d *TerminalDisplay) Message(text string, textColor color.Color) error {
+	d.Lock()
+	defer d.Unlock()
+	d.text = text
+	d.textColor = textColor
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetTextColor(c color.Color) error {
+	d.Lock()
+	defer d.Unlock()
+	d.textColor = c
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetBGColor(c color.Color) error {
+	d.Lock()
+	defer d.Unlock()
+	d.bgColor = c
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetBrightness(brightness float32) error {
+	d.Lock()
+	defer d.Unlock()
+	d.brightness = clampBrightness(brightness)
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetBGBrightness(brightness float32) error {
+	d.Lock()
+	defer d.Unlock()
+	d.bgBrightness = clampBrightness(brightness)
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetMode(mode Mode) error {
+	d.Lock()
+	defer d.Unlock()
+	d.mode = mode
+	d.render()
+	return nil
+}
+
+func (d *TerminalDisplay) SetSpeed(speed int) error {
+	d.Lock()
+	defer d.Unlock()
+	d.speed = speed
+	return nil
+}
+
+// SetSize sets the simulated panel resolution
+func (d *TerminalDisplay) SetSize(w, h int) {
+	d.Lock()
+	defer d.Unlock()
+	d.width = w
+	d.height = h
+}
+
+func (d *TerminalDisplay) Size() (width, height int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.width, d.height
+}
+
+// ShowFrame prints img with two columns per pixel, so it keeps the aspect ratio
+func (d *TerminalDisplay) ShowFrame(img *image.RGBA) error {
+	d.Lock()
+	defer d.Unlock()
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		line := "[WiMatrix] "
+		for x := b.Min.X; x < b.Max.X; x++ {
+			line += ansiColor(img.At(x, y), d.brightness, true) + "  "
+		}
+		_, _ = fmt.Fprintln(d.out, line+"\x1b[0m")
+	}
+
+	return nil
+}
+
+func (d *TerminalDisplay) ToggleLight() error {
+	d.Lock()
+	defer d.Unlock()
+	_, _ = fmt.Fprintln(d.out, "[WiMatrix] Room light toggled")
+	return nil
+}
+
+func (d *TerminalDisplay) Close() error {
+	return nil
+}
@@ -0,0 +1,261 @@
+package wimatrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/image/colornames"
+)
+
+// WLED effect ids
+const (
+	wledEffectSolid         = 0
+	wledEffectScrollingText = 122
+)
+
+// WLED realtime UDP protocol, used for pixel frames
+const (
+	wledRealtimePort    = 21324
+	wledProtocolDNRGB   = 4
+	wledRealtimeTimeout = 2 // seconds without frames before WLED goes back to the effect
+	wledMaxLedsPerFrame = 489
+)
+
+// wledClockText is replaced by the current time on the WLED scrolling text effect
+const wledClockText = "#TIME"
+
+// WLEDDisplay drives a WLED matrix through its JSON API. Messages use the scrolling text effect
+type WLEDDisplay struct {
+	sync.Mutex
+	stateUrl string
+	client   *http.Client
+	host     string
+	udp      net.Conn
+	width    int
+	height   int
+
+	mode         Mode
+	text         string
+	textColor    color.Color
+	bgColor      color.Color
+	brightness   float32
+	bgBrightness float32
+	speed        int
+}
+
+// MakeWLEDDisplay creates a display for the WLED controller at host and checks that it answers
+func MakeWLEDDisplay(host string) (*WLEDDisplay, error) {
+	d := &WLEDDisplay{
+		stateUrl:     fmt.Sprintf("http://%s/json/state", host),
+		client:       &http.Client{Timeout: time.Second * 2},
+		host:         host,
+		width:        DefaultWidth,
+		height:       DefaultHeight,
+		mode:         ModeClock,
+		textColor:    colornames.White,
+		bgColor:      colornames.Black,
+		brightness:   1,
+		bgBrightness: 1,
+		speed:        20,
+	}
+
+	res, err := d.client.Get(d.stateUrl)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach WLED at %s: %s", host, err)
+	}
+	_ = res.Body.Close()
+
+	return d, nil
+}
+
+func wledColor(c color.Color, brightness float32) []uint8 {
+	r, g, b := rgb(c)
+	return []uint8{
+		uint8(float32(r) * brightness),
+		uint8(float32(g) * brightness),
+		uint8(float32(b) * brightness),
+	}
+}
+
+// state builds the full WLED state. It is sent on every change, so the panel converges after reboots
+func (d *WLEDDisplay) state() map[string]interface{} {
+	black := []uint8{0, 0, 0}
+	fg := wledColor(d.textColor, 1)
+	bg := black
+
+	switch d.mode {
+	case ModeBackgroundOnly, ModeBackgroundStringDisplay, ModeBackgroundClock:
+		bg = wledColor(d.bgColor, d.bgBrightness)
+	}
+
+	seg := map[string]interface{}{
+		"id":  0,
+		"on":  true,
+		"fx":  wledEffectScrollingText,
+		"col": [][]uint8{fg, bg, black},
+		// WiMatrix speed is a step delay, WLED sx is higher for faster
+		"sx": 255 - clampInt(d.speed, 0, 255),
+	}
+
+	switch d.mode {
+	case ModeBackgroundOnly:
+		seg["fx"] = wledEffectSolid
+		seg["col"] = [][]uint8{bg, black, black}
+	case ModeClock, ModeBackgroundClock:
+		seg["n"] = wledClockText
+	default:
+		seg["n"] = d.text
+	}
+
+	return map[string]interface{}{
+		"on":  true,
+		"bri": int(clampBrightness(d.brightness) * 255),
+		"seg": []interface{}{seg},
+	}
+}
+
+func (d *WLEDDisplay) push() error {
+	d.Lock()
+	data, _ := json.Marshal(d.state())
+	d.Unlock()
+
+	log.Debug("Sending to %s: %s", d.stateUrl, string(data))
+	res, err := d.client.Post(d.stateUrl, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("WLED returned (%d) %s: %s", res.StatusCode, res.Status, string(body))
+	}
+
+	return nil
+}
+
+func (d *WLEDDisplay) Message(text string, textColor color.Color) error {
+	d.Lock()
+	d.text = text
+	d.textColor = textColor
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetTextColor(c color.Color) error {
+	d.Lock()
+	d.textColor = c
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetBGColor(c color.Color) error {
+	d.Lock()
+	d.bgColor = c
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetBrightness(brightness float32) error {
+	d.Lock()
+	d.brightness = clampBrightness(brightness)
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetBGBrightness(brightness float32) error {
+	d.Lock()
+	d.bgBrightness = clampBrightness(brightness)
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetMode(mode Mode) error {
+	d.Lock()
+	d.mode = mode
+	d.Unlock()
+	return d.push()
+}
+
+func (d *WLEDDisplay) SetSpeed(speed int) error {
+	d.Lock()
+	d.speed = speed
+	d.Unlock()
+	return d.push()
+}
+
+// SetSize sets the matrix resolution
+func (d *WLEDDisplay) SetSize(w, h int) {
+	d.Lock()
+	defer d.Unlock()
+	d.width = w
+	d.height = h
+}
+
+func (d *WLEDDisplay) Size() (width, height int) {
+	d.Lock()
+	defer d.Unlock()
+	return d.width, d.height
+}
+
+// ShowFrame sends img through the DNRGB realtime protocol. WLED shows the realtime
+// pixels over the current effect and goes back to it when the frames stop
+func (d *WLEDDisplay) ShowFrame(img *image.RGBA) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.udp == nil {
+		conn, err := net.Dial("udp", net.JoinHostPort(d.host, strconv.Itoa(wledRealtimePort)))
+		if err != nil {
+			return err
+		}
+		d.udp = conn
+	}
+
+	if img.Bounds().Dx() != d.width || img.Bounds().Dy() != d.height {
+		img = RenderImage(img, d.width, d.height)
+	}
+
+	pixels := packRGB(img)
+	for start := 0; start*3 < len(pixels); start += wledMaxLedsPerFrame {
+		end := (start + wledMaxLedsPerFrame) * 3
+		if end > len(pixels) {
+			end = len(pixels)
+		}
+
+		packet := append([]byte{wledProtocolDNRGB, wledRealtimeTimeout, byte(start >> 8), byte(start)}, pixels[start*3:end]...)
+		if _, err := d.udp.Write(packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *WLEDDisplay) Close() error {
+	d.Lock()
+	defer d.Unlock()
+	if d.udp != nil {
+		return d.udp.Close()
+	}
+	return nil
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,172 @@
+package wimatrix
+
+import (
+	"image"
+	"image/color"
+)
+
+// The show functions send to the display without changing the device state.
+// They are called with the device lock held
+
+func (d *Device) showMode(mode Mode) {
+	log.Info("Setting mode to %s", mode)
+	if err := d.display.SetMode(mode); err != nil {
+		log.Error("Error setting mode: %s", err)
+	}
+}
+
+func (d *Device) showTextColor(c color.Color) {
+	if err := d.display.SetTextColor(c); err != nil {
+		log.Error("Error setting text color: %s", err)
+	}
+}
+
+func (d *Device) showBGColor(c color.Color) {
+	if err := d.display.SetBGColor(c); err != nil {
+		log.Error("Error setting background color: %s", err)
+	}
+}
+
+func (d *Device) showTextBrightness(brightness float32) {
+	if err := d.display.SetBrightness(brightness); err != nil {
+		log.Error("Error setting text brightness: %s", err)
+	}
+}
+
+func (d *Device) showBGBrightness(brightness float32) {
+	if err := d.display.SetBGBrightness(brightness); err != nil {
+		log.Error("Error setting background brightness: %s", err)
+	}
+}
+
+func (d *Device) showSpeed(speed int) {
+	if err := d.display.SetSpeed(speed); err != nil {
+		log.Error("Error setting speed: %s", err)
+	}
+}
+
+func (d *Device) showFrame(fd FrameDisplay, img *image.RGBA) {
+	if err := fd.ShowFrame(img); err != nil {
+		log.Error("Error sending frame: %s", err)
+	}
+}
+
+// shownMode is the mode of the showing alert, or the device mode
+func (d *Device) shownMode() Mode {
+	if d.overridden() {
+		return d.current.style.mode
+	}
+	return d.currentMode
+}
+
+func (d *Device) showMessage(message string, c color.Color) {
+	log.Info("Sending message: %s", message)
+	d.currentText = message
+	if err := d.display.Message(message, c); err != nil {
+		log.Error("Error sending message: %s", err)
+	}
+}
+
+// The set functions are control events. They apply immediately, except for the
+// settings overridden by the showing alert, that are applied when it ends
+
+func (d *Device) setMode(mode Mode) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	d.currentMode = mode
+	d.persist()
+	if !d.overridden() {
+		d.showMode(mode)
+	}
+}
+
+func (d *Device) setTextColor(c color.Color) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	d.lastColor = c
+	d.persist()
+	if !d.overridden() {
+		d.showTextColor(c)
+	}
+}
+
+func (d *Device) setBGColor(c color.Color) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	d.lastBGColor = c
+	d.persist()
+	if !d.overridden() {
+		d.showBGColor(c)
+	}
+}
+
+func (d *Device) setTextBrightness(brightness float32) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	brightness = clampBrightness(brightness)
+
+	log.Info("Setting text brightness to %f", brightness)
+	d.lastBrightness = brightness
+	d.persist()
+
+	if !d.overridden() || d.current.style.brightness == 0 {
+		d.showTextBrightness(brightness)
+	}
+}
+
+func (d *Device) setBGBrightness(brightness float32) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	if brightness > 0.2 {
+		brightness = 0.2
+	}
+	brightness = clampBrightness(brightness)
+
+	log.Info("Setting background brightness to %f", brightness)
+	d.lastBgBrightness = brightness
+	d.persist()
+
+	d.showBGBrightness(brightness)
+}
+
+func (d *Device) setSpeed(speed int) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	log.Debug("Setting speed to %d", speed)
+	d.lastSpeed = speed
+	d.persist()
+
+	if !d.overridden() || d.current.style.speed == 0 {
+		d.showSpeed(speed)
+	}
+}
@@ -0,0 +1,153 @@
+package wimatrix
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+)
+
+// defaultEffectInterval is the time between frames when EffectMaxRate is not set
+const defaultEffectInterval = time.Second / 10
+
+// effectPlayer is an effect being played on the device
+type effectPlayer struct {
+	effect   Effect
+	start    time.Time
+	interval time.Duration
+	stop     chan struct{}
+	last     Frame
+}
+
+// effectInterval is the minimum time between frames, from the configured updates per second
+func effectInterval() time.Duration {
+	rate := config.GetConfig().EffectMaxRate
+	if rate <= 0 {
+		return defaultEffectInterval
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// SetClock replaces the time source of the effects and the alert scheduler
+func (d *Device) SetClock(c Clock) {
+	d.Lock()
+	defer d.Unlock()
+	d.clock = c
+}
+
+// now is the time on the device clock
+func (d *Device) now() time.Time {
+	d.Lock()
+	defer d.Unlock()
+	return d.clock.Now()
+}
+
+// startEffect plays e until stopEffect is called. Called with the device lock held
+func (d *Device) startEffect(e Effect) {
+	d.stopEffect()
+
+	p := &effectPlayer{
+		effect:   e,
+		start:    d.clock.Now(),
+		interval: effectInterval(),
+		stop:     make(chan struct{}),
+		last:     NewFrame(0),
+	}
+	d.effect = p
+
+	log.Debug("Starting effect %s", e.Name())
+	go d.playEffect(p)
+}
+
+// stopEffect stops the playing effect. Called with the device lock held
+func (d *Device) stopEffect() {
+	if d.effect == nil {
+		return
+	}
+	close(d.effect.stop)
+	d.effect = nil
+}
+
+func (d *Device) playEffect(p *effectPlayer) {
+	for {
+		d.Lock()
+		if d.effect != p {
+			d.Unlock()
+			return
+		}
+		d.applyFrame(p, p.effect.Frame(d.clock.Now().Sub(p.start)))
+		clock := d.clock
+		d.Unlock()
+
+		select {
+		case <-p.stop:
+			return
+		case <-clock.After(p.interval):
+		}
+	}
+}
+
+// applyFrame sends what changed since the last frame. Called with the device lock held
+func (d *Device) applyFrame(p *effectPlayer, f Frame) {
+	last := p.last
+
+	if f.Image != nil && f.Image != last.Image {
+		if fd, ok := d.display.(FrameDisplay); ok {
+			if last.Image == nil {
+				d.showMode(ModePixels)
+			}
+			d.showFrame(fd, f.Image)
+		}
+		last.Image = f.Image
+	} else if f.Image == nil && last.Image != nil {
+		// Back from the pixel frames to the text
+		d.showMode(d.shownMode())
+		last.Image = nil
+	}
+
+	if f.BGColor != nil && !sameColor(f.BGColor, last.BGColor) {
+		d.showBGColor(f.BGColor)
+		last.BGColor = f.BGColor
+	}
+	if f.Brightness != Unchanged && f.Brightness != last.Brightness {
+		d.showTextBrightness(f.Brightness)
+		last.Brightness = f.Brightness
+	}
+	if f.BGBrightness != Unchanged && f.BGBrightness != last.BGBrightness {
+		d.showBGBrightness(f.BGBrightness)
+		last.BGBrightness = f.BGBrightness
+	}
+
+	textColor := last.TextColor
+	if f.TextColor != nil {
+		textColor = f.TextColor
+	}
+
+	if f.Text != "" && f.Text != last.Text {
+		if textColor == nil {
+			textColor = d.lastColor
+			if d.current != nil {
+				textColor = d.current.style.fgColor
+			}
+		}
+		// The message carries the text color
+		d.showMessage(f.Text, textColor)
+		last.Text = f.Text
+		last.TextColor = textColor
+	} else if f.TextColor != nil && !sameColor(f.TextColor, last.TextColor) {
+		d.showTextColor(f.TextColor)
+		last.TextColor = f.TextColor
+	}
+
+	last.At = f.At
+	p.last = last
+}
+
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
@@ -0,0 +1,238 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Unchanged is the brightness of a Frame that keeps the panel brightness
+const Unchanged float32 = -1
+
+// Frame is the panel state at a point of an effect. Nil colors, empty text and
+// Unchanged brightness are not sent to the panel. An image switches the panel to ModePixels
+type Frame struct {
+	At           time.Duration
+	TextColor    color.Color
+	BGColor      color.Color
+	Brightness   float32
+	BGBrightness float32
+	Text         string
+	Image        *image.RGBA
+}
+
+// NewFrame returns a frame at t that changes nothing
+func NewFrame(t time.Duration) Frame {
+	return Frame{
+		At:           t,
+		Brightness:   Unchanged,
+		BGBrightness: Unchanged,
+	}
+}
+
+// Effect generates the panel frames of an animation
+type Effect interface {
+	// Name is the name used to reference the effect
+	Name() string
+	// Frame returns the panel changes at t since the effect started
+	Frame(t time.Duration) Frame
+}
+
+// EffectFactory creates an effect from its parameters
+type EffectFactory func(p EffectParams) (Effect, error)
+
+var effectsLock sync.Mutex
+var effects = map[string]EffectFactory{}
+
+// RegisterEffect adds an effect that can be referenced by name on the alert templates
+func RegisterEffect(name string, factory EffectFactory) {
+	effectsLock.Lock()
+	defer effectsLock.Unlock()
+	effects[strings.ToLower(name)] = factory
+}
+
+// Effects returns the registered effect names
+func Effects() []string {
+	effectsLock.Lock()
+	defer effectsLock.Unlock()
+
+	names := make([]string, 0, len(effects))
+	for name := range effects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseEffect parses an effect reference, like "pulse period=2s min=0.2".
+// An empty spec returns a nil effect
+func ParseEffect(spec string) (Effect, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	name := strings.ToLower(fields[0])
+
+	effectsLock.Lock()
+	factory, ok := effects[name]
+	effectsLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown effect %q", name)
+	}
+
+	p := EffectParams{}
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid effect parameter %q, expected name=value", f)
+		}
+		p[strings.ToLower(kv[0])] = kv[1]
+	}
+
+	e, err := factory(p)
+	if err != nil {
+		return nil, fmt.Errorf("effect %s: %s", name, err)
+	}
+
+	return e, nil
+}
+
+// Sequence samples e every interval until length. It is what a player sends with a perfect clock
+func Sequence(e Effect, length, interval time.Duration) []Frame {
+	if interval <= 0 {
+		interval = defaultEffectInterval
+	}
+
+	var frames []Frame
+	for t := time.Duration(0); t < length; t += interval {
+		frames = append(frames, e.Frame(t))
+	}
+
+	return frames
+}
+
+// sequence plays first until length, then next
+type sequence struct {
+	first  Effect
+	length time.Duration
+	next   Effect
+}
+
+// Then plays first for length and next after it. A nil next keeps the panel as is
+func Then(first Effect, length time.Duration, next Effect) Effect {
+	return &sequence{
+		first:  first,
+		length: length,
+		next:   next,
+	}
+}
+
+func (s *sequence) Name() string {
+	if s.next == nil {
+		return s.first.Name()
+	}
+	return s.first.Name() + "+" + s.next.Name()
+}
+
+func (s *sequence) Frame(t time.Duration) Frame {
+	if t < s.length {
+		return s.first.Frame(t)
+	}
+	if s.next == nil {
+		return NewFrame(t)
+	}
+	f := s.next.Frame(t - s.length)
+	f.At = t
+	return f
+}
+
+// EffectParams are the name=value parameters of an effect reference
+type EffectParams map[string]string
+
+// check returns an error if there is a parameter not in names
+func (p EffectParams) check(names ...string) error {
+	for k := range p {
+		known := false
+		for _, n := range names {
+			if k == n {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown parameter %q", k)
+		}
+	}
+	return nil
+}
+
+func (p EffectParams) Duration(name string, def time.Duration) (time.Duration, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return def, fmt.Errorf("invalid %s %q", name, v)
+	}
+
+	return d, nil
+}
+
+func (p EffectParams) Float(name string, def float64) (float64, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q", name, v)
+	}
+
+	return f, nil
+}
+
+func (p EffectParams) Int(name string, def int) (int, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q", name, v)
+	}
+
+	return i, nil
+}
+
+func (p EffectParams) Color(name string, def color.Color) (color.Color, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+
+	c, err := ParseColor(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q", name, v)
+	}
+
+	return c, nil
+}
+
+func (p EffectParams) String(name string, def string) string {
+	if v, ok := p[name]; ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,349 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/colornames"
+)
+
+// Effect targets
+const (
+	targetText = "text"
+	targetBG   = "bg"
+	targetBoth = "both"
+)
+
+func init() {
+	RegisterEffect("rainbow", makeRainbow)
+	RegisterEffect("strobe", makeStrobe)
+	RegisterEffect("pulse", makePulse)
+	RegisterEffect("breathe", makePulse)
+	RegisterEffect("fadein", makeFade(true))
+	RegisterEffect("fadeout", makeFade(false))
+	RegisterEffect("countdown", makeCountdown)
+}
+
+func parseTarget(p EffectParams, def string) (string, error) {
+	t := strings.ToLower(p.String("target", def))
+	switch t {
+	case targetText, targetBG, targetBoth:
+		return t, nil
+	}
+	return def, fmt.Errorf("invalid target %q, expected text, bg or both", t)
+}
+
+// rainbow cycles the hue of the text and/or background colors
+type rainbow struct {
+	period time.Duration
+	target string
+}
+
+// makeRainbow parameters: period=3s target=text|bg|both
+func makeRainbow(p EffectParams) (Effect, error) {
+	if err := p.check("period", "target"); err != nil {
+		return nil, err
+	}
+
+	e := &rainbow{}
+	var err error
+
+	if e.period, err = p.Duration("period", time.Second*3); err != nil {
+		return nil, err
+	}
+	if e.target, err = parseTarget(p, targetText); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *rainbow) Name() string {
+	return "rainbow"
+}
+
+func (e *rainbow) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+	hue := 360 * float64(t%e.period) / float64(e.period)
+
+	if e.target != targetBG {
+		f.TextColor = hsv(hue, 1, 1)
+	}
+	if e.target != targetText {
+		// Opposite hue, so the text stays readable
+		f.BGColor = hsv(math.Mod(hue+180, 360), 1, 1)
+	}
+
+	return f
+}
+
+// strobe alternates between two colors
+type strobe struct {
+	rate   float64
+	on     color.Color
+	off    color.Color
+	target string
+}
+
+// makeStrobe parameters: rate=5 (flashes per second) color=white off=black target=text|bg|both
+func makeStrobe(p EffectParams) (Effect, error) {
+	if err := p.check("rate", "color", "off", "target"); err != nil {
+		return nil, err
+	}
+
+	e := &strobe{}
+	var err error
+
+	if e.rate, err = p.Float("rate", 5); err != nil {
+		return nil, err
+	}
+	if e.rate <= 0 {
+		return nil, fmt.Errorf("rate should be positive")
+	}
+	if e.on, err = p.Color("color", colornames.White); err != nil {
+		return nil, err
+	}
+	if e.off, err = p.Color("off", colornames.Black); err != nil {
+		return nil, err
+	}
+	if e.target, err = parseTarget(p, targetBG); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *strobe) Name() string {
+	return "strobe"
+}
+
+func (e *strobe) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+
+	c := e.off
+	if int64(t.Seconds()*e.rate*2)%2 == 0 {
+		c = e.on
+	}
+
+	if e.target != targetBG {
+		f.TextColor = c
+	}
+	if e.target != targetText {
+		f.BGColor = c
+	}
+
+	return f
+}
+
+// pulse moves the brightness up and down like breathing
+type pulse struct {
+	period time.Duration
+	min    float32
+	max    float32
+	target string
+}
+
+// makePulse parameters: period=2s min=0.1 max=1 target=text|bg|both
+func makePulse(p EffectParams) (Effect, error) {
+	if err := p.check("period", "min", "max", "target"); err != nil {
+		return nil, err
+	}
+
+	e := &pulse{}
+	var err error
+	var min, max float64
+
+	if e.period, err = p.Duration("period", time.Second*2); err != nil {
+		return nil, err
+	}
+	if min, err = p.Float("min", 0.1); err != nil {
+		return nil, err
+	}
+	if max, err = p.Float("max", 1); err != nil {
+		return nil, err
+	}
+	if min < 0 || max > 1 || min > max {
+		return nil, fmt.Errorf("min and max should be between 0 and 1, with min <= max")
+	}
+	if e.target, err = parseTarget(p, targetText); err != nil {
+		return nil, err
+	}
+
+	e.min = float32(min)
+	e.max = float32(max)
+
+	return e, nil
+}
+
+func (e *pulse) Name() string {
+	return "pulse"
+}
+
+func (e *pulse) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+
+	phase := 2 * math.Pi * float64(t%e.period) / float64(e.period)
+	b := e.min + (e.max-e.min)*float32((1-math.Cos(phase))/2)
+
+	if e.target != targetBG {
+		f.Brightness = b
+	}
+	if e.target != targetText {
+		f.BGBrightness = b
+	}
+
+	return f
+}
+
+// fade moves the brightness from 0 to max, or from max to 0
+type fade struct {
+	in       bool
+	duration time.Duration
+	max      float32
+	target   string
+}
+
+// makeFade parameters: duration=1s max=1 target=text|bg|both
+func makeFade(in bool) EffectFactory {
+	return func(p EffectParams) (Effect, error) {
+		if err := p.check("duration", "max", "target"); err != nil {
+			return nil, err
+		}
+
+		e := &fade{in: in}
+		var err error
+		var max float64
+
+		if e.duration, err = p.Duration("duration", time.Second); err != nil {
+			return nil, err
+		}
+		if max, err = p.Float("max", 1); err != nil {
+			return nil, err
+		}
+		if max < 0 || max > 1 {
+			return nil, fmt.Errorf("max should be between 0 and 1")
+		}
+		if e.target, err = parseTarget(p, targetText); err != nil {
+			return nil, err
+		}
+
+		e.max = float32(max)
+
+		return e, nil
+	}
+}
+
+func (e *fade) Name() string {
+	if e.in {
+		return "fadein"
+	}
+	return "fadeout"
+}
+
+func (e *fade) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+
+	progress := float32(1)
+	if t < e.duration {
+		progress = float32(t) / float32(e.duration)
+	}
+	if !e.in {
+		progress = 1 - progress
+	}
+
+	b := e.max * progress
+	if e.target != targetBG {
+		f.Brightness = b
+	}
+	if e.target != targetText {
+		f.BGBrightness = b
+	}
+
+	return f
+}
+
+// countdown shows the seconds left, then the done text
+type countdown struct {
+	from  int
+	text  string
+	done  string
+	color color.Color
+}
+
+// makeCountdown parameters: from=10 text={n} done=GO! color=red. Use _ for spaces in the texts
+func makeCountdown(p EffectParams) (Effect, error) {
+	if err := p.check("from", "text", "done", "color"); err != nil {
+		return nil, err
+	}
+
+	e := &countdown{
+		text: strings.Replace(p.String("text", "{n}"), "_", " ", -1),
+		done: strings.Replace(p.String("done", "GO!"), "_", " ", -1),
+	}
+	var err error
+
+	if e.from, err = p.Int("from", 10); err != nil {
+		return nil, err
+	}
+	if e.from < 1 {
+		return nil, fmt.Errorf("from should be at least 1")
+	}
+	if e.color, err = p.Color("color", nil); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *countdown) Name() string {
+	return "countdown"
+}
+
+func (e *countdown) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+	f.TextColor = e.color
+
+	left := e.from - int(t/time.Second)
+	if left <= 0 {
+		f.Text = e.done
+		return f
+	}
+
+	f.Text = renderTemplate(e.text, map[string]string{"n": strconv.Itoa(left)})
+
+	return f
+}
+
+// hsv converts hue (0-360), saturation and value (0-1) to a color
+func hsv(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
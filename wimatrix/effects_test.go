@@ -0,0 +1,172 @@
+package wimatrix
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"golang.org/x/image/colornames"
+)
+
+// playEffect plays spec on a recording device with a fake clock, at rate updates per second
+func playEffect(t *testing.T, spec string, rate float64) (*RecordingDisplay, *FakeClock, func()) {
+	t.Helper()
+
+	c := config.GetConfig()
+	c.EffectMaxRate = rate
+	config.SetConfig(c)
+
+	e, err := ParseEffect(spec)
+	if err != nil {
+		t.Fatalf("ParseEffect(%q): %s", spec, err)
+	}
+
+	display := MakeRecordingDisplay()
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	d := MakeWiiMatrix("test", display, nil)
+	d.SetClock(clock)
+
+	d.Lock()
+	d.startEffect(e)
+	d.Unlock()
+	waitSleeping(t, clock)
+
+	return display, clock, func() {
+		d.Lock()
+		d.stopEffect()
+		d.Unlock()
+	}
+}
+
+// waitSleeping waits for the effect player to wait on the clock, after sending its frame
+func waitSleeping(t *testing.T, clock *FakeClock) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("effect player is not waiting on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// advance moves the clock by step n times, letting the player send the frames that are due
+func advance(t *testing.T, clock *FakeClock, step time.Duration, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		clock.Advance(step)
+		waitSleeping(t, clock)
+	}
+}
+
+// callArgs returns the first argument of every method call
+func callArgs(display *RecordingDisplay, method string) []interface{} {
+	var args []interface{}
+	for _, c := range display.Calls() {
+		if c.Method == method {
+			args = append(args, c.Args[0])
+		}
+	}
+	return args
+}
+
+func sameValue(a, b interface{}) bool {
+	switch av := a.(type) {
+	case color.Color:
+		bv, ok := b.(color.Color)
+		return ok && sameColor(av, bv)
+	case float32:
+		bv, ok := b.(float32)
+		return ok && math.Abs(float64(av-bv)) < 1e-4
+	}
+	return a == b
+}
+
+func TestBuiltinEffects(t *testing.T) {
+	tests := []struct {
+		spec   string
+		rate   float64
+		steps  int
+		method string
+		want   []interface{}
+	}{
+		{
+			"rainbow period=2s", 2, 3, "SetTextColor",
+			[]interface{}{colornames.Red, color.RGBA{R: 128, G: 255, A: 255}, color.RGBA{G: 255, B: 255, A: 255}, color.RGBA{R: 128, B: 255, A: 255}},
+		},
+		{
+			"rainbow period=2s target=bg", 2, 1, "SetBGColor",
+			[]interface{}{color.RGBA{G: 255, B: 255, A: 255}, color.RGBA{R: 128, B: 255, A: 255}},
+		},
+		// Unchanged frames are not sent again
+		{"strobe rate=1 color=red off=blue", 4, 4, "SetBGColor", []interface{}{colornames.Red, colornames.Blue, colornames.Red}},
+		{"strobe rate=1 color=red off=blue target=text", 2, 2, "SetTextColor", []interface{}{colornames.Red, colornames.Blue, colornames.Red}},
+		{"pulse period=2s min=0 max=1", 2, 3, "SetBrightness", []interface{}{float32(0), float32(0.5), float32(1), float32(0.5)}},
+		{"breathe period=2s min=0.2 max=0.6 target=bg", 2, 2, "SetBGBrightness", []interface{}{float32(0.2), float32(0.4), float32(0.6)}},
+		{"fadein duration=1s", 4, 6, "SetBrightness", []interface{}{float32(0), float32(0.25), float32(0.5), float32(0.75), float32(1)}},
+		{"fadeout duration=1s max=0.5 target=bg", 2, 3, "SetBGBrightness", []interface{}{float32(0.5), float32(0.25), float32(0)}},
+		{"countdown from=2 text={n}_left done=GO", 2, 5, "Message", []interface{}{"2 left", "1 left", "GO"}},
+	}
+
+	for _, tt := range tests {
+		display, clock, stop := playEffect(t, tt.spec, tt.rate)
+		advance(t, clock, time.Duration(float64(time.Second)/tt.rate), tt.steps)
+		stop()
+
+		got := callArgs(display, tt.method)
+		ok := len(got) == len(tt.want)
+		for i := 0; ok && i < len(got); i++ {
+			ok = sameValue(got[i], tt.want[i])
+		}
+		if !ok {
+			t.Errorf("%s: %s calls = %v, want %v", tt.spec, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestEffectMaxRate(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want int
+	}{
+		{4, 5},
+		{20, 21},
+		// Not set, 10 updates per second
+		{0, 11},
+	}
+
+	for _, tt := range tests {
+		display, clock, stop := playEffect(t, "rainbow period=1s", tt.rate)
+		// A frame changes the color every step, but only the ones at the max rate are sent
+		advance(t, clock, time.Millisecond*50, 20)
+		stop()
+
+		if got := len(callArgs(display, "SetTextColor")); got != tt.want {
+			t.Errorf("rate %v: %d updates in 1s, want %d", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestParseEffectErrors(t *testing.T) {
+	for _, spec := range []string{
+		"sparkle",
+		"rainbow period",
+		"rainbow speed=2",
+		"rainbow period=-1s",
+		"strobe rate=0",
+		"pulse min=0.8 max=0.2",
+		"fadein max=2",
+		"countdown from=0",
+		"rainbow target=floor",
+	} {
+		if _, err := ParseEffect(spec); err == nil {
+			t.Errorf("ParseEffect(%q) did not fail", spec)
+		}
+	}
+
+	if e, err := ParseEffect(""); e != nil || err != nil {
+		t.Errorf("ParseEffect(\"\") = %v, %v, want no effect", e, err)
+	}
+}
@@ -0,0 +1,253 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/twitch/twitchdata"
+)
+
+// defaultEmoteCDN is the twitch emote CDN, used when EmoteCDN is not configured
+const defaultEmoteCDN = "https://static-cdn.jtvnw.net/emoticons/v2"
+
+// defaultStandIn replaces emotes without a configured stand-in on text only panels
+const defaultStandIn = ":)"
+
+// marqueeSpeed is how many pixels per second messages with inline emotes scroll
+const marqueeSpeed = 20
+
+// emoteCacheSize is the maximum number of emote images kept in memory
+const emoteCacheSize = 256
+
+// defaultStandIns are the ASCII stand-ins of common global emotes
+var defaultStandIns = map[string]string{
+	"Kappa":       ";)",
+	"LUL":         "xD",
+	"PogChamp":    ":O",
+	"BibleThump":  ":'(",
+	"<3":          "<3",
+	"HeyGuys":     "o/",
+	"NotLikeThis": "D:",
+}
+
+// EmoteSet maps emote names to their twitch ids
+type EmoteSet map[string]string
+
+// EmotesFromMessage returns the emotes of message from the positions of the emotes tag
+func EmotesFromMessage(message string, emotes []twitchdata.Emote) EmoteSet {
+	runes := []rune(message)
+	set := EmoteSet{}
+
+	for _, e := range emotes {
+		if e.Start < 0 || e.End < e.Start || e.End >= len(runes) {
+			continue
+		}
+		set[string(runes[e.Start:e.End+1])] = e.Id
+	}
+
+	return set
+}
+
+// MessageRun is a piece of a message: text or a single emote
+type MessageRun struct {
+	Text    string
+	EmoteId string
+}
+
+func (r MessageRun) IsEmote() bool {
+	return r.EmoteId != ""
+}
+
+// SplitEmotes splits text in text and emote runs. Emotes are whole words, like twitch does
+func SplitEmotes(text string, set EmoteSet) []MessageRun {
+	var runs []MessageRun
+	current := ""
+
+	words := strings.Split(text, " ")
+	for i, word := range words {
+		if i > 0 {
+			current += " "
+		}
+
+		id, ok := set[word]
+		if !ok || word == "" {
+			current += word
+			continue
+		}
+
+		if current != "" {
+			runs = append(runs, MessageRun{Text: current})
+			current = ""
+		}
+		runs = append(runs, MessageRun{Text: word, EmoteId: id})
+	}
+
+	if current != "" {
+		runs = append(runs, MessageRun{Text: current})
+	}
+
+	return runs
+}
+
+// StandIn returns the ASCII text shown instead of the emote name on panels without pixel frames
+func StandIn(name string) string {
+	cfg := config.GetConfig()
+
+	if s, ok := cfg.EmoteStandIns[name]; ok {
+		return s
+	}
+	if s, ok := defaultStandIns[name]; ok {
+		return s
+	}
+	if cfg.EmoteStandIn != "" {
+		return cfg.EmoteStandIn
+	}
+
+	return defaultStandIn
+}
+
+// ReplaceEmotes replaces the emotes of text with their stand-ins
+func ReplaceEmotes(text string, set EmoteSet) string {
+	if len(set) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	for _, r := range SplitEmotes(text, set) {
+		if r.IsEmote() {
+			sb.WriteString(StandIn(r.Text))
+		} else {
+			sb.WriteString(r.Text)
+		}
+	}
+
+	return sb.String()
+}
+
+// EmoteURL returns the image url of a twitch emote. The default format is a GIF for animated emotes
+func EmoteURL(id string) string {
+	base := strings.TrimRight(config.GetConfig().EmoteCDN, "/")
+	if base == "" {
+		base = defaultEmoteCDN
+	}
+	return fmt.Sprintf("%s/%s/default/dark/1.0", base, id)
+}
+
+// emoteCache keeps the rendered emotes by id and size
+type emoteCache struct {
+	sync.Mutex
+	items map[string]*Animation
+}
+
+var emoteImages = &emoteCache{
+	items: map[string]*Animation{},
+}
+
+// FetchEmote returns the emote id rendered at size x size, from the cache or the CDN
+func FetchEmote(id string, size int) (*Animation, error) {
+	key := fmt.Sprintf("%s@%d", id, size)
+
+	emoteImages.Lock()
+	anim, ok := emoteImages.items[key]
+	emoteImages.Unlock()
+	if ok {
+		return anim, nil
+	}
+
+	anim, err := FetchAnimation(EmoteURL(id), size, size)
+	if err != nil {
+		return nil, err
+	}
+
+	emoteImages.Lock()
+	if len(emoteImages.items) >= emoteCacheSize {
+		// Emotes are small, starting over is cheaper than tracking usage
+		emoteImages.items = map[string]*Animation{}
+	}
+	emoteImages.items[key] = anim
+	emoteImages.Unlock()
+
+	return anim, nil
+}
+
+// marqueeItem is a text or emote of a scrolling message
+type marqueeItem struct {
+	x     int
+	width int
+	anim  *Animation
+}
+
+// marquee scrolls a message with inline emotes from right to left
+type marquee struct {
+	items  []marqueeItem
+	length int
+	width  int
+	height int
+}
+
+// RenderMessage renders the runs as a message that scrolls on a w x h panel. Emotes that cannot
+// be fetched are replaced by their stand-ins
+func RenderMessage(runs []MessageRun, c color.Color, w, h int) Effect {
+	m := &marquee{
+		width:  w,
+		height: h,
+	}
+
+	add := func(anim *Animation) {
+		if len(anim.Frames) == 0 {
+			return
+		}
+		width := anim.Frames[0].Image.Bounds().Dx()
+		m.items = append(m.items, marqueeItem{x: m.length, width: width, anim: anim})
+		m.length += width
+	}
+
+	for _, r := range runs {
+		if r.IsEmote() {
+			anim, err := FetchEmote(r.EmoteId, h)
+			if err == nil {
+				add(anim)
+				continue
+			}
+			log.Warn("Cannot load emote %s: %s", r.Text, err)
+			add(StillAnimation(RenderText(StandIn(r.Text), c, h)))
+			continue
+		}
+		add(StillAnimation(RenderText(r.Text, c, h)))
+	}
+
+	return m
+}
+
+func (m *marquee) Name() string {
+	return "marquee"
+}
+
+func (m *marquee) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, m.width, m.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// Starts out of the panel on the right and leaves on the left
+	offset := m.width - int(t.Seconds()*marqueeSpeed)%(m.length+m.width)
+
+	for _, item := range m.items {
+		x := item.x + offset
+		if x+item.width < 0 || x >= m.width {
+			continue
+		}
+		frame := item.anim.At(t)
+		draw.Draw(img, frame.Bounds().Add(image.Pt(x, 0)), frame, image.Point{}, draw.Src)
+	}
+
+	f.Image = img
+
+	return f
+}
@@ -2,112 +2,190 @@ package wimatrix
 
 import (
 	"image/color"
-	"time"
+	"strings"
 )
 
+// handlers maps each EventBus topic to its handler
+func (d *Device) handlers() map[string]interface{} {
+	return map[string]interface{}{
+		EvNewSub:            d.evNewSub,
+		EvNewFollower:       d.evNewFollower,
+		EvSetTextColor:      d.evSetTextColor,
+		EvSetBgColor:        d.evSetBackgroundColor,
+		EvNewMsg:            d.evNewMessage,
+		EvNewEmoteMsg:       d.evNewEmoteMessage,
+		EvStreamStatus:      d.evStreamStatus,
+		EvStreamTitle:       d.evStreamTitle,
+		EvSubGoal:           d.evSubGoal,
+		EvSetTextBrightness: d.evSetTextBrightness,
+		EvSetBgBrightness:   d.evSetBGBrightness,
+		EvNewMode:           d.evNewMode,
+		EvSetSpeed:          d.evSetSpeed,
+		EvNewBits:           d.evNewBits,
+		EvNewRaid:           d.evNewRaid,
+		EvNewReward:         d.evNewReward,
+		EvBitsEmotes:        d.evBitsEmotes,
+	}
+}
+
+// SetTags sets the groups of the device. Must be called before Start
+func (d *Device) SetTags(tags ...string) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.tags = nil
+	for _, t := range tags {
+		d.tags = append(d.tags, strings.ToLower(strings.TrimSpace(t)))
+	}
+}
+
+// targets returns the device name and tags, without duplicates
+func (d *Device) targets() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	targets := []string{strings.ToLower(d.name)}
+	for _, t := range d.tags {
+		if t != "" && t != targets[0] {
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+func (d *Device) hasTarget(target string) bool {
+	for _, t := range d.targets() {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// topics returns every topic the device listens to: the broadcast topic and one per target
+func (d *Device) topics(topic string) []string {
+	topics := []string{topic}
+	for _, t := range d.targets() {
+		topics = append(topics, Target(topic, t))
+	}
+	return topics
+}
+
+// subEventBus subscribes the handlers once. EventBus matches handlers by function pointer,
+// which is the same for every Device, so unsubscribing could remove another device handlers.
+// Handlers of a stopped device are ignored instead
 func (d *Device) subEventBus() {
-	d.ev.Subscribe(EvNewSub, d.evNewSub)
-	d.ev.Subscribe(EvNewFollower, d.evNewFollower)
-	d.ev.Subscribe(EvSetTextColor, d.evSetTextColor)
-	d.ev.Subscribe(EvSetBgColor, d.evSetBackgroundColor)
-	d.ev.Subscribe(EvNewMsg, d.evNewMessage)
-	d.ev.Subscribe(EvSetTextBrightness, d.evSetTextBrightness)
-	d.ev.Subscribe(EvSetBgBrightness, d.evSetBGBrightness)
-	d.ev.Subscribe(EvNewMode, d.evNewMode)
-	d.ev.Subscribe(EvSetSpeed, d.evSetSpeed)
-	d.ev.Subscribe(EvSetLight, d.evSetLight)
-	d.ev.Subscribe(EvNewBits, d.evNewBits)
-}
-
-func (d *Device) unSubEventBus() {
-	d.ev.Unsubscribe(EvNewSub, d.evNewSub)
-	d.ev.Unsubscribe(EvNewFollower, d.evNewFollower)
-	d.ev.Unsubscribe(EvSetTextColor, d.evSetTextColor)
-	d.ev.Unsubscribe(EvSetBgColor, d.evSetBackgroundColor)
-	d.ev.Unsubscribe(EvNewMsg, d.evNewMessage)
-	d.ev.Unsubscribe(EvSetTextBrightness, d.evSetTextBrightness)
-	d.ev.Unsubscribe(EvSetBgBrightness, d.evSetBGBrightness)
-	d.ev.Unsubscribe(EvNewMode, d.evNewMode)
-	d.ev.Unsubscribe(EvSetSpeed, d.evSetSpeed)
-	d.ev.Unsubscribe(EvSetLight, d.evSetLight)
-	d.ev.Unsubscribe(EvNewBits, d.evNewBits)
-}
-
-func (d *Device) evNewSub(username string, months int) {
-	d.eventQueue.Add(&newSubEvent{
+	d.Lock()
+	if d.subscribed {
+		d.Unlock()
+		return
+	}
+	d.subscribed = true
+	d.Unlock()
+
+	for topic, handler := range d.handlers() {
+		for _, t := range d.topics(topic) {
+			_ = d.ev.Subscribe(t, handler)
+		}
+	}
+}
+
+func (d *Device) evNewSub(username string, months int, tier string) {
+	d.queueAlert(&newSubEvent{
 		username: username,
 		months:   months,
-		when:     time.Now(),
+		tier:     tier,
+		when:     d.now(),
 	})
 }
 
 func (d *Device) evNewFollower(username string) {
-	d.eventQueue.Add(&newFollowerEvent{
-		username: username,
-		when:     time.Now(),
+	d.queueAlert(&newFollowerEvent{
+		usernames: []string{username},
+		when:      d.now(),
 	})
 }
 
 func (d *Device) evSetTextColor(color color.Color) {
-	d.eventQueue.Add(&textColorEvent{
-		color: color,
-		when:  time.Now(),
-	})
+	d.setTextColor(color)
 }
 
 func (d *Device) evSetBackgroundColor(color color.Color) {
-	d.eventQueue.Add(&bgColorEvent{
-		color: color,
-		when:  time.Now(),
-	})
+	d.setBGColor(color)
 }
 
 func (d *Device) evSetTextBrightness(brightness float32) {
-	d.eventQueue.Add(&textBrightnessEvent{
-		brightness: brightness,
-		when:       time.Now(),
-	})
+	d.setTextBrightness(brightness)
 }
 
 func (d *Device) evSetBGBrightness(brightness float32) {
-	d.eventQueue.Add(&bgBrightnessEvent{
-		brightness: brightness,
-		when:       time.Now(),
-	})
+	d.setBGBrightness(brightness)
 }
 
-func (d *Device) evNewMessage(message string) {
-	d.eventQueue.Add(&messageEvent{
+func (d *Device) evNewMessage(message string, done AlertDone) {
+	d.queueTrackedAlert(&messageEvent{
 		text: message,
-		when: time.Now(),
-	})
+		when: d.now(),
+	}, done)
 }
 
 func (d *Device) evNewMode(mode Mode) {
-	d.eventQueue.Add(&newModeEvent{
-		mode: mode,
-		when: time.Now(),
-	})
+	d.setMode(mode)
 }
 
 func (d *Device) evSetSpeed(speed int) {
-	d.eventQueue.Add(&newSetSpeedEvent{
-		speed: speed,
-		when:  time.Now(),
+	d.setSpeed(speed)
+}
+
+func (d *Device) evNewBits(username string, numBits int, message string) {
+	d.queueAlert(&newBits{
+		message:  message,
+		username: username,
+		bits:     numBits,
+		when:     d.now(),
 	})
 }
 
-func (d *Device) evSetLight() {
-	d.eventQueue.Add(&newSetLightEvent{
-		when: time.Now(),
+func (d *Device) evNewRaid(username string, viewers int) {
+	d.queueAlert(&newRaidEvent{
+		username: username,
+		viewers:  viewers,
+		when:     d.now(),
 	})
 }
 
-func (d *Device) evNewBits(username string, numBits int, message string) {
-	d.eventQueue.Add(&newBits{
-		message:  message,
+func (d *Device) evNewReward(username, title, input string, cost int, done AlertDone) {
+	d.queueTrackedAlert(&newRewardEvent{
 		username: username,
-		bits:     numBits,
-		when:     time.Now(),
+		title:    title,
+		input:    input,
+		cost:     cost,
+		when:     d.now(),
+	}, done)
+}
+
+// evBitsEmotes receives the emotes of a cheer message, shown with the bits alert
+func (d *Device) evBitsEmotes(username string, emotes EmoteSet) {
+	d.addBitsEmotes(username, emotes)
+}
+
+func (d *Device) evNewEmoteMessage(message string, emotes EmoteSet) {
+	d.queueAlert(&messageEvent{
+		text:   message,
+		emotes: emotes,
+		when:   d.now(),
 	})
 }
+
+func (d *Device) evStreamStatus(online bool, title string) {
+	d.setStreamStatus(online, title)
+}
+
+func (d *Device) evStreamTitle(title string) {
+	d.setStreamTitle(title)
+}
+
+func (d *Device) evSubGoal(current, goal int) {
+	d.setSubGoal(current, goal)
+}
@@ -1,109 +1,42 @@
 package wimatrix
 
 import (
-	"image/color"
 	"time"
 )
 
 type eventType int
 
 const (
-	eventMessage        eventType = iota
-	eventTextColor      eventType = iota
-	eventBGColor        eventType = iota
-	eventTextBrightness eventType = iota
-	eventBGBrightness   eventType = iota
-	eventNewSub         eventType = iota
-	eventNewFollower    eventType = iota
-	eventNewMode        eventType = iota
-	eventSetSpeed       eventType = iota
-	eventSetLight       eventType = iota
-	eventNewBits        eventType = iota
+	eventMessage     eventType = iota
+	eventNewSub      eventType = iota
+	eventNewFollower eventType = iota
+	eventNewBits     eventType = iota
+	eventNewRaid     eventType = iota
+	eventNewReward   eventType = iota
 )
 
 const expirationDuration = time.Minute * 5
 
+// event is an alert waiting to be shown on the panel
 type event interface {
 	GetType() eventType
-	Expired() bool
+	// Expired returns true if the alert waited too long to be shown at now
+	Expired(now time.Time) bool
 }
 
 // region
 type messageEvent struct {
-	text string
-	when time.Time
+	text   string
+	emotes EmoteSet
+	when   time.Time
 }
 
 func (e messageEvent) GetType() eventType {
 	return eventMessage
 }
 
-func (e messageEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
-}
-
-// endregion
-
-// region
-type textColorEvent struct {
-	color color.Color
-	when  time.Time
-}
-
-func (e textColorEvent) GetType() eventType {
-	return eventTextColor
-}
-
-func (e textColorEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
-}
-
-// endregion
-
-// region
-type bgColorEvent struct {
-	color color.Color
-	when  time.Time
-}
-
-func (e bgColorEvent) GetType() eventType {
-	return eventBGColor
-}
-
-func (e bgColorEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
-}
-
-// endregion
-
-// region
-type textBrightnessEvent struct {
-	brightness float32
-	when       time.Time
-}
-
-func (e textBrightnessEvent) GetType() eventType {
-	return eventTextBrightness
-}
-
-func (e textBrightnessEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
-}
-
-// endregion
-
-// region
-type bgBrightnessEvent struct {
-	brightness float32
-	when       time.Time
-}
-
-func (e bgBrightnessEvent) GetType() eventType {
-	return eventBGBrightness
-}
-
-func (e bgBrightnessEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e messageEvent) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
@@ -112,6 +45,7 @@ func (e bgBrightnessEvent) Expired() bool {
 type newSubEvent struct {
 	username string
 	months   int
+	tier     string
 	when     time.Time
 }
 
@@ -119,89 +53,81 @@ func (e newSubEvent) GetType() eventType {
 	return eventNewSub
 }
 
-func (e newSubEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e newSubEvent) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
 
 // region
 type newFollowerEvent struct {
-	username string
-	when     time.Time
+	// usernames has more than one user when follows are coalesced
+	usernames []string
+	when      time.Time
 }
 
 func (e newFollowerEvent) GetType() eventType {
 	return eventNewFollower
 }
 
-func (e newFollowerEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
-}
-
-// endregion
-
-// region
-type newModeEvent struct {
-	mode Mode
-	when time.Time
-}
-
-func (e newModeEvent) GetType() eventType {
-	return eventNewMode
-}
-
-func (e newModeEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e newFollowerEvent) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
 
 // region
-type newSetSpeedEvent struct {
-	speed int
-	when  time.Time
+type newBits struct {
+	when     time.Time
+	message  string
+	username string
+	bits     int
+	// emotes of the cheer message, from the chat emotes tag
+	emotes EmoteSet
 }
 
-func (e newSetSpeedEvent) GetType() eventType {
-	return eventSetSpeed
+func (e newBits) GetType() eventType {
+	return eventNewBits
 }
 
-func (e newSetSpeedEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e newBits) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
 
 // region
-type newSetLightEvent struct {
-	when time.Time
+type newRaidEvent struct {
+	when     time.Time
+	username string
+	viewers  int
 }
 
-func (e newSetLightEvent) GetType() eventType {
-	return eventSetLight
+func (e newRaidEvent) GetType() eventType {
+	return eventNewRaid
 }
 
-func (e newSetLightEvent) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e newRaidEvent) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
 
 // region
-type newBits struct {
+type newRewardEvent struct {
 	when     time.Time
-	message  string
 	username string
-	bits     int
+	title    string
+	input    string
+	cost     int
 }
 
-func (e newBits) GetType() eventType {
-	return eventNewBits
+func (e newRewardEvent) GetType() eventType {
+	return eventNewReward
 }
 
-func (e newBits) Expired() bool {
-	return e.when.Add(expirationDuration).Before(time.Now())
+func (e newRewardEvent) Expired(now time.Time) bool {
+	return e.when.Add(expirationDuration).Before(now)
 }
 
 // endregion
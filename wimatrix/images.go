@@ -0,0 +1,173 @@
+package wimatrix
+
+import (
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pendingEmotesTimeout is how long emotes wait for the bits alert they belong to
+const pendingEmotesTimeout = time.Minute
+
+// AvatarLookup returns the profile picture url of a user
+type AvatarLookup func(username string) (string, error)
+
+var avatarLock sync.Mutex
+var avatarLookup AvatarLookup
+
+// SetAvatarLookup sets how follower avatars are found, like twitch.GetProfilePic
+func SetAvatarLookup(lookup AvatarLookup) {
+	avatarLock.Lock()
+	defer avatarLock.Unlock()
+	avatarLookup = lookup
+}
+
+func getAvatarLookup() AvatarLookup {
+	avatarLock.Lock()
+	defer avatarLock.Unlock()
+	return avatarLookup
+}
+
+// pendingEmotes are emotes received before their bits alert
+type pendingEmotes struct {
+	emotes EmoteSet
+	when   time.Time
+}
+
+// imageLoader fetches the images of an alert rendered at w x h
+type imageLoader func(w, h int) (Effect, error)
+
+// imagesFor returns the loader of the images of a and how long they are shown,
+// or a nil loader if it has none. Called with the device lock held
+func imagesFor(a *alert, textColor color.Color) (imageLoader, time.Duration) {
+	if set := alertEmotes(a); len(set) > 0 {
+		// The whole message scrolls with the emotes inline
+		runs := SplitEmotes(rawAlertText(a), set)
+		c := textColor
+		return func(w, h int) (Effect, error) {
+			return RenderMessage(runs, c, w, h), nil
+		}, a.style.duration
+	}
+
+	if e, ok := a.event.(*newFollowerEvent); ok {
+		lookup := getAvatarLookup()
+		if lookup == nil {
+			return nil, 0
+		}
+		username := e.usernames[0]
+		return func(w, h int) (Effect, error) {
+			url, err := lookup(username)
+			if err != nil {
+				return nil, err
+			}
+			return FetchAnimation(url, w, h)
+		}, alertImageTime
+	}
+
+	return nil, 0
+}
+
+// loadImages fetches the images of a in background if the display shows pixel frames.
+// Called with the device lock held
+func (d *Device) loadImages(a *alert) {
+	fd, ok := d.display.(FrameDisplay)
+	if !ok {
+		return
+	}
+
+	textColor := a.style.fgColor
+	if a.message {
+		textColor = d.lastColor
+	}
+
+	load, length := imagesFor(a, textColor)
+	if load == nil {
+		return
+	}
+
+	go func() {
+		w, h := fd.Size()
+		images, err := load(w, h)
+		if err != nil {
+			log.Error("Error loading images of alert %d: %s", a.event.GetType(), err)
+			return
+		}
+
+		d.Lock()
+		defer d.Unlock()
+
+		a.images = images
+		a.imageLength = length
+		// Already on the panel, show the images from now
+		if d.running && d.current == a {
+			d.startEffect(alertEffect(a))
+		}
+	}()
+}
+
+// alertEmotes returns the emotes of the alert message
+func alertEmotes(a *alert) EmoteSet {
+	switch e := a.event.(type) {
+	case *messageEvent:
+		return e.emotes
+	case *newBits:
+		return e.emotes
+	}
+	return nil
+}
+
+// attachPendingEmotes adds the emotes received before the bits alert a. Called with the device lock held
+func (d *Device) attachPendingEmotes(a *alert) {
+	bits, ok := a.event.(*newBits)
+	if !ok {
+		return
+	}
+
+	for user, p := range d.pendingEmotes {
+		if time.Since(p.when) > pendingEmotesTimeout {
+			delete(d.pendingEmotes, user)
+		}
+	}
+
+	key := strings.ToLower(bits.username)
+	if p, ok := d.pendingEmotes[key]; ok {
+		bits.emotes = p.emotes
+		delete(d.pendingEmotes, key)
+	}
+}
+
+// addBitsEmotes attaches emotes to the queued or showing bits alert of username,
+// or keeps them until the alert arrives
+func (d *Device) addBitsEmotes(username string, set EmoteSet) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running || len(set) == 0 {
+		return
+	}
+
+	alerts := append([]*alert{}, d.alerts.items...)
+	if d.current != nil {
+		alerts = append(alerts, d.current)
+	}
+
+	for _, a := range alerts {
+		bits, ok := a.event.(*newBits)
+		if !ok || !strings.EqualFold(bits.username, username) || len(bits.emotes) > 0 {
+			continue
+		}
+		bits.emotes = set
+		if a == d.current {
+			// Send the text again with the stand-ins
+			d.showMessage(alertText(a), a.style.fgColor)
+		}
+		d.loadImages(a)
+		return
+	}
+
+	d.pendingEmotes[strings.ToLower(username)] = pendingEmotes{
+		emotes: set,
+		when:   time.Now(),
+	}
+}
@@ -1,6 +1,10 @@
 package wimatrix
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type Mode uint8
 
@@ -10,6 +14,8 @@ const (
 	ModeBackgroundStringDisplay Mode = 2
 	ModeClock                   Mode = 3
 	ModeBackgroundClock         Mode = 4
+	// ModePixels shows the raw frames sent to the frame topic
+	ModePixels Mode = 5
 )
 
 var modeNames = map[Mode]string{
@@ -18,6 +24,7 @@ var modeNames = map[Mode]string{
 	ModeBackgroundStringDisplay: "String Display with Background",
 	ModeClock:                   "Clock",
 	ModeBackgroundClock:         "Clock with Background",
+	ModePixels:                  "Pixels",
 }
 
 var Modes = []Mode{
@@ -26,6 +33,7 @@ var Modes = []Mode{
 	ModeBackgroundStringDisplay,
 	ModeClock,
 	ModeBackgroundClock,
+	ModePixels,
 }
 
 func (m Mode) String() string {
@@ -36,3 +44,25 @@ func (m Mode) String() string {
 
 	return v
 }
+
+// ParseMode parses a mode number or name, like "2" or "clock"
+func ParseMode(s string) (Mode, error) {
+	s = strings.TrimSpace(s)
+
+	if v, err := strconv.Atoi(s); err == nil {
+		for _, m := range Modes {
+			if int(m) == v {
+				return m, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid mode %d", v)
+	}
+
+	for _, m := range Modes {
+		if strings.EqualFold(m.String(), s) {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid mode %q", s)
+}
@@ -0,0 +1,300 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/i18n"
+)
+
+// Playlist item types
+const (
+	PlaylistClock    = "clock"
+	PlaylistTitle    = "title"
+	PlaylistFollower = "follower"
+	PlaylistSubGoal  = "subgoal"
+	PlaylistMessage  = "message"
+	PlaylistPanel    = "panel"
+)
+
+// defaultPlaylistDuration is used by items without duration
+const defaultPlaylistDuration = time.Second * 15
+
+// defaultPlaylistTexts are the message keys of the texts of items without text, in the panel language
+var defaultPlaylistTexts = map[string]string{
+	PlaylistFollower: "playlist_follower",
+	PlaylistSubGoal:  "playlist_subgoal",
+}
+
+// playlistItem is a parsed config.PlaylistItem
+type playlistItem struct {
+	kind     string
+	text     string
+	mode     Mode
+	hasMode  bool
+	fgColor  color.Color
+	bgColor  color.Color
+	effect   Effect
+	duration time.Duration
+}
+
+// parsePlaylistItem parses item
+func parsePlaylistItem(item config.PlaylistItem) (playlistItem, error) {
+	p := playlistItem{
+		kind:     strings.ToLower(strings.TrimSpace(item.Type)),
+		text:     item.Text,
+		duration: defaultPlaylistDuration,
+	}
+
+	switch p.kind {
+	case PlaylistClock, PlaylistPanel:
+	case PlaylistTitle:
+		if p.text == "" {
+			p.text = "{title}"
+		}
+	case PlaylistFollower, PlaylistSubGoal:
+		if p.text == "" {
+			p.text = i18n.T(panelLanguage(), defaultPlaylistTexts[p.kind], nil)
+		}
+	case PlaylistMessage:
+		if p.text == "" {
+			return p, fmt.Errorf("message item without text")
+		}
+	default:
+		return p, fmt.Errorf("unknown playlist item type %q", item.Type)
+	}
+
+	var err error
+
+	if item.Mode != "" {
+		if p.mode, err = ParseMode(item.Mode); err != nil {
+			return p, err
+		}
+		p.hasMode = true
+	}
+	if item.TextColor != "" {
+		if p.fgColor, err = ParseColor(item.TextColor); err != nil {
+			return p, fmt.Errorf("invalid text color %q", item.TextColor)
+		}
+	}
+	if item.BGColor != "" {
+		if p.bgColor, err = ParseColor(item.BGColor); err != nil {
+			return p, fmt.Errorf("invalid background color %q", item.BGColor)
+		}
+	}
+	if p.effect, err = ParseEffect(item.Effect); err != nil {
+		return p, err
+	}
+	if item.Duration != "" {
+		if p.duration, err = time.ParseDuration(item.Duration); err != nil || p.duration <= 0 {
+			return p, fmt.Errorf("invalid duration %q", item.Duration)
+		}
+	}
+
+	return p, nil
+}
+
+// parsePlaylist parses the valid items of items and returns the errors of the others
+func parsePlaylist(items []config.PlaylistItem) ([]playlistItem, []error) {
+	var playlist []playlistItem
+	var errs []error
+
+	for i, item := range items {
+		p, err := parsePlaylistItem(item)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("playlist item %d: %s", i+1, err))
+			continue
+		}
+		playlist = append(playlist, p)
+	}
+
+	return playlist, errs
+}
+
+// ValidatePlaylists returns the errors of the configured playlists
+func ValidatePlaylists(playlists config.Playlists) []error {
+	_, errs := parsePlaylist(playlists.Online)
+	_, offline := parsePlaylist(playlists.Offline)
+	return append(errs, offline...)
+}
+
+// loadPlaylist selects the playlist of the stream status. Called with the device lock held
+func (d *Device) loadPlaylist() {
+	playlists := config.GetConfig().Playlists
+	items := playlists.Offline
+	if d.streamOnline {
+		items = playlists.Online
+	}
+
+	d.playlist, _ = parsePlaylist(items)
+	d.playlistPos = -1
+	d.idleUntil = time.Time{}
+
+	if len(d.playlist) == 0 && d.idleShowing && d.current == nil {
+		// Nothing to rotate, go back to the panel settings
+		d.stopEffect()
+		d.restoreState()
+	}
+	d.idleShowing = false
+}
+
+// playlistVars are the variables of the playlist texts
+func (d *Device) playlistVars() map[string]string {
+	return map[string]string{
+		"title": d.streamTitle,
+		"user":  d.lastFollower,
+		"subs":  strconv.Itoa(d.subCount),
+		"goal":  strconv.Itoa(d.subGoal),
+	}
+}
+
+// playlistText returns the text of item, or false if there is nothing to show
+func (d *Device) playlistText(item playlistItem) (string, bool) {
+	switch item.kind {
+	case PlaylistClock:
+		return "", true
+	case PlaylistTitle:
+		if d.streamTitle == "" {
+			return "", false
+		}
+	case PlaylistFollower:
+		if d.lastFollower == "" {
+			return "", false
+		}
+	case PlaylistSubGoal:
+		if d.subGoal <= 0 {
+			return "", false
+		}
+	case PlaylistPanel:
+		return d.lastMessage, d.lastMessage != ""
+	}
+
+	return renderTemplate(item.text, d.playlistVars()), true
+}
+
+// idle rotates the playlist. Returns how long to wait until the next item.
+// Called with the device lock held, when there is no alert
+func (d *Device) idle(now time.Time) time.Duration {
+	if len(d.playlist) == 0 {
+		return time.Hour
+	}
+
+	if d.idleShowing && now.Before(d.idleUntil) {
+		return d.idleUntil.Sub(now)
+	}
+
+	// Items without data are skipped. If none has data, try again later
+	for range d.playlist {
+		d.playlistPos = (d.playlistPos + 1) % len(d.playlist)
+		item := d.playlist[d.playlistPos]
+
+		text, ok := d.playlistText(item)
+		if !ok {
+			continue
+		}
+
+		d.showPlaylistItem(item, text)
+		d.idleShowing = true
+		d.idleUntil = now.Add(item.duration)
+
+		return item.duration
+	}
+
+	return defaultPlaylistDuration
+}
+
+// showPlaylistItem sends item to the display without changing the device state
+func (d *Device) showPlaylistItem(item playlistItem, text string) {
+	log.Debug("Showing playlist item %s", item.kind)
+	d.stopEffect()
+
+	fg := d.lastColor
+	if item.fgColor != nil {
+		fg = item.fgColor
+	}
+	bg := d.lastBGColor
+	if item.bgColor != nil {
+		bg = item.bgColor
+	}
+
+	mode := item.mode
+	if !item.hasMode {
+		mode = ModeStringDisplay
+		if item.kind == PlaylistClock {
+			mode = ModeClock
+		}
+	}
+
+	d.showMode(mode)
+	d.showBGColor(bg)
+	d.showTextColor(fg)
+	if text != "" {
+		d.showMessage(text, fg)
+	}
+	if item.effect != nil {
+		d.startEffect(item.effect)
+	}
+}
+
+// playlistShowing returns the type of the item showing. Called with the device lock held
+func (d *Device) playlistShowing() string {
+	if !d.idleShowing || d.current != nil || d.playlistPos < 0 || d.playlistPos >= len(d.playlist) {
+		return ""
+	}
+	return d.playlist[d.playlistPos].kind
+}
+
+// resumePlaylist shows the current playlist item again after an alert. Called with the device lock held
+func (d *Device) resumePlaylist() {
+	if !d.idleShowing {
+		return
+	}
+	// The item is shown again from the start
+	d.playlistPos--
+	if d.playlistPos < -1 {
+		d.playlistPos = -1
+	}
+	d.idleShowing = false
+}
+
+// setStreamStatus switches the playlist when the stream goes online or offline
+func (d *Device) setStreamStatus(online bool, title string) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.running {
+		return
+	}
+
+	if title != "" {
+		d.streamTitle = title
+	}
+
+	if online != d.streamOnline {
+		log.Info("Stream is online: %t. Switching playlist", online)
+		d.streamOnline = online
+		if online {
+			d.subCount = 0
+		}
+		d.loadPlaylist()
+	}
+
+	d.wakeUp()
+}
+
+func (d *Device) setStreamTitle(title string) {
+	d.Lock()
+	defer d.Unlock()
+	d.streamTitle = title
+}
+
+func (d *Device) setSubGoal(current, goal int) {
+	d.Lock()
+	defer d.Unlock()
+	d.subCount = current
+	d.subGoal = goal
+}
@@ -1,127 +1,95 @@
 package wimatrix
 
 import (
-	"fmt"
-	"golang.org/x/image/colornames"
-	"time"
-)
-
-func (d *Device) processEvent(e event) {
-	switch e.GetType() {
-	case eventNewSub:
-		d.processNewSub(e.(*newSubEvent))
-	case eventNewFollower:
-		d.processNewFollower(e.(*newFollowerEvent))
-	case eventBGColor:
-		d.processBGColor(e.(*bgColorEvent))
-	case eventTextColor:
-		d.processTextColor(e.(*textColorEvent))
-	case eventMessage:
-		d.processMessage(e.(*messageEvent))
-	case eventBGBrightness:
-		d.processBGBrightness(e.(*bgBrightnessEvent))
-	case eventTextBrightness:
-		d.processTextBrightness(e.(*textBrightnessEvent))
-	case eventNewMode:
-		d.processNewMode(e.(*newModeEvent))
-	case eventSetSpeed:
-		d.processSetSpeed(e.(*newSetSpeedEvent))
-	case eventSetLight:
-		d.processSetLight(e.(*newSetLightEvent))
-	case eventNewBits:
-		d.processNewBits(e.(*newBits))
-	default:
-		log.Error("Unknown event type: (%s) %d", e.GetType(), e.GetType())
-	}
-}
-
-func (d *Device) processNewBits(e *newBits) {
-	m := d.currentMode
-	bgc := d.lastBGColor
-	txc := d.lastColor
-
-	d.setMode(ModeBackgroundStringDisplay)
-	d.setBGColor(colornames.Teal)
-	d.setTextColor(colornames.Green)
+	"strings"
 
-	d.msg(fmt.Sprintf("%s TKS %d BITS!! %s", e.username, e.bits, e.message))
-	// TODO: Effects
-	time.Sleep(time.Second * 20)
-
-	d.setMode(m)
-	d.setBGColor(bgc)
-	d.setTextColor(txc)
-}
-
-func (d *Device) processNewSub(e *newSubEvent) {
-	m := d.currentMode
-	bgc := d.lastBGColor
-	txc := d.lastColor
-
-	d.setMode(ModeBackgroundStringDisplay)
-	d.setBGColor(colornames.Teal)
-	d.setTextColor(colornames.Green)
+	"github.com/racerxdl/twitchled/i18n"
+)
 
-	d.msg(fmt.Sprintf("%s TKS SUB %d MESES!", e.username, e.months))
-	// TODO: Effects
-	time.Sleep(time.Second * 20)
+// Alert values, in cents, used to rank alerts
+const (
+	followerValue   = 1
+	rewardValue     = 1
+	raidViewerValue = 10
+	bitValue        = 1
+)
 
-	d.setMode(m)
-	d.setBGColor(bgc)
-	d.setTextColor(txc)
+// subTierValues has the value of each sub plan
+var subTierValues = map[string]int{
+	"Prime": 499,
+	"1000":  499,
+	"2000":  999,
+	"3000":  2499,
 }
 
-func (d *Device) processNewFollower(e *newFollowerEvent) {
-	m := d.currentMode
-	bgc := d.lastBGColor
-	txc := d.lastColor
-
-	d.setMode(ModeBackgroundStringDisplay)
-	d.setBGColor(colornames.Teal)
-	d.setTextColor(colornames.Green)
+// followersShown is how many names are shown on a coalesced follow alert
+const followersShown = 2
 
-	d.msg(fmt.Sprintf("%s TKS FOLLOW!", e.username))
-	// TODO: Effects
-	time.Sleep(time.Second * 10)
+// alertFor builds the alert for e, ranked by its value
+func alertFor(e event) *alert {
+	a := &alert{
+		event: e,
+	}
 
-	d.setMode(m)
-	d.setBGColor(bgc)
-	d.setTextColor(txc)
-}
+	switch ev := e.(type) {
+	case *newSubEvent:
+		a.score = subTierValues[ev.tier]
+		if a.score == 0 {
+			a.score = subTierValues["1000"]
+		}
+	case *newBits:
+		a.score = ev.bits * bitValue
+	case *newFollowerEvent:
+		a.score = followerValue
+	case *newRaidEvent:
+		a.score = ev.viewers * raidViewerValue
+	case *newRewardEvent:
+		a.score = rewardValue
+	case *messageEvent:
+		// Messages stay on the panel after the alert, only the time is reserved
+		a.message = true
+		a.style.duration = messageDuration
+		a.style.repeat = 1
+		return a
+	}
 
-func (d *Device) processBGColor(e *bgColorEvent) {
-	d.setBGColor(e.color)
-}
+	a.style = styleFor(e)
 
-func (d *Device) processTextColor(e *textColorEvent) {
-	d.setTextColor(e.color)
+	return a
 }
 
-func (d *Device) processMessage(e *messageEvent) {
-	d.msg(e.text)
-	time.Sleep(time.Second * 5) // Wait before returning to event loop
+// alertText is the panel message for a, with the emotes replaced by their stand-ins
+func alertText(a *alert) string {
+	return ReplaceEmotes(rawAlertText(a), alertEmotes(a))
 }
 
-func (d *Device) processBGBrightness(e *bgBrightnessEvent) {
-	if e.brightness > 0.2 {
-		e.brightness = 0.2
+// rawAlertText is the panel message for a, with the emote names
+func rawAlertText(a *alert) string {
+	if e, ok := a.event.(*messageEvent); ok {
+		return e.text
 	}
-	d.setBGBrightness(e.brightness)
-}
 
-func (d *Device) processTextBrightness(e *textBrightnessEvent) {
-	d.setTextBrightness(e.brightness)
-}
+	if a.style.text == "" {
+		return defaultAlertText(a.event)
+	}
 
-func (d *Device) processNewMode(e *newModeEvent) {
-	d.currentMode = e.mode
-	d.setMode(e.mode)
+	return renderTemplate(a.style.text, templateVars(a.event))
 }
 
-func (d *Device) processSetSpeed(e *newSetSpeedEvent) {
-	d.setSpeed(e.speed)
-}
+// followersText joins the follower names like "X, Y E MAIS 8", in the panel language
+func followersText(usernames []string) string {
+	switch {
+	case len(usernames) == 1:
+		return usernames[0]
+	case len(usernames) <= followersShown+1:
+		return i18n.T(panelLanguage(), "followers_and", i18n.Args{
+			"names": strings.Join(usernames[:len(usernames)-1], ", "),
+			"last":  usernames[len(usernames)-1],
+		})
+	}
 
-func (d *Device) processSetLight(e *newSetLightEvent) {
-	d.setLight()
+	return i18n.T(panelLanguage(), "followers_more", i18n.Args{
+		"names": strings.Join(usernames[:followersShown], ", "),
+		"more":  len(usernames) - followersShown,
+	})
 }
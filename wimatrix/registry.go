@@ -0,0 +1,116 @@
+package wimatrix
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TargetAll targets every device
+const TargetAll = "all"
+
+// Target returns the EventBus topic that reaches only the device or group named target.
+// An empty target or TargetAll returns topic, that reaches every device
+func Target(topic, target string) string {
+	target = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(target), "@"))
+	if target == "" || target == TargetAll {
+		return topic
+	}
+	return topic + "@" + target
+}
+
+// Registry keeps the devices by name and group tags
+type Registry struct {
+	sync.Mutex
+	devices map[string]*Device
+}
+
+func MakeRegistry() *Registry {
+	return &Registry{
+		devices: map[string]*Device{},
+	}
+}
+
+// Add adds a device. A device with the same name is replaced
+func (r *Registry) Add(d *Device) {
+	r.Lock()
+	defer r.Unlock()
+	r.devices[strings.ToLower(d.name)] = d
+}
+
+func (r *Registry) Get(name string) (*Device, bool) {
+	r.Lock()
+	defer r.Unlock()
+	d, ok := r.devices[strings.ToLower(name)]
+	return d, ok
+}
+
+// Devices returns all devices sorted by name
+func (r *Registry) Devices() []*Device {
+	r.Lock()
+	defer r.Unlock()
+
+	devices := make([]*Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].name < devices[j].name
+	})
+
+	return devices
+}
+
+// Resolve returns the devices reached by target: a device name, a tag or TargetAll
+func (r *Registry) Resolve(target string) []*Device {
+	target = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(target), "@"))
+
+	var devices []*Device
+	for _, d := range r.Devices() {
+		if target == "" || target == TargetAll || d.hasTarget(target) {
+			devices = append(devices, d)
+		}
+	}
+
+	return devices
+}
+
+// IsTarget returns true if target reaches at least one device
+func (r *Registry) IsTarget(target string) bool {
+	return len(r.Resolve(target)) > 0
+}
+
+// Targets returns the device names and tags
+func (r *Registry) Targets() []string {
+	seen := map[string]bool{}
+	var targets []string
+	for _, d := range r.Devices() {
+		for _, t := range d.targets() {
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+func (r *Registry) StartAll() {
+	for _, d := range r.Devices() {
+		d.Start()
+	}
+}
+
+func (r *Registry) StopAll() {
+	for _, d := range r.Devices() {
+		d.Stop()
+	}
+}
+
+// CloseAll stops every device and closes their displays
+func (r *Registry) CloseAll() {
+	for _, d := range r.Devices() {
+		_ = d.Close()
+	}
+}
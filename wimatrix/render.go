@@ -0,0 +1,269 @@
+package wimatrix
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	// Decoders for profile pictures and emotes
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Default panel resolution
+const (
+	DefaultWidth  = 32
+	DefaultHeight = 8
+)
+
+// gifDefaultDelay is used for GIF frames without delay, like browsers do
+const gifDefaultDelay = time.Millisecond * 100
+
+var imageClient = &http.Client{Timeout: time.Second * 5}
+
+// AnimationFrame is an image shown for Delay
+type AnimationFrame struct {
+	Image *image.RGBA
+	Delay time.Duration
+}
+
+// Animation is a looping sequence of pixel frames. As an Effect it only sends images
+type Animation struct {
+	Frames []AnimationFrame
+	length time.Duration
+}
+
+func NewAnimation(frames []AnimationFrame) *Animation {
+	a := &Animation{Frames: frames}
+	for _, f := range frames {
+		a.length += f.Delay
+	}
+	return a
+}
+
+// StillAnimation is an animation of a single image
+func StillAnimation(img *image.RGBA) *Animation {
+	return NewAnimation([]AnimationFrame{{Image: img, Delay: time.Second}})
+}
+
+// At returns the image shown at t
+func (a *Animation) At(t time.Duration) *image.RGBA {
+	if len(a.Frames) == 0 {
+		return nil
+	}
+	if a.length <= 0 {
+		return a.Frames[0].Image
+	}
+
+	t %= a.length
+	for _, f := range a.Frames {
+		if t < f.Delay {
+			return f.Image
+		}
+		t -= f.Delay
+	}
+
+	return a.Frames[len(a.Frames)-1].Image
+}
+
+func (a *Animation) Name() string {
+	return "animation"
+}
+
+func (a *Animation) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+	f.Image = a.At(t)
+	return f
+}
+
+// RenderImage scales src to fit w x h, centered over black, with nearest neighbour sampling
+func RenderImage(src image.Image, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	sb := src.Bounds()
+	if sb.Dx() == 0 || sb.Dy() == 0 {
+		return dst
+	}
+
+	// Keep the aspect ratio
+	scale := float64(w) / float64(sb.Dx())
+	if s := float64(h) / float64(sb.Dy()); s < scale {
+		scale = s
+	}
+	sw := int(float64(sb.Dx()) * scale)
+	sh := int(float64(sb.Dy()) * scale)
+	if sw < 1 {
+		sw = 1
+	}
+	if sh < 1 {
+		sh = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			sx := sb.Min.X + int(float64(x)/scale)
+			sy := sb.Min.Y + int(float64(y)/scale)
+			scaled.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	offset := image.Pt((w-sw)/2, (h-sh)/2)
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Over)
+
+	return dst
+}
+
+// RenderGIF renders every frame of g at w x h, applying the GIF disposal methods
+func RenderGIF(g *gif.GIF, w, h int) *Animation {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+
+	canvas := image.NewRGBA(bounds)
+	frames := make([]AnimationFrame, 0, len(g.Image))
+
+	for i, img := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		delay := gifDefaultDelay
+		if i < len(g.Delay) && g.Delay[i] > 0 {
+			delay = time.Duration(g.Delay[i]) * time.Millisecond * 10
+		}
+		frames = append(frames, AnimationFrame{
+			Image: RenderImage(canvas, w, h),
+			Delay: delay,
+		})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return NewAnimation(frames)
+}
+
+// Sprite is a pixel art image, one string per row. Each rune is a color of the palette,
+// runes not in the palette are transparent
+type Sprite []string
+
+// Render draws the sprite scaled to fit w x h
+func (s Sprite) Render(palette map[rune]color.Color, w, h int) *image.RGBA {
+	sw := 0
+	for _, row := range s {
+		if n := len([]rune(row)); n > sw {
+			sw = n
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, sw, len(s)))
+	for y, row := range s {
+		for x, r := range []rune(row) {
+			if c, ok := palette[r]; ok {
+				img.Set(x, y, c)
+			}
+		}
+	}
+
+	return RenderImage(img, w, h)
+}
+
+// DecodeAnimation decodes a GIF, PNG or JPEG image rendered at w x h
+func DecodeAnimation(data []byte, w, h int) (*Animation, error) {
+	if g, err := gif.DecodeAll(bytes.NewReader(data)); err == nil {
+		return RenderGIF(g, w, h), nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return StillAnimation(RenderImage(img, w, h)), nil
+}
+
+// FetchAnimation downloads and decodes the image at url
+func FetchAnimation(url string, w, h int) (*Animation, error) {
+	res, err := imageClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", url, res.Status)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeAnimation(data, w, h)
+}
+
+// strip shows animations side by side, like the emotes of a message
+type strip struct {
+	animations []*Animation
+	width      int
+	height     int
+}
+
+// RenderStrip places the animations from left to right on a w x h frame
+func RenderStrip(animations []*Animation, w, h int) Effect {
+	return &strip{
+		animations: animations,
+		width:      w,
+		height:     h,
+	}
+}
+
+func (s *strip) Name() string {
+	return "strip"
+}
+
+func (s *strip) Frame(t time.Duration) Frame {
+	f := NewFrame(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	x := 0
+	for _, a := range s.animations {
+		frame := a.At(t)
+		if frame == nil {
+			continue
+		}
+		b := frame.Bounds()
+		draw.Draw(img, b.Add(image.Pt(x, 0)), frame, image.Point{}, draw.Src)
+		x += b.Dx()
+		if x >= s.width {
+			break
+		}
+	}
+
+	f.Image = img
+
+	return f
+}
@@ -0,0 +1,275 @@
+package wimatrix
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// alertMinShowTime is the minimum time an alert stays on the panel before being cut short
+	alertMinShowTime = time.Second * 3
+	// messageDuration is the time reserved for panel messages
+	messageDuration = time.Second * 5
+	// alertImageTime is how long the images of an alert are shown before the text
+	alertImageTime = time.Second * 4
+)
+
+// alert is an event scheduled to be shown on the panel
+type alert struct {
+	event event
+	score int
+	seq   uint64
+	style alertStyle
+	shown int
+
+	// images are shown for imageLength before the text on panels with pixel frames. Loaded in background
+	images      Effect
+	imageLength time.Duration
+
+	// message is true for panel messages, that keep the mode and colors and are not restored
+	message bool
+
+	// done is told when the alert is shown or discarded
+	done AlertDone
+}
+
+// alertQueue keeps the alerts ordered by score. Alerts with the same score keep arrival order
+type alertQueue struct {
+	items []*alert
+	seq   uint64
+}
+
+func (q *alertQueue) push(a *alert) {
+	q.seq++
+	a.seq = q.seq
+	q.items = append(q.items, a)
+	sort.SliceStable(q.items, func(i, j int) bool {
+		if q.items[i].score != q.items[j].score {
+			return q.items[i].score > q.items[j].score
+		}
+		return q.items[i].seq < q.items[j].seq
+	})
+}
+
+// peek returns the next alert, discarding the ones expired at now
+func (q *alertQueue) peek(now time.Time) *alert {
+	for len(q.items) > 0 {
+		if !q.items[0].event.Expired(now) {
+			return q.items[0]
+		}
+		log.Warn("Discarding expired alert %d", q.items[0].event.GetType())
+		q.items[0].finish(AlertExpired)
+		q.items = q.items[1:]
+	}
+	return nil
+}
+
+func (q *alertQueue) pop(now time.Time) *alert {
+	a := q.peek(now)
+	if a != nil {
+		q.items = q.items[1:]
+	}
+	return a
+}
+
+// mergeFollower adds username to a queued follow alert. Returns false if there is none
+func (q *alertQueue) mergeFollower(username string) bool {
+	for _, a := range q.items {
+		if e, ok := a.event.(*newFollowerEvent); ok {
+			e.usernames = append(e.usernames, username)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *alertQueue) Len() int {
+	return len(q.items)
+}
+
+// queueAlert adds an alert and wakes the event loop
+func (d *Device) queueAlert(e event) {
+	d.queueTrackedAlert(e, nil)
+}
+
+// queueTrackedAlert adds an alert that reports to done when it is shown or discarded
+func (d *Device) queueTrackedAlert(e event, done AlertDone) {
+	d.Lock()
+	if !d.running {
+		if done != nil {
+			done(AlertDropped)
+		}
+		d.Unlock()
+		return
+	}
+	d.track(e)
+	if f, ok := e.(*newFollowerEvent); ok && len(f.usernames) == 1 && d.alerts.mergeFollower(f.usernames[0]) {
+		log.Debug("Merged follow from %s into queued alert", f.usernames[0])
+	} else {
+		a := alertFor(e)
+		a.done = done
+		d.attachPendingEmotes(a)
+		d.alerts.push(a)
+		d.loadImages(a)
+	}
+	d.Unlock()
+
+	d.wakeUp()
+}
+
+// track keeps the data shown on the playlist. Called with the device lock held
+func (d *Device) track(e event) {
+	switch ev := e.(type) {
+	case *newFollowerEvent:
+		d.lastFollower = ev.usernames[len(ev.usernames)-1]
+	case *newSubEvent:
+		d.subCount++
+	}
+}
+
+func (d *Device) wakeUp() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule ends, starts or preempts alerts. Returns how long to wait until the next check
+func (d *Device) schedule() time.Duration {
+	d.Lock()
+	defer d.Unlock()
+
+	now := d.clock.Now()
+
+	if d.current != nil && now.Sub(d.currentStart) >= d.current.style.duration {
+		if d.current.shown < d.current.style.repeat {
+			d.repeatAlert()
+		} else {
+			d.endAlert()
+		}
+	}
+
+	next := d.alerts.peek(now)
+
+	if next != nil && d.current != nil && next.score > d.current.score {
+		shown := now.Sub(d.currentStart)
+		if shown < alertMinShowTime {
+			return alertMinShowTime - shown
+		}
+		log.Info("Alert %d cut short by alert %d", d.current.event.GetType(), next.event.GetType())
+		d.endAlert()
+	}
+
+	if d.current == nil {
+		next = d.alerts.pop(now)
+		if next == nil {
+			return d.idle(now)
+		}
+		d.startAlert(next)
+	}
+
+	return d.current.style.duration - now.Sub(d.currentStart)
+}
+
+func (d *Device) startAlert(a *alert) {
+	d.stopEffect()
+	d.current = a
+	d.currentStart = d.clock.Now()
+	a.shown = 1
+
+	if d.online {
+		a.finish(AlertShown)
+	} else {
+		a.finish(AlertOffline)
+	}
+
+	if a.message {
+		d.lastMessage = alertText(a)
+		d.persist()
+		d.showMessage(d.lastMessage, d.lastColor)
+		if a.images != nil {
+			d.startEffect(alertEffect(a))
+		}
+		return
+	}
+
+	d.showAlert(a)
+}
+
+// showAlert sends the alert style and text to the display
+func (d *Device) showAlert(a *alert) {
+	s := a.style
+	d.showMode(s.mode)
+	d.showBGColor(s.bgColor)
+	d.showTextColor(s.fgColor)
+	if s.brightness > 0 {
+		d.showTextBrightness(s.brightness)
+	}
+	if s.speed > 0 {
+		d.showSpeed(s.speed)
+	}
+	d.showMessage(alertText(a), s.fgColor)
+	if effect := alertEffect(a); effect != nil {
+		d.startEffect(effect)
+	}
+}
+
+// alertEffect is the effect of a, after its images if loaded
+func alertEffect(a *alert) Effect {
+	if a.images == nil {
+		return a.style.effect
+	}
+
+	length := a.imageLength
+	if a.style.duration < length {
+		length = a.style.duration
+	}
+
+	return Then(a.images, length, a.style.effect)
+}
+
+// repeatAlert shows the text of the current alert again
+func (d *Device) repeatAlert() {
+	a := d.current
+	a.shown++
+	d.currentStart = d.clock.Now()
+	d.showMessage(alertText(a), a.style.fgColor)
+}
+
+// endAlert restores the state set before the alert
+func (d *Device) endAlert() {
+	a := d.current
+	d.current = nil
+	d.stopEffect()
+
+	if a.message {
+		if a.images != nil {
+			// Back from the scrolling pixel message
+			d.showMode(d.currentMode)
+		}
+		d.resumePlaylist()
+		return
+	}
+
+	d.showMode(d.currentMode)
+	d.showBGColor(d.lastBGColor)
+	d.showTextColor(d.lastColor)
+	if a.style.brightness > 0 || a.style.effect != nil {
+		d.showTextBrightness(d.lastBrightness)
+	}
+	if a.style.effect != nil {
+		d.showBGBrightness(d.lastBgBrightness)
+	}
+	if a.style.speed > 0 && d.lastSpeed > 0 {
+		d.showSpeed(d.lastSpeed)
+	}
+	if d.lastMessage != "" {
+		d.showMessage(d.lastMessage, d.lastColor)
+	}
+	d.resumePlaylist()
+}
+
+// overridden returns true if the showing alert owns the mode and colors
+func (d *Device) overridden() bool {
+	return d.current != nil && !d.current.message
+}
@@ -0,0 +1,78 @@
+package wimatrix
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScheduleFakeClock(t *testing.T) {
+	display := MakeRecordingDisplay()
+	clock := NewFakeClock(time.Now())
+	d := MakeWiiMatrix("test", display, nil)
+	d.SetClock(clock)
+	d.running = true
+
+	d.evNewMessage("first", nil)
+	d.evNewMessage("second", nil)
+
+	if wait := d.schedule(); wait != messageDuration {
+		t.Errorf("schedule() = %s, want %s", wait, messageDuration)
+	}
+
+	// The real time does not end the alert
+	time.Sleep(time.Millisecond * 10)
+	if wait := d.schedule(); wait != messageDuration {
+		t.Errorf("schedule() without advancing = %s, want %s", wait, messageDuration)
+	}
+	if got := display.Messages(); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Fatalf("messages = %v, want [first]", got)
+	}
+
+	clock.Advance(time.Second * 2)
+	if wait := d.schedule(); wait != messageDuration-time.Second*2 {
+		t.Errorf("schedule() after 2s = %s, want %s", wait, messageDuration-time.Second*2)
+	}
+
+	clock.Advance(messageDuration - time.Second*2)
+	d.schedule()
+	if got := display.Messages(); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("messages = %v, want [first second]", got)
+	}
+}
+
+func TestScheduleExpired(t *testing.T) {
+	display := MakeRecordingDisplay()
+	clock := NewFakeClock(time.Now())
+	d := MakeWiiMatrix("test", display, nil)
+	d.SetClock(clock)
+	d.running = true
+
+	var results []AlertResult
+	done := func(result AlertResult) {
+		results = append(results, result)
+	}
+
+	d.evNewMessage("first", done)
+	d.schedule()
+
+	// Waits behind the message, on the device clock
+	d.evNewReward("viewer", "Hydrate", "", 100, done)
+	d.schedule()
+	if d.QueueLength() != 1 {
+		t.Fatalf("queue length = %d, want 1", d.QueueLength())
+	}
+
+	clock.Advance(expirationDuration + time.Second)
+	d.schedule()
+
+	if want := []AlertResult{AlertShown, AlertExpired}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+	if d.QueueLength() != 0 {
+		t.Errorf("queue length = %d, want 0", d.QueueLength())
+	}
+	if got := display.Messages(); !reflect.DeepEqual(got, []string{"first"}) {
+		t.Errorf("messages = %v, want [first]", got)
+	}
+}
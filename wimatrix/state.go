@@ -0,0 +1,167 @@
+package wimatrix
+
+import (
+	"encoding/json"
+	"image/color"
+	"io/ioutil"
+	"os"
+)
+
+// State is a snapshot of the device state
+type State struct {
+	Running bool `json:"running"`
+	// Online is false when the display reports the panel offline
+	Online       bool       `json:"online"`
+	Mode         Mode       `json:"mode"`
+	TextColor    color.RGBA `json:"text_color"`
+	BGColor      color.RGBA `json:"bg_color"`
+	Brightness   float32    `json:"brightness"`
+	BGBrightness float32    `json:"bg_brightness"`
+	Speed        int        `json:"speed"`
+	// Message is the text showing on the panel
+	Message string `json:"message"`
+	// PanelMessage is the last message sent by chat or rewards, shown again after alerts
+	PanelMessage string `json:"panel_message"`
+	// Alert is true while an alert is showing
+	Alert       bool `json:"alert"`
+	QueueLength int  `json:"queue_length"`
+	// Playlist is the type of the idle playlist item showing, if any
+	Playlist string `json:"playlist,omitempty"`
+}
+
+// savedState is the part of State that is restored on restart
+type savedState struct {
+	Mode         Mode       `json:"mode"`
+	TextColor    color.RGBA `json:"text_color"`
+	BGColor      color.RGBA `json:"bg_color"`
+	Brightness   float32    `json:"brightness"`
+	BGBrightness float32    `json:"bg_brightness"`
+	Speed        int        `json:"speed"`
+	PanelMessage string     `json:"panel_message"`
+}
+
+func toRGBA(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+// State returns a snapshot of the device state
+func (d *Device) State() State {
+	d.Lock()
+	defer d.Unlock()
+
+	return State{
+		Running:      d.running,
+		Online:       d.online,
+		Mode:         d.currentMode,
+		TextColor:    toRGBA(d.lastColor),
+		BGColor:      toRGBA(d.lastBGColor),
+		Brightness:   d.lastBrightness,
+		BGBrightness: d.lastBgBrightness,
+		Speed:        d.lastSpeed,
+		Message:      d.currentText,
+		PanelMessage: d.lastMessage,
+		Alert:        d.overridden(),
+		QueueLength:  d.alerts.Len(),
+		Playlist:     d.playlistShowing(),
+	}
+}
+
+// SetStateFile enables saving the state to path on every change. It is loaded on Start
+func (d *Device) SetStateFile(path string) {
+	d.Lock()
+	defer d.Unlock()
+	d.stateFile = path
+}
+
+// SaveState writes the state to path
+func (d *Device) SaveState(path string) error {
+	d.Lock()
+	defer d.Unlock()
+	return d.saveState(path)
+}
+
+// saveState must be called with the lock held
+func (d *Device) saveState(path string) error {
+	s := savedState{
+		Mode:         d.currentMode,
+		TextColor:    toRGBA(d.lastColor),
+		BGColor:      toRGBA(d.lastBGColor),
+		Brightness:   d.lastBrightness,
+		BGBrightness: d.lastBgBrightness,
+		Speed:        d.lastSpeed,
+		PanelMessage: d.lastMessage,
+	}
+
+	data, _ := json.MarshalIndent(&s, "", "    ")
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// persist saves the state if a state file is set. Must be called with the lock held
+func (d *Device) persist() {
+	if d.stateFile == "" {
+		return
+	}
+
+	if err := d.saveState(d.stateFile); err != nil {
+		log.Error("Error saving state to %s: %s", d.stateFile, err)
+	}
+}
+
+// LoadState reads the state from path and sends it to the display
+func (d *Device) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s := savedState{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.currentMode = s.Mode
+	d.lastColor = s.TextColor
+	d.lastBGColor = s.BGColor
+	d.lastBrightness = clampBrightness(s.Brightness)
+	d.lastBgBrightness = clampBrightness(s.BGBrightness)
+	d.lastSpeed = s.Speed
+	d.lastMessage = s.PanelMessage
+
+	if d.overridden() {
+		// Applied when the alert ends
+		return nil
+	}
+
+	d.restoreState()
+
+	return nil
+}
+
+// restoreState sends the device state to the display. Must be called with the lock held
+func (d *Device) restoreState() {
+	d.showMode(d.currentMode)
+	d.showBGColor(d.lastBGColor)
+	d.showTextColor(d.lastColor)
+	// Zero brightness means it was never set
+	if d.lastBrightness > 0 {
+		d.showTextBrightness(d.lastBrightness)
+	}
+	if d.lastBgBrightness > 0 {
+		d.showBGBrightness(d.lastBgBrightness)
+	}
+	if d.lastSpeed > 0 {
+		d.showSpeed(d.lastSpeed)
+	}
+	if d.lastMessage != "" {
+		d.showMessage(d.lastMessage, d.lastColor)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
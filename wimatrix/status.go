@@ -0,0 +1,41 @@
+package wimatrix
+
+// watchStatus tracks the panel status if the display reports it
+func (d *Device) watchStatus() {
+	reporter, ok := d.display.(StatusReporter)
+	if !ok {
+		return
+	}
+
+	d.Lock()
+	d.online = reporter.Online()
+	d.Unlock()
+
+	reporter.OnStatus(d.onDisplayStatus)
+}
+
+// onDisplayStatus pushes the current state again when the panel comes back,
+// since it loses mode, colors and brightness on reboot
+func (d *Device) onDisplayStatus(online bool) {
+	d.Lock()
+	d.online = online
+	running := d.running
+	if online && running {
+		log.Info("Panel %s online. Sending current state", d.name)
+		d.restoreState()
+		if d.current != nil && !d.current.message {
+			d.showAlert(d.current)
+		}
+	}
+	d.Unlock()
+
+	if !running {
+		return
+	}
+
+	if online {
+		d.ev.Publish(EvDeviceOnline, d.name)
+	} else {
+		d.ev.Publish(EvDeviceOffline, d.name)
+	}
+}
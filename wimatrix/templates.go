@@ -0,0 +1,267 @@
+package wimatrix
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/racerxdl/twitchled/config"
+	"github.com/racerxdl/twitchled/i18n"
+)
+
+// alertStyle is a parsed config.AlertTemplate
+type alertStyle struct {
+	text       string
+	mode       Mode
+	fgColor    color.Color
+	bgColor    color.Color
+	brightness float32
+	speed      int
+	duration   time.Duration
+	repeat     int
+	effect     Effect
+}
+
+// defaultTemplates are used when the config has no template for an alert type. Their text is
+// the alertKeys message in the panel language
+var defaultTemplates = map[eventType]config.AlertTemplate{
+	eventNewSub: {
+		Mode:      "2",
+		TextColor: "green",
+		BGColor:   "teal",
+		Duration:  "20s",
+		Effect:    "rainbow period=4s",
+	},
+	eventNewBits: {
+		Mode:      "2",
+		TextColor: "green",
+		BGColor:   "teal",
+		Duration:  "20s",
+	},
+	eventNewFollower: {
+		Mode:      "2",
+		TextColor: "green",
+		BGColor:   "teal",
+		Duration:  "10s",
+	},
+	eventNewRaid: {
+		Mode:      "2",
+		TextColor: "yellow",
+		BGColor:   "purple",
+		Duration:  "20s",
+		Effect:    "pulse period=1s min=0.3",
+	},
+	eventNewReward: {
+		Mode:      "2",
+		TextColor: "green",
+		BGColor:   "teal",
+		Duration:  "10s",
+	},
+}
+
+// alertKeys are the message keys of the default alert texts
+var alertKeys = map[eventType]string{
+	eventNewSub:      "alert_sub",
+	eventNewBits:     "alert_bits",
+	eventNewFollower: "alert_follow",
+	eventNewRaid:     "alert_raid",
+	eventNewReward:   "alert_reward",
+}
+
+// panelLanguage is the language of the panel texts
+func panelLanguage() string {
+	return config.GetConfig().Language.PanelLanguage()
+}
+
+// defaultAlertText returns the alert text of e in the panel language
+func defaultAlertText(e event) string {
+	args := i18n.Args{}
+	for k, v := range templateVars(e) {
+		args[k] = v
+	}
+	return strings.TrimSpace(i18n.T(panelLanguage(), alertKeys[e.GetType()], args))
+}
+
+// configTemplates returns the configured variants for an alert type
+func configTemplates(t eventType) []config.AlertTemplate {
+	alerts := config.GetConfig().Alerts
+	switch t {
+	case eventNewSub:
+		return alerts.Sub
+	case eventNewBits:
+		return alerts.Bits
+	case eventNewFollower:
+		return alerts.Follow
+	case eventNewRaid:
+		return alerts.Raid
+	case eventNewReward:
+		return alerts.Reward
+	}
+	return nil
+}
+
+// selectTemplate picks the variant with the highest threshold reached by bits and months
+func selectTemplate(templates []config.AlertTemplate, bits, months int) (config.AlertTemplate, bool) {
+	found := false
+	best := config.AlertTemplate{}
+
+	for _, t := range templates {
+		if t.MinBits > bits || t.MinMonths > months {
+			continue
+		}
+		if !found || t.MinBits > best.MinBits || t.MinMonths > best.MinMonths {
+			best = t
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// parseTemplate parses t, using def for the empty fields
+func parseTemplate(t, def config.AlertTemplate) (alertStyle, error) {
+	if t.Text == "" {
+		t.Text = def.Text
+	}
+	if t.Mode == "" {
+		t.Mode = def.Mode
+	}
+	if t.TextColor == "" {
+		t.TextColor = def.TextColor
+	}
+	if t.BGColor == "" {
+		t.BGColor = def.BGColor
+	}
+	if t.Duration == "" {
+		t.Duration = def.Duration
+	}
+	if t.Effect == "" {
+		t.Effect = def.Effect
+	}
+
+	s := alertStyle{
+		text:       t.Text,
+		brightness: t.Brightness,
+		speed:      t.Speed,
+		repeat:     t.Repeat,
+	}
+
+	var err error
+
+	if s.mode, err = ParseMode(t.Mode); err != nil {
+		return s, err
+	}
+	if s.fgColor, err = ParseColor(t.TextColor); err != nil {
+		return s, fmt.Errorf("invalid text color %q", t.TextColor)
+	}
+	if s.bgColor, err = ParseColor(t.BGColor); err != nil {
+		return s, fmt.Errorf("invalid background color %q", t.BGColor)
+	}
+	if s.duration, err = time.ParseDuration(t.Duration); err != nil {
+		return s, fmt.Errorf("invalid duration %q: %s", t.Duration, err)
+	}
+	if s.effect, err = ParseEffect(t.Effect); err != nil {
+		return s, err
+	}
+	if s.brightness < 0 || s.brightness > 1 {
+		return s, fmt.Errorf("brightness should be between 0 and 1")
+	}
+	if s.repeat < 1 {
+		s.repeat = 1
+	}
+
+	return s, nil
+}
+
+// styleFor returns the style of e from the config, or the default one
+func styleFor(e event) alertStyle {
+	bits, months := 0, 0
+	switch ev := e.(type) {
+	case *newBits:
+		bits = ev.bits
+	case *newSubEvent:
+		months = ev.months
+	}
+
+	def := defaultTemplates[e.GetType()]
+	t, ok := selectTemplate(configTemplates(e.GetType()), bits, months)
+	if !ok {
+		t = def
+	}
+
+	s, err := parseTemplate(t, def)
+	if err != nil {
+		log.Error("Invalid alert template %q: %s. Using default", t.Text, err)
+		s, _ = parseTemplate(def, def)
+	}
+
+	return s
+}
+
+// templateVars are the named variables available on the alert text
+func templateVars(e event) map[string]string {
+	switch ev := e.(type) {
+	case *newSubEvent:
+		return map[string]string{
+			"user":   ev.username,
+			"months": strconv.Itoa(ev.months),
+			"tier":   ev.tier,
+		}
+	case *newBits:
+		return map[string]string{
+			"user":    ev.username,
+			"bits":    strconv.Itoa(ev.bits),
+			"message": ev.message,
+		}
+	case *newFollowerEvent:
+		return map[string]string{
+			"user":  followersText(ev.usernames),
+			"count": strconv.Itoa(len(ev.usernames)),
+		}
+	case *newRaidEvent:
+		return map[string]string{
+			"user":    ev.username,
+			"viewers": strconv.Itoa(ev.viewers),
+		}
+	case *newRewardEvent:
+		return map[string]string{
+			"user":   ev.username,
+			"reward": ev.title,
+			"input":  ev.input,
+			"cost":   strconv.Itoa(ev.cost),
+		}
+	}
+	return nil
+}
+
+// renderTemplate replaces the {name} variables of text. Unknown variables are kept
+func renderTemplate(text string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.TrimSpace(strings.NewReplacer(pairs...).Replace(text))
+}
+
+// ValidateAlertTemplates returns the errors of every configured template
+func ValidateAlertTemplates(alerts config.AlertTemplates) []error {
+	var errs []error
+
+	check := func(name string, t eventType, templates []config.AlertTemplate) {
+		for i, tpl := range templates {
+			if _, err := parseTemplate(tpl, defaultTemplates[t]); err != nil {
+				errs = append(errs, fmt.Errorf("alert %s template %d: %s", name, i+1, err))
+			}
+		}
+	}
+
+	check("sub", eventNewSub, alerts.Sub)
+	check("bits", eventNewBits, alerts.Bits)
+	check("follow", eventNewFollower, alerts.Follow)
+	check("raid", eventNewRaid, alerts.Raid)
+	check("reward", eventNewReward, alerts.Reward)
+
+	return errs
+}
@@ -9,7 +9,9 @@ const (
 	MQTTWiMatrixSetTextColor    = "_textcolor"
 	MQTTWiMatrixSetMode         = "_mode"
 	MQTTWiMatrixSetSpeed        = "_scrollspeed"
-	MQTTSetRoomLight            = "ENTRADA/036"
+	MQTTWiMatrixFrame           = "_frame"  // Raw RGB888 pixels, row by row, at the panel resolution
+	MQTTWiMatrixStatus          = "_status" // "online" or "offline", also the panel last will
+	MQTTWiMatrixState           = "_state"  // JSON state reported by the panel
 )
 
 // EventBus Topics
@@ -17,12 +19,20 @@ const (
 	EvNewSub            = "WiMatrix:NewSub"
 	EvNewBits           = "WiMatrix:NewBits"
 	EvNewFollower       = "WiMatrix:NewFollower"
-	EvNewMsg            = "WiMatrix:NewMsg"
+	EvNewRaid           = "WiMatrix:NewRaid"
+	EvNewReward         = "WiMatrix:NewReward" // username, title, input, cost, AlertDone (can be nil)
+	EvNewMsg            = "WiMatrix:NewMsg"    // message, AlertDone (can be nil)
+	EvNewEmoteMsg       = "WiMatrix:NewEmoteMsg"
 	EvSetTextColor      = "WiMatrix:SetTextColor"
 	EvSetBgColor        = "WiMatrix:SetBackgroundColor"
 	EvSetTextBrightness = "WiMatrix:SetTextBrightness"
 	EvSetBgBrightness   = "WiMatrix:SetBackgroundBrightness"
 	EvNewMode           = "WiMatrix:SetMode"
 	EvSetSpeed          = "WiMatrix:SetSpeed"
-	EvSetLight          = "Room:SetLight"
+	EvDeviceOnline      = "WiMatrix:DeviceOnline"
+	EvDeviceOffline     = "WiMatrix:DeviceOffline"
+	EvBitsEmotes        = "WiMatrix:BitsEmotes"
+	EvStreamStatus      = "WiMatrix:StreamStatus"
+	EvStreamTitle       = "WiMatrix:StreamTitle"
+	EvSubGoal           = "WiMatrix:SubGoal"
 )
@@ -2,69 +2,165 @@ package wimatrix
 
 import (
 	"github.com/asaskevich/EventBus"
-	"github.com/eclipse/paho.mqtt.golang"
-	"github.com/foize/go.fifo"
 	"github.com/quan-to/slog"
+	"github.com/racerxdl/twitchled/config"
 	"golang.org/x/image/colornames"
 	"image/color"
+	"sync"
 	"time"
 )
 
 var log = slog.Scope("WiMatrix")
 
 type Device struct {
+	sync.Mutex
 	name             string
-	mq               mqtt.Client
+	tags             []string
+	display          Display
 	ev               EventBus.Bus
 	lastColor        color.Color
 	lastBGColor      color.Color
-	eventQueue       *fifo.Queue
 	running          bool
 	currentMode      Mode
 	lastBgBrightness float32
 	lastBrightness   float32
+	lastSpeed        int
+	lastMessage      string
+	currentText      string
+	stateFile        string
+	online           bool
+	watchingStatus   bool
+	subscribed       bool
+
+	alerts       alertQueue
+	current      *alert
+	currentStart time.Time
+	wake         chan struct{}
+	stop         chan struct{}
+
+	clock  Clock
+	effect *effectPlayer
+
+	pendingEmotes map[string]pendingEmotes
+
+	// Idle playlist
+	streamOnline bool
+	streamTitle  string
+	lastFollower string
+	subCount     int
+	subGoal      int
+	playlist     []playlistItem
+	playlistPos  int
+	idleUntil    time.Time
+	idleShowing  bool
 }
 
-func MakeWiiMatrix(name string, mq mqtt.Client, ev EventBus.Bus) *Device {
+func MakeWiiMatrix(name string, display Display, ev EventBus.Bus) *Device {
 	return &Device{
 		name:             name,
-		mq:               mq,
+		display:          display,
 		ev:               ev,
 		lastColor:        colornames.White,
 		lastBGColor:      colornames.Black,
 		lastBgBrightness: 0,
 		lastBrightness:   0,
-		eventQueue:       fifo.NewQueue(),
 		running:          false,
+		online:           true,
 		currentMode:      ModeClock,
+		wake:             make(chan struct{}, 1),
+		clock:            RealClock,
+		pendingEmotes:    map[string]pendingEmotes{},
+		playlistPos:      -1,
 	}
 }
 
+func (d *Device) Name() string {
+	return d.name
+}
+
 func (d *Device) Start() {
-	d.subEventBus()
+	d.Lock()
+	if d.running {
+		d.Unlock()
+		return
+	}
 	d.running = true
-	go d.eventLoop()
+	d.stop = make(chan struct{})
+	stateFile := d.stateFile
+	watch := !d.watchingStatus
+	d.watchingStatus = true
+	d.Unlock()
+
+	if watch {
+		d.watchStatus()
+	}
+
+	if stateFile != "" && fileExists(stateFile) {
+		if err := d.LoadState(stateFile); err != nil {
+			log.Error("Error loading state from %s: %s", stateFile, err)
+		}
+	}
+
+	cfg := config.GetConfig()
+	for _, err := range ValidateAlertTemplates(cfg.Alerts) {
+		log.Warn("%s. The default template will be used", err)
+	}
+	for _, err := range ValidatePlaylists(cfg.Playlists) {
+		log.Warn("%s. The item will be skipped", err)
+	}
+
+	d.Lock()
+	if d.subGoal == 0 {
+		d.subGoal = cfg.SubGoal
+	}
+	d.loadPlaylist()
+	d.Unlock()
+
+	d.subEventBus()
+	go d.eventLoop(d.stop)
 }
 
 func (d *Device) Stop() {
+	d.Lock()
+	if !d.running {
+		d.Unlock()
+		return
+	}
 	d.running = false
-	d.unSubEventBus()
+	d.stopEffect()
+	for _, a := range d.alerts.items {
+		a.finish(AlertDropped)
+	}
+	close(d.stop)
+	d.persist()
+	d.Unlock()
 }
 
-func (d *Device) putEvent(event event) {
-	d.eventQueue.Add(event)
+// Close stops the device and closes its display
+func (d *Device) Close() error {
+	d.Stop()
+	return d.display.Close()
 }
 
-func (d *Device) eventLoop() {
-	for d.running {
-		rawe := d.eventQueue.Next()
-		if rawe != nil {
-			e, ok := rawe.(event)
-			if ok && !e.Expired() {
-				d.processEvent(e)
-			}
+// QueueLength returns the number of alerts waiting to be shown
+func (d *Device) QueueLength() int {
+	d.Lock()
+	defer d.Unlock()
+	return d.alerts.Len()
+}
+
+func (d *Device) eventLoop(stop chan struct{}) {
+	for {
+		t := time.NewTimer(d.schedule())
+
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-d.wake:
+		case <-t.C:
 		}
 
-		time.Sleep(time.Millisecond)
+		t.Stop()
 	}
 }